@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// bisectResult is the outcome of bisecting a crash.
+type bisectResult struct {
+	// FirstBad is the first commit at which the crash reproduces.
+	FirstBad string
+
+	// Candidates lists the commits, oldest first, that may have
+	// introduced the crash when commits that could not be tested
+	// prevented narrowing it down to a single one. It is empty if
+	// FirstBad is exact.
+	Candidates []string
+
+	// Summary describes the first bad commit for use in reports.
+	Summary string
+}
+
+// bisectPendingCrashes bisects every crash queued in the state, records the
+// first bad commit in its crash record and announces it through the notifier.
+// Crashes that cannot be bisected are logged and dropped.
+func bisectPendingCrashes(ctx context.Context, logger *slog.Logger,
+	cfg *Config, state *fuzzState, n *notifier) {
+
+	for _, job := range state.takeBisects() {
+		if ctx.Err() != nil {
+			// Keep the job for the next cycle.
+			state.addBisect(job)
+			continue
+		}
+
+		jobLogger := logger.With("package", job.Package, "target",
+			job.Target, "signature", job.Signature)
+
+		jobLogger.Info("Bisecting crash", "good_commit",
+			job.GoodCommit, "bad_commit", job.BadCommit)
+
+		recordPath := filepath.Join(cfg.FuzzResultsPath,
+			crashRecordFileName(job.Package, job.Target,
+				job.Signature))
+		rec, err := loadCrashRecord(recordPath)
+		if err != nil {
+			jobLogger.Error("Failed to load crash record", "error",
+				err)
+			continue
+		}
+
+		result, err := bisectCrash(ctx, jobLogger, cfg, job, rec)
+		if err != nil {
+			if ctx.Err() != nil {
+				state.addBisect(job)
+				continue
+			}
+			jobLogger.Error("Failed to bisect crash", "error", err)
+			continue
+		}
+
+		jobLogger.Info("Crash bisected", "first_bad_commit",
+			result.Summary, "candidates", len(result.Candidates))
+
+		rec.FirstBadCommit = result.FirstBad
+		rec.BisectCandidates = result.Candidates
+		err = saveCrashRecord(cfg.FuzzResultsPath, rec)
+		if err != nil {
+			jobLogger.Error("Failed to save crash record", "error",
+				err)
+			continue
+		}
+
+		msg := fmt.Sprintf("Crash in %s/%s was introduced by %s",
+			rec.Package, rec.Target, result.Summary)
+		n.notify(&event{
+			Type:    eventCrashBisected,
+			Package: rec.Package,
+			Target:  rec.Target,
+			Message: msg,
+			Crash:   rec,
+		})
+	}
+}
+
+// bisectCrash finds the commit between the job's good and bad commits that
+// introduced the crash. It clones the full history of the project into a
+// temporary directory and replays the crash's failing input at each step. An
+// error is returned unless the input passes at the good commit and fails at
+// the bad one.
+func bisectCrash(ctx context.Context, logger *slog.Logger, cfg *Config,
+	job bisectJob, rec *crashRecord) (*bisectResult, error) {
+
+	if len(rec.Input) == 0 {
+		return nil, fmt.Errorf("crash has no failing input to replay")
+	}
+
+	dir, err := os.MkdirTemp("", "go-continuous-fuzz-bisect-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			logger.Error("Failed to remove bisect workspace",
+				"error", err)
+		}
+	}()
+
+	repo, err := cloneRepository(ctx, cfg, dir, 0)
+	if err != nil {
+		return nil, fmt.Errorf("cloning repository: %w", err)
+	}
+
+	commits, err := commitsBetween(repo, job.GoodCommit, job.BadCommit)
+	if err != nil {
+		return nil, err
+	}
+
+	pkgPath := filepath.Join(dir, job.Package)
+	replayAt := func(commit string) (bool, error) {
+		if err := checkoutCommit(repo, commit); err != nil {
+			return false, fmt.Errorf("checking out %s: %w", commit,
+				err)
+		}
+
+		reproduced, err := replayFuzzInput(ctx, cfg, pkgPath,
+			job.Target, rec.InputID, rec.Input,
+			rec.Class == crashClassRace)
+		logger.Info("Bisect step", "commit", commit, "reproduced",
+			reproduced, "error", err)
+
+		return reproduced, err
+	}
+
+	// The crash must not reproduce at the known-good commit, otherwise
+	// it predates the range and there is nothing to bisect.
+	reproduced, err := replayAt(job.GoodCommit)
+	if err != nil {
+		return nil, fmt.Errorf("testing good commit: %w", err)
+	}
+	if reproduced {
+		return nil, fmt.Errorf("crash already reproduces at the last "+
+			"known-good commit %s", job.GoodCommit)
+	}
+
+	// The crash must reproduce at the commit it was found at, otherwise
+	// a flaky crash, or one that only happens while fuzzing, would be
+	// blamed on the newest commit of the range.
+	reproduced, err = replayAt(job.BadCommit)
+	if err != nil {
+		return nil, fmt.Errorf("testing bad commit: %w", err)
+	}
+	if !reproduced {
+		return nil, fmt.Errorf("crash does not reproduce at the "+
+			"commit %s it was found at", job.BadCommit)
+	}
+
+	first, last, err := findFirstBad(ctx, len(commits), func(i int) (bool,
+		error) {
+
+		return replayAt(commits[i])
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &bisectResult{
+		FirstBad: commits[last],
+		Summary:  commitSummary(repo, commits[last]),
+	}
+	if first != last {
+		result.Candidates = commits[first : last+1]
+	}
+
+	return result, nil
+}
+
+// findFirstBad binary searches n commits, ordered oldest first, for the first
+// one at which test reports the crash. The commit preceding the first one is
+// known to be good, and the last one is known to be bad.
+//
+// Commits for which test fails are skipped, like "git bisect skip" does. If
+// skipped commits prevent isolating a single commit, the range of commits that
+// may have introduced the crash is returned as [first, last]; otherwise first
+// equals last.
+func findFirstBad(ctx context.Context, n int, test func(int) (bool,
+	error)) (int, int, error) {
+
+	// good and bad are the indices of the newest commit known to be good
+	// and the oldest commit known to be bad.
+	good, bad := -1, n-1
+	untestable := make(map[int]bool)
+
+	for bad-good > 1 {
+		if err := ctx.Err(); err != nil {
+			return 0, 0, err
+		}
+
+		// Pick the testable commit closest to the midpoint.
+		mid := -1
+		center := good + (bad-good)/2
+		for offset := 0; offset < bad-good; offset++ {
+			for _, i := range []int{center + offset,
+				center - offset} {
+
+				if i > good && i < bad && !untestable[i] {
+					mid = i
+					break
+				}
+			}
+			if mid != -1 {
+				break
+			}
+		}
+
+		// Every commit in the range is untestable.
+		if mid == -1 {
+			break
+		}
+
+		reproduced, err := test(mid)
+		switch {
+		case err != nil:
+			untestable[mid] = true
+
+		case reproduced:
+			bad = mid
+
+		default:
+			good = mid
+		}
+	}
+
+	return good + 1, bad, nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestFindFirstBad verifies that findFirstBad locates the first bad commit,
+// skipping commits that cannot be tested.
+func TestFindFirstBad(t *testing.T) {
+	tests := []struct {
+		name string
+
+		// numCommits is the number of commits in the range.
+		numCommits int
+
+		// firstBad is the index of the commit introducing the crash.
+		firstBad int
+
+		// untestable lists the indices of commits that fail to build.
+		untestable map[int]bool
+
+		expectedFirst int
+		expectedLast  int
+	}{
+		{
+			name:          "single commit",
+			numCommits:    1,
+			firstBad:      0,
+			expectedFirst: 0,
+			expectedLast:  0,
+		},
+		{
+			name:          "crash introduced in the middle",
+			numCommits:    100,
+			firstBad:      37,
+			expectedFirst: 37,
+			expectedLast:  37,
+		},
+		{
+			name:          "crash introduced by the oldest commit",
+			numCommits:    16,
+			firstBad:      0,
+			expectedFirst: 0,
+			expectedLast:  0,
+		},
+		{
+			name:          "untestable commits are skipped",
+			numCommits:    20,
+			firstBad:      12,
+			untestable:    map[int]bool{9: true, 10: true},
+			expectedFirst: 12,
+			expectedLast:  12,
+		},
+		{
+			name:          "untestable commits widen the result",
+			numCommits:    20,
+			firstBad:      12,
+			untestable:    map[int]bool{11: true, 12: true},
+			expectedFirst: 11,
+			expectedLast:  13,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tested := 0
+			first, last, err := findFirstBad(context.Background(),
+				tt.numCommits, func(i int) (bool, error) {
+					tested++
+					if tt.untestable[i] {
+						return false, errors.New(
+							"build failed")
+					}
+					return i >= tt.firstBad, nil
+				})
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedFirst, first)
+			assert.Equal(t, tt.expectedLast, last)
+
+			// Bisection must need far fewer steps than a linear
+			// scan.
+			assert.LessOrEqual(t, tested,
+				len(tt.untestable)+8)
+		})
+	}
+}
+
+// TestBisectCrash verifies that a crash is blamed on the first commit at which
+// its input fails, and that a crash that does not reproduce at the commit it
+// was found at is not blamed on any commit.
+func TestBisectCrash(t *testing.T) {
+	srcDir := t.TempDir()
+	src, err := git.PlainInit(srcDir, false)
+	require.NoError(t, err)
+	worktree, err := src.Worktree()
+	require.NoError(t, err)
+
+	// Each commit writes a fuzz target failing on the given input, or on
+	// none if it is empty.
+	var commits []string
+	commit := func(failOn string) {
+		writeFiles(t, srcDir, map[string]string{
+			"go.mod": "module example.com/fuzz\n\ngo 1.23\n",
+			"parser/parse_test.go": fmt.Sprintf(`package parser
+
+import "testing"
+
+// Commit %d.
+func FuzzParse(f *testing.F) {
+	f.Fuzz(func(t *testing.T, s string) {
+		if s != "" && s == %q {
+			t.Fatal(s)
+		}
+	})
+}
+`, len(commits), failOn),
+		})
+		require.NoError(t, worktree.AddGlob("."))
+		hash, err := worktree.Commit("commit", &git.CommitOptions{
+			All: true,
+			Author: &object.Signature{Name: "dev",
+				When: time.Now()},
+		})
+		require.NoError(t, err)
+		commits = append(commits, hash.String())
+	}
+	commit("")
+	commit("")
+	commit("boom")
+	commit("boom")
+	commit("other")
+	require.NoError(t, src.Storer.SetReference(
+		plumbing.NewHashReference(plumbing.NewBranchReferenceName(
+			"fuzz-example"), plumbing.NewHash(commits[4]))))
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg := &Config{ProjectSrcPath: srcDir, ReplayTimeout: time.Minute}
+	rec := &crashRecord{
+		Package: "parser",
+		Target:  "FuzzParse",
+		InputID: "boom",
+		Input:   []byte("go test fuzz v1\nstring(\"boom\")\n"),
+	}
+	job := bisectJob{Package: "parser", Target: "FuzzParse",
+		GoodCommit: commits[0], BadCommit: commits[3]}
+
+	result, err := bisectCrash(context.Background(), logger, cfg, job,
+		rec)
+	require.NoError(t, err)
+	assert.Equal(t, commits[2], result.FirstBad)
+	assert.Empty(t, result.Candidates)
+
+	// The input no longer fails at the newest commit, so the crash it was
+	// supposedly found at cannot be bisected.
+	job.BadCommit = commits[4]
+	_, err = bisectCrash(context.Background(), logger, cfg, job, rec)
+	assert.ErrorContains(t, err, "does not reproduce")
+}
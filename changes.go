@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// initialDeepenDepth is the depth, in commits, of the first fetch deepening
+// the shallow clone of the project to reach the commit of the previous cycle.
+// Each following fetch doubles it.
+const initialDeepenDepth = 16
+
+// changedFuzzPackages returns the fuzzed packages affected by the changes
+// between the commit of the previous cycle, from, and the current one, to: the
+// packages that, or whose dependencies, have a changed file. It returns nil if
+// there is no previous commit or it is the current one. Failures are logged
+// and yield nil, as the cycle fuzzes all targets regardless.
+func changedFuzzPackages(ctx context.Context, logger *slog.Logger,
+	cfg *Config, repo *git.Repository, from, to string,
+	pkgTargets map[string][]string) map[string]bool {
+
+	if from == "" || from == to {
+		return nil
+	}
+
+	ctx, span := startSpan(ctx, "detect changed packages",
+		attribute.String("from", from), attribute.String("to", to))
+
+	files, err := changedFiles(ctx, repo, from, to)
+	if err != nil {
+		endSpan(span, err)
+		logger.Error("Failed to diff commits", "from", from, "to", to,
+			"error", err)
+		return nil
+	}
+
+	changed, err := affectedPackages(ctx, cfg,
+		slices.Sorted(maps.Keys(pkgTargets)), files)
+	span.SetAttributes(attribute.Int("files", len(files)),
+		attribute.Int("packages", len(changed)))
+	endSpan(span, err)
+	if err != nil {
+		logger.Error("Failed to find changed packages", "error", err)
+		return nil
+	}
+
+	logger.Info("Detected changed packages", "from", from, "to", to,
+		"files", len(files), "packages",
+		slices.Sorted(maps.Keys(changed)))
+
+	return changed
+}
+
+// changedFiles returns the slash separated paths, sorted, of the files added,
+// modified or removed between the commits from and to. If the repository, a
+// shallow clone, lacks from, its history is deepened until it holds it.
+func changedFiles(ctx context.Context, repo *git.Repository, from,
+	to string) ([]string, error) {
+
+	toCommit, err := repo.CommitObject(plumbing.NewHash(to))
+	if err != nil {
+		return nil, fmt.Errorf("resolving commit %s: %w", to, err)
+	}
+
+	fromCommit, err := repo.CommitObject(plumbing.NewHash(from))
+	if errors.Is(err, plumbing.ErrObjectNotFound) {
+		err = deepenHistory(ctx, repo, from)
+		if err == nil {
+			fromCommit, err = repo.CommitObject(
+				plumbing.NewHash(from))
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("resolving commit %s: %w", from, err)
+	}
+
+	fromTree, err := fromCommit.Tree()
+	if err != nil {
+		return nil, err
+	}
+	toTree, err := toCommit.Tree()
+	if err != nil {
+		return nil, err
+	}
+	changes, err := object.DiffTreeWithOptions(ctx, fromTree, toTree, nil)
+	if err != nil {
+		return nil, fmt.Errorf("diffing %s and %s: %w", from, to, err)
+	}
+
+	var files []string
+	for _, change := range changes {
+		for _, name := range []string{change.From.Name,
+			change.To.Name} {
+
+			if name != "" && !slices.Contains(files, name) {
+				files = append(files, name)
+			}
+		}
+	}
+	slices.Sort(files)
+
+	return files, nil
+}
+
+// deepenHistory fetches more history into the shallow clone repo, doubling
+// its depth, until it holds the commit hash. Servers only serve the commits
+// reachable from their references, so the history is deepened from the
+// fetched branch rather than fetching the commit by its hash. It gives up once
+// the whole history, or maxHistoryWalk commits of it, are fetched.
+func deepenHistory(ctx context.Context, repo *git.Repository,
+	hash string) error {
+
+	for depth := initialDeepenDepth; ; depth *= 2 {
+		depth = min(depth, maxHistoryWalk)
+		err := repo.FetchContext(ctx, &git.FetchOptions{Depth: depth})
+		if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+			return fmt.Errorf("deepening history to %d commits: %w",
+				depth, err)
+		}
+
+		_, err = repo.CommitObject(plumbing.NewHash(hash))
+		if !errors.Is(err, plumbing.ErrObjectNotFound) {
+			return err
+		}
+
+		shallow, err := repo.Storer.Shallow()
+		if err != nil {
+			return err
+		}
+		if len(shallow) == 0 || depth == maxHistoryWalk {
+			return fmt.Errorf("commit %s not found in the last %d "+
+				"commits of the history", hash, depth)
+		}
+	}
+}
+
+// affectedPackages returns the packages among pkgs, relative to the project
+// directory, that are affected by the changed files: the packages depending,
+// directly or through their tests, on a package with a changed file. A file in
+// a testdata directory belongs to the package containing it, and a changed
+// go.mod, go.sum or go.work file affects every package below it.
+func affectedPackages(ctx context.Context, cfg *Config, pkgs,
+	files []string) (map[string]bool, error) {
+
+	changedDirs := make(map[string]bool)
+	var modDirs []string
+	for _, file := range files {
+		dir := path.Dir(file)
+		switch path.Base(file) {
+		case "go.mod", "go.sum", "go.work":
+			modDirs = append(modDirs, dir)
+			continue
+		}
+
+		elems := strings.Split(dir, "/")
+		if i := slices.Index(elems, "testdata"); i >= 0 {
+			dir = path.Join(append([]string{"."}, elems[:i]...)...)
+		}
+		changedDirs[dir] = true
+	}
+
+	affected := make(map[string]bool)
+	for _, pkg := range pkgs {
+		pkgDir := path.Clean(filepath.ToSlash(pkg))
+		if slices.ContainsFunc(modDirs, func(dir string) bool {
+			return dir == "." || pkgDir == dir ||
+				strings.HasPrefix(pkgDir, dir+"/")
+		}) {
+
+			affected[pkg] = true
+			continue
+		}
+
+		deps, err := packageDepDirs(ctx, cfg, pkg)
+		if err != nil {
+			return nil, err
+		}
+		if slices.ContainsFunc(deps, func(dir string) bool {
+			return changedDirs[dir]
+		}) {
+
+			affected[pkg] = true
+		}
+	}
+
+	return affected, nil
+}
+
+// packageDepDirs returns the directories, slash separated and relative to the
+// project directory, of the package and of the dependencies of its tests that
+// are located in the project, as listed by "go list -deps -test".
+func packageDepDirs(ctx context.Context, cfg *Config,
+	pkg string) ([]string, error) {
+
+	cmd := goCommand(ctx, cfg, filepath.Join(cfg.ProjectDir, pkg), "list",
+		"-e", "-deps", "-test", "-f", "{{.Dir}}", ".")
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("listing dependencies of %q: %w "+
+			"(output: %q)", pkg, err,
+			strings.TrimSpace(stderr.String()))
+	}
+
+	projectDir, err := filepath.Abs(cfg.ProjectDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var dirs []string
+	for _, dir := range strings.Split(stdout.String(), "\n") {
+		if dir == "" {
+			continue
+		}
+		rel, err := filepath.Rel(projectDir, dir)
+		if err != nil || !filepath.IsLocal(rel) {
+			continue
+		}
+		dirs = append(dirs, filepath.ToSlash(rel))
+	}
+
+	return dirs, nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFiles writes the files, keyed by their slash separated path, into dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+}
+
+// TestChangedFiles verifies that the files changed between two commits are
+// listed, deepening a shallow clone lacking the older commit until it holds
+// it.
+func TestChangedFiles(t *testing.T) {
+	srcDir := t.TempDir()
+	src, err := git.PlainInit(srcDir, false)
+	require.NoError(t, err)
+
+	worktree, err := src.Worktree()
+	require.NoError(t, err)
+	commit := func(msg string) string {
+		require.NoError(t, worktree.AddGlob("."))
+		hash, err := worktree.Commit(msg, &git.CommitOptions{
+			All: true,
+			Author: &object.Signature{Name: "dev",
+				When: time.Now()},
+		})
+		require.NoError(t, err)
+		return hash.String()
+	}
+
+	writeFiles(t, srcDir, map[string]string{
+		"lexer/lex.go":    "package lexer\n",
+		"parser/parse.go": "package parser\n",
+		"README.md":       "# project\n",
+	})
+	from := commit("initial")
+
+	writeFiles(t, srcDir, map[string]string{
+		"lexer/lex.go": "package lexer\n\n// Changed.\n",
+		"parser/testdata/fuzz/FuzzParse/1": "go test fuzz v1\n" +
+			"int(1)\n",
+	})
+	require.NoError(t, os.Remove(filepath.Join(srcDir, "README.md")))
+	commit("change")
+
+	// Bury the changes under more commits than the first deepening
+	// fetches.
+	var to string
+	for i := range initialDeepenDepth + 1 {
+		writeFiles(t, srcDir, map[string]string{
+			"lexer/lex.go": fmt.Sprintf("package lexer\n\n"+
+				"// Changed %d times.\n", i+1),
+		})
+		to = commit("more changes")
+	}
+
+	repo, err := git.PlainClone(t.TempDir(), false, &git.CloneOptions{
+		URL:   srcDir,
+		Depth: 1,
+	})
+	require.NoError(t, err)
+	shallow, err := repo.Storer.Shallow()
+	require.NoError(t, err)
+	require.NotEmpty(t, shallow)
+
+	files, err := changedFiles(context.Background(), repo, from, to)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"README.md", "lexer/lex.go",
+		"parser/testdata/fuzz/FuzzParse/1"}, files)
+
+	files, err = changedFiles(context.Background(), repo, to, to)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+
+	// A commit missing from the history is reported.
+	_, err = changedFiles(context.Background(), repo,
+		strings.Repeat("ab", 20), to)
+	assert.ErrorContains(t, err, "not found")
+}
+
+// TestAffectedPackages verifies that a changed file affects its package and
+// the packages depending on it, directly or through their tests.
+func TestAffectedPackages(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.mod":       "module example.com/fuzz\n\ngo 1.23\n",
+		"lexer/lex.go": "package lexer\n",
+		"parser/parse.go": "package parser\n\n" +
+			"import _ \"example.com/fuzz/lexer\"\n",
+		"codec/codec.go": "package codec\n",
+		"codec/codec_test.go": "package codec\n\n" +
+			"import _ \"example.com/fuzz/lexer\"\n",
+		"sub/other/other.go": "package other\n",
+	})
+
+	cfg := &Config{ProjectDir: dir}
+	pkgs := []string{"codec", "lexer", "parser", "sub/other"}
+	tests := []struct {
+		name  string
+		files []string
+		want  map[string]bool
+	}{
+		{
+			name:  "dependency",
+			files: []string{"lexer/lex.go"},
+			want: map[string]bool{"codec": true, "lexer": true,
+				"parser": true},
+		},
+		{
+			name:  "dependent",
+			files: []string{"parser/parse.go"},
+			want:  map[string]bool{"parser": true},
+		},
+		{
+			name: "testdata",
+			files: []string{
+				"sub/other/testdata/fuzz/FuzzOther/1"},
+			want: map[string]bool{"sub/other": true},
+		},
+		{
+			name:  "module",
+			files: []string{"sub/go.sum"},
+			want:  map[string]bool{"sub/other": true},
+		},
+		{
+			name:  "unrelated",
+			files: []string{"README.md", "docs/fuzzing.md"},
+			want:  map[string]bool{},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := affectedPackages(context.Background(), cfg,
+				pkgs, tc.files)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
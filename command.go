@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	flags "github.com/jessevdk/go-flags"
+)
+
+// corpusCommand groups the commands managing the corpus stored in the S3
+// bucket. They run once instead of the fuzzing daemon, using the same
+// configuration.
+//
+//nolint:lll
+type corpusCommand struct {
+	Minimize struct{} `command:"minimize" description:"Replay the corpus of every fuzz target with coverage, keep a minimal set of inputs preserving the total coverage and archive the others"`
+
+	Import corpusImportCommand `command:"import" description:"Merge inputs from the repository's seed corpora, local directories or archives into the stored corpus, skipping invalid and duplicate inputs"`
+
+	Export corpusExportCommand `command:"export" description:"Write the inputs of the stored corpus into a directory, in the testdata/fuzz layout of the packages"`
+
+	Stats struct{} `command:"stats" description:"Print the number of inputs, their sizes, the age of the newest one and the types of their values for every fuzz target of the stored corpus"`
+}
+
+// corpusImportCommand holds the options of the "corpus import" command.
+//
+//nolint:lll
+type corpusImportCommand struct {
+	RepoTestdata bool `long:"repo_testdata" description:"Import the seed corpora in the testdata/fuzz directories of the fuzzed packages of the repository"`
+
+	Dirs []string `long:"dir" description:"Directory to import inputs from; may be repeated"`
+
+	Archives []string `long:"archive" description:"Zip or tar file, optionally gzip-compressed, to import inputs from; may be repeated"`
+
+	Package string `long:"package" description:"Package the inputs are imported for, unless they are in a <package>/testdata/fuzz/<target> directory"`
+
+	Target string `long:"target" description:"Fuzz target the inputs are imported for; without it, inputs must be in a <target> directory"`
+}
+
+// corpusExportCommand holds the options of the "corpus export" command.
+//
+//nolint:lll
+type corpusExportCommand struct {
+	Package string `long:"package" description:"Only export the inputs of the fuzz targets of this package"`
+
+	Target string `long:"target" description:"Only export the inputs of this fuzz target of the package"`
+
+	Out string `long:"out" description:"Directory to write the inputs to, as <out>/<package>/testdata/fuzz/<target>/<input>" required:"true"`
+}
+
+// commandFunc runs a command given on the command line.
+type commandFunc func(ctx context.Context, logger *slog.Logger,
+	cfg *Config) error
+
+// commands maps the names of the commands, including the names of their
+// parent commands, to their implementation.
+var commands = map[string]commandFunc{
+	"corpus minimize": runCorpusMinimize,
+	"corpus import":   runCorpusImport,
+	"corpus export":   runCorpusExport,
+	"corpus stats":    runCorpusStats,
+}
+
+// newConfigParser returns the parser populating cfg from the command line and
+// the environment. A command is optional; without one, the fuzzing daemon
+// runs.
+func newConfigParser(cfg *Config) *flags.Parser {
+	parser := flags.NewParser(cfg, flags.Default)
+	parser.SubcommandsOptional = true
+
+	return parser
+}
+
+// activeCommand returns the name of the command selected on the command line,
+// including the names of its parent commands, or an empty string if none was.
+func activeCommand(parser *flags.Parser) string {
+	var names []string
+	for cmd := parser.Active; cmd != nil; cmd = cmd.Active {
+		names = append(names, cmd.Name)
+	}
+
+	return strings.Join(names, " ")
+}
+
+// runCommand runs the command selected on the command line.
+func runCommand(ctx context.Context, logger *slog.Logger, cfg *Config) error {
+	run, ok := commands[cfg.command]
+	if !ok {
+		return fmt.Errorf("unknown command %q", cfg.command)
+	}
+
+	return run(ctx, logger, cfg)
+}
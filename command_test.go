@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestActiveCommand verifies that a command is optional on the command line,
+// and that the selected command is named along with its parent commands.
+func TestActiveCommand(t *testing.T) {
+	flags := []string{
+		"--project_src_path=https://example.com/repo.git",
+		"--s3_bucket_name=corpus",
+		"--fuzz_results_path=/tmp/results",
+		"--fuzz_pkgs_path=parser",
+	}
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{args: flags, want: ""},
+		{
+			args: append([]string{"corpus", "minimize"}, flags...),
+			want: "corpus minimize",
+		},
+		{
+			args: append([]string{"corpus", "import", "--dir=seeds",
+				"--package=parser"}, flags...),
+			want: "corpus import",
+		},
+		{
+			args: append([]string{"corpus", "export", "--out=out"},
+				flags...),
+			want: "corpus export",
+		},
+		{
+			args: append([]string{"corpus", "stats"}, flags...),
+			want: "corpus stats",
+		},
+	}
+	for _, tc := range tests {
+		var cfg Config
+		parser := newConfigParser(&cfg)
+		_, err := parser.ParseArgs(tc.args)
+		require.NoError(t, err)
+
+		assert.Equal(t, tc.want, activeCommand(parser))
+		_, ok := commands[tc.want]
+		assert.Equal(t, tc.want != "", ok)
+	}
+
+	// The options of a command are parsed into its struct.
+	var cfg Config
+	_, err := newConfigParser(&cfg).ParseArgs(append([]string{"corpus",
+		"import", "--dir=a", "--dir=b", "--archive=c.zip"}, flags...))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, cfg.Corpus.Import.Dirs)
+	assert.Equal(t, []string{"c.zip"}, cfg.Corpus.Import.Archives)
+
+	// The export command needs an output directory.
+	cfg = Config{}
+	_, err = newConfigParser(&cfg).ParseArgs(append([]string{"corpus",
+		"export"}, flags...))
+	assert.Error(t, err)
+
+	// The corpus command needs a subcommand.
+	cfg = Config{}
+	_, err = newConfigParser(&cfg).ParseArgs(append(flags, "corpus"))
+	assert.Error(t, err)
+}
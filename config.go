@@ -8,8 +8,6 @@ import (
 	"runtime"
 	"strings"
 	"time"
-
-	flags "github.com/jessevdk/go-flags"
 )
 
 const (
@@ -21,6 +19,14 @@ const (
 	// located.
 	TmpCorpusDir = "corpus"
 
+	// TmpBinDir is the temporary directory where the compiled test
+	// binaries are located.
+	TmpBinDir = "bin"
+
+	// TmpArchiveDir is the temporary directory where the corpus inputs
+	// removed by minimization are collected before they are archived.
+	TmpArchiveDir = "archive"
+
 	// Corpus key is the name of the object stored in S3
 	CorpusKey = "corpus.zip"
 )
@@ -42,12 +48,111 @@ type Config struct {
 
 	NumWorkers int `long:"num_workers" description:"Number of concurrent fuzzing workers" env:"NUM_WORKERS" default:"1"`
 
+	MinTargetFuzzTime time.Duration `long:"min_target_fuzz_time" description:"Minimum time each fuzz target is fuzzed; if the targets do not all fit in a cycle at this time, each cycle fuzzes the next ones in rotation" env:"MIN_TARGET_FUZZ_TIME" default:"1s"`
+
+	ChangedTargetBoost float64 `long:"changed_target_boost" description:"Factor by which the fuzz time of targets in packages affected by the commits since the previous cycle exceeds that of the other targets; 1 fuzzes all targets equally long" env:"CHANGED_TARGET_BOOST" default:"2"`
+
+	GitHubRepo string `long:"github_repo" description:"GitHub repository (owner/name) in which to open issues for new unique crashes; issue filing is disabled if empty" env:"GITHUB_REPO"`
+
+	GitHubToken string `long:"github_token" description:"GitHub token used to authenticate issue tracker requests" env:"GITHUB_TOKEN"`
+
+	GitHubAPIURL string `long:"github_api_url" description:"Base URL of the GitHub REST API" env:"GITHUB_API_URL" default:"https://api.github.com"`
+
+	WebhookURL string `long:"webhook_url" description:"URL that crash and cycle event notifications are POSTed to as JSON; webhook notifications are disabled if empty" env:"WEBHOOK_URL"`
+
+	WebhookHeaders []string `long:"webhook_header" description:"Extra HTTP header, in 'Name: value' form, sent with every webhook request; may be repeated" env:"WEBHOOK_HEADERS" env-delim:","`
+
+	WebhookSecret string `long:"webhook_secret" description:"Secret used to sign webhook payloads with HMAC-SHA256 in the X-Fuzz-Signature-256 header; payloads are unsigned if empty" env:"WEBHOOK_SECRET"`
+
+	WebhookMaxRetries int `long:"webhook_max_retries" description:"Number of times a failed webhook delivery is retried" env:"WEBHOOK_MAX_RETRIES" default:"3"`
+
+	WebhookRateLimit int `long:"webhook_rate_limit" description:"Maximum number of webhook notifications sent per minute; 0 disables rate limiting" env:"WEBHOOK_RATE_LIMIT" default:"20"`
+
+	Bisect bool `long:"bisect" description:"Bisect new crashes to the commit that introduced them, by replaying the failing input over the history since the last cycle in which the target did not crash" env:"BISECT"`
+
+	ReplayTimeout time.Duration `long:"replay_timeout" description:"Time a failing input may run when replayed to check whether its crash is fixed or to bisect it, after which it counts as still failing" env:"REPLAY_TIMEOUT" default:"1m"`
+
+	RegressionRepoPath string `long:"regression_repo_path" description:"Local clone of the project in which to create, for every crash, a branch adding its failing input to the testdata of its target, ready to push; cloned if missing" env:"REGRESSION_REPO_PATH"`
+
+	RaceEvery int `long:"race_every" description:"Build fuzz targets with the race detector in every Nth cycle; 0 disables periodic race cycles" env:"RACE_EVERY" default:"0"`
+
+	RaceTargets []string `long:"race_targets" description:"Comma-separated list of fuzz targets, as <package>:<target>, that are always built with the race detector" env:"RACE_TARGETS" env-delim:","`
+
+	GoCacheDir string `long:"go_cache_dir" description:"Persistent Go build cache directory (GOCACHE) shared across cycles; Go's default is used if empty" env:"GO_CACHE_DIR"`
+
+	GoCacheMaxSizeMB int `long:"go_cache_max_size_mb" description:"Size in megabytes above which the least recently used build cache entries are pruned after each cycle; 0 disables pruning" env:"GO_CACHE_MAX_SIZE_MB" default:"0"`
+
+	GoModCacheDir string `long:"go_mod_cache_dir" description:"Persistent Go module cache directory (GOMODCACHE) shared across cycles; Go's default is used if empty" env:"GO_MOD_CACHE_DIR"`
+
+	GoModCacheMaxSizeMB int `long:"go_mod_cache_max_size_mb" description:"Size in megabytes above which the module cache is cleaned after each cycle; 0 disables cleaning" env:"GO_MOD_CACHE_MAX_SIZE_MB" default:"0"`
+
+	GoProxyOff bool `long:"go_proxy_off" description:"Run go commands with GOPROXY=off, so that no modules are downloaded" env:"GO_PROXY_OFF"`
+
+	GoModVendor bool `long:"go_mod_vendor" description:"Run go commands with GOFLAGS=-mod=vendor, building from the project's vendor directory" env:"GO_MOD_VENDOR"`
+
+	Executor string `long:"executor" description:"How fuzz targets are run: 'binary' runs each package's test binary, compiled once per cycle; 'local' runs 'go test -fuzz' for every target" env:"EXECUTOR" default:"binary" choice:"binary" choice:"local"`
+
+	Sandbox bool `long:"sandbox" description:"Run fuzz targets in a Linux sandbox with new user, mount and network namespaces, a read-only file system except for the package's testdata, the corpus and a private temporary directory, and cgroup v2 memory and CPU limits" env:"SANDBOX"`
+
+	SandboxCgroup string `long:"sandbox_cgroup" description:"Delegated cgroup v2 directory under which a cgroup enforcing the resource limits is created for each sandboxed fuzz target" env:"SANDBOX_CGROUP" default:"/sys/fs/cgroup/go-continuous-fuzz"`
+
+	SandboxMemoryMB int `long:"sandbox_memory_mb" description:"Memory limit in megabytes of each sandboxed fuzz target; 0 leaves memory unlimited" env:"SANDBOX_MEMORY_MB" default:"0"`
+
+	SandboxCPUs float64 `long:"sandbox_cpus" description:"Number of CPUs each sandboxed fuzz target may use; 0 leaves CPU unlimited" env:"SANDBOX_CPUS" default:"0"`
+
+	SandboxTargetLimits []string `long:"sandbox_target_limits" description:"Comma-separated list of per-target resource limits, as <package>:<target>:<memory_mb>:<cpus>, overriding the default limits; an empty field keeps the default and 0 lifts it" env:"SANDBOX_TARGET_LIMITS" env-delim:","`
+
+	LogFormat string `long:"log_format" description:"Format of the log output: 'text' for key=value pairs, 'json' for one JSON object per line" env:"LOG_FORMAT" default:"text" choice:"text" choice:"json"`
+
+	LogLevel string `long:"log_level" description:"Minimum level of logged messages" env:"LOG_LEVEL" default:"info" choice:"debug" choice:"info" choice:"warn" choice:"error"`
+
+	LogFile string `long:"log_file" description:"File the logs are written to instead of the standard output" env:"LOG_FILE"`
+
+	LogMaxSizeMB int `long:"log_max_size_mb" description:"Size in megabytes beyond which the log file is rotated; 0 disables rotation" env:"LOG_MAX_SIZE_MB" default:"100"`
+
+	LogMaxBackups int `long:"log_max_backups" description:"Number of rotated log files kept" env:"LOG_MAX_BACKUPS" default:"5"`
+
+	FuzzerOutputLevel string `long:"fuzzer_output_level" description:"Level at which every line of the fuzzer output is logged; 'off' disables logging it" env:"FUZZER_OUTPUT_LEVEL" default:"info" choice:"debug" choice:"info" choice:"warn" choice:"error" choice:"off"`
+
+	HealthStallTimeout time.Duration `long:"health_stall_timeout" description:"Time a step of the scheduler loop, e.g. cloning the repository, may take before /healthz reports the daemon as stuck; the fuzzing step may take the cycle duration on top of it" env:"HEALTH_STALL_TIMEOUT" default:"30m"`
+
+	WatchdogMargin time.Duration `long:"watchdog_margin" description:"Time a fuzz target may overrun its per-target timeout before the watchdog flags it as hung" env:"WATCHDOG_MARGIN" default:"5m"`
+
+	CorpusMinimizeEvery int `long:"corpus_minimize_every" description:"Minimize the corpus at the start of every N-th cycle, archiving the inputs that add no coverage; minimization is disabled if 0" env:"CORPUS_MINIMIZE_EVERY" default:"0"`
+
+	CorpusArchivePrefix string `long:"corpus_archive_prefix" description:"S3 key prefix under which the corpus inputs removed by minimization are archived" env:"CORPUS_ARCHIVE_PREFIX" default:"archive/"`
+
+	Coverage bool `long:"coverage" description:"Measure the coverage of each fuzzed package by the corpus at the end of every cycle, writing coverage profiles and HTML reports to the fuzz results directory" env:"COVERAGE"`
+
+	TraceExporter string `long:"trace_exporter" description:"Exporter of the OpenTelemetry traces of the cycles: 'otlp' sends them to an OTLP/HTTP collector, 'file' writes them as JSON to --trace_file; tracing is disabled if empty" env:"TRACE_EXPORTER" choice:"otlp" choice:"file"`
+
+	TraceEndpoint string `long:"trace_endpoint" description:"URL of the OTLP/HTTP traces endpoint, e.g. 'http://localhost:4318/v1/traces'; defaults to the standard OTEL_EXPORTER_OTLP_* environment variables" env:"TRACE_ENDPOINT"`
+
+	TraceFile string `long:"trace_file" description:"File the traces are written to by the 'file' exporter" env:"TRACE_FILE"`
+
+	HTTPListen string `long:"http_listen" description:"Address, e.g. ':9090', of the HTTP listener serving Prometheus metrics at /metrics, the status API at /api and the dashboard at /; the listener is disabled if empty" env:"HTTP_LISTEN"`
+
 	// ProjectDir contains the absolute path to the directory where the
 	// project is located.
 	ProjectDir string
 
 	// Absolute path to corpus directory
 	CorpusDir string
+
+	// BinDir contains the absolute path to the directory where the test
+	// binaries of the fuzzed packages are compiled to.
+	BinDir string
+
+	// ArchiveDir contains the absolute path to the directory where the
+	// corpus inputs removed by minimization are collected.
+	ArchiveDir string
+
+	// Corpus holds the commands managing the stored corpus.
+	Corpus corpusCommand `command:"corpus" description:"Manage the corpus stored in the S3 bucket"`
+
+	// command is the command selected on the command line, e.g. "corpus
+	// minimize", or empty if the fuzzing daemon runs.
+	command string
 }
 
 // loadConfig parses configuration from environment variables and command-line
@@ -57,9 +162,11 @@ func loadConfig() (*Config, error) {
 	var cfg Config
 
 	// Parse configuration, populating the cfg struct.
-	if _, err := flags.Parse(&cfg); err != nil {
+	parser := newConfigParser(&cfg)
+	if _, err := parser.Parse(); err != nil {
 		return nil, err
 	}
+	cfg.command = activeCommand(parser)
 
 	// Validate the number of workers to ensure it is within the allowed
 	// range.
@@ -70,10 +177,113 @@ func loadConfig() (*Config, error) {
 			runtime.NumCPU())
 	}
 
+	// Validate the issue tracker settings, if issue filing is enabled.
+	if cfg.GitHubRepo != "" {
+		owner, name, ok := strings.Cut(cfg.GitHubRepo, "/")
+		if !ok || owner == "" || name == "" || strings.Contains(name,
+			"/") {
+
+			return nil, fmt.Errorf("invalid GitHub repository %q, "+
+				"expected owner/name", cfg.GitHubRepo)
+		}
+		if cfg.GitHubToken == "" {
+			return nil, fmt.Errorf("a GitHub token is required " +
+				"when github_repo is set")
+		}
+	}
+
+	// Validate the webhook settings.
+	for _, header := range cfg.WebhookHeaders {
+		name, _, ok := strings.Cut(header, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid webhook header %q, "+
+				"expected 'Name: value'", header)
+		}
+	}
+	if cfg.WebhookMaxRetries < 0 || cfg.WebhookRateLimit < 0 {
+		return nil, fmt.Errorf("webhook retries and rate limit must " +
+			"not be negative")
+	}
+
+	// Validate the fuzz time settings.
+	if cfg.MinTargetFuzzTime < time.Second {
+		return nil, fmt.Errorf("invalid minimum target fuzz time %v, "+
+			"must be at least 1s", cfg.MinTargetFuzzTime)
+	}
+	if cfg.ChangedTargetBoost < 1 {
+		return nil, fmt.Errorf("invalid changed target boost %v, must "+
+			"be at least 1", cfg.ChangedTargetBoost)
+	}
+
+	// Validate the race detector settings.
+	if cfg.RaceEvery < 0 {
+		return nil, fmt.Errorf("invalid race cycle interval: %d",
+			cfg.RaceEvery)
+	}
+	for _, target := range cfg.RaceTargets {
+		pkg, name, ok := strings.Cut(target, ":")
+		if !ok || pkg == "" || name == "" {
+			return nil, fmt.Errorf("invalid race target %q, "+
+				"expected <package>:<target>", target)
+		}
+	}
+
+	// Validate the Go cache settings.
+	if cfg.GoCacheMaxSizeMB < 0 || cfg.GoModCacheMaxSizeMB < 0 {
+		return nil, fmt.Errorf("Go cache size limits must not be " +
+			"negative")
+	}
+
+	// Validate the sandbox settings.
+	if cfg.Sandbox && runtime.GOOS != "linux" {
+		return nil, fmt.Errorf("sandboxing is only supported on Linux")
+	}
+	if cfg.SandboxMemoryMB < 0 || cfg.SandboxCPUs < 0 {
+		return nil, fmt.Errorf("sandbox limits must not be negative")
+	}
+	for _, limit := range cfg.SandboxTargetLimits {
+		if _, _, err := parseSandboxTargetLimit(limit); err != nil {
+			return nil, err
+		}
+	}
+
+	// Validate the corpus settings.
+	if cfg.CorpusMinimizeEvery < 0 {
+		return nil, fmt.Errorf("invalid corpus minimization "+
+			"interval: %d", cfg.CorpusMinimizeEvery)
+	}
+
+	// Validate the log settings.
+	if cfg.LogMaxSizeMB < 0 || cfg.LogMaxBackups < 0 {
+		return nil, fmt.Errorf("log size limit and backups must not " +
+			"be negative")
+	}
+
+	// Validate the tracing settings.
+	if cfg.TraceExporter == traceExporterFile && cfg.TraceFile == "" {
+		return nil, fmt.Errorf("--trace_file is required by the " +
+			"file trace exporter")
+	}
+
+	if cfg.ReplayTimeout <= 0 {
+		return nil, fmt.Errorf("replay timeout must be positive")
+	}
+
+	// Validate the health check settings.
+	if cfg.HealthStallTimeout <= 0 || cfg.WatchdogMargin <= 0 {
+		return nil, fmt.Errorf("health stall timeout and watchdog " +
+			"margin must be positive")
+	}
+
 	// As soon as we're done parsing configuration options, ensure all paths
 	// to directories and files are cleaned and expanded before attempting
 	// to use them later on.
 	cfg.FuzzResultsPath = CleanAndExpandPath(cfg.FuzzResultsPath)
+	cfg.LogFile = CleanAndExpandPath(cfg.LogFile)
+	cfg.TraceFile = CleanAndExpandPath(cfg.TraceFile)
+	cfg.GoCacheDir = CleanAndExpandPath(cfg.GoCacheDir)
+	cfg.GoModCacheDir = CleanAndExpandPath(cfg.GoModCacheDir)
+	cfg.RegressionRepoPath = CleanAndExpandPath(cfg.RegressionRepoPath)
 
 	// Set the absolute path to the temp project directory.
 	tmpDirPath, err := os.MkdirTemp("", "go-continuous-fuzz-")
@@ -82,6 +292,8 @@ func loadConfig() (*Config, error) {
 	}
 	cfg.ProjectDir = filepath.Join(tmpDirPath, TmpProjectDir)
 	cfg.CorpusDir = filepath.Join(tmpDirPath, TmpCorpusDir)
+	cfg.BinDir = filepath.Join(tmpDirPath, TmpBinDir)
+	cfg.ArchiveDir = filepath.Join(tmpDirPath, TmpArchiveDir)
 
 	return &cfg, nil
 }
@@ -0,0 +1,579 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/go-continuous-fuzz/go-continuous-fuzz/fuzzinput"
+)
+
+// fetchCorpus downloads the stored corpus and extracts it into
+// cfg.CorpusDir. A missing corpus yields an empty corpus directory.
+func fetchCorpus(ctx context.Context, logger *slog.Logger, cfg *Config,
+	s3Client *s3.Client, m *fuzzMetrics) error {
+
+	corpusZipPath := cfg.CorpusDir + ".zip"
+	empty, err := downloadObject(ctx, s3Client, cfg.S3BucketName,
+		CorpusKey, corpusZipPath, logger, m)
+	if err != nil {
+		return err
+	}
+
+	if empty {
+		return EnsureDirExists(cfg.CorpusDir)
+	}
+	if err := unzip(corpusZipPath, cfg.CorpusDir, logger); err != nil {
+		return fmt.Errorf("extracting corpus: %w", err)
+	}
+	_, err = removeInvalidInputs(logger, cfg.CorpusDir)
+
+	return err
+}
+
+// removeInvalidInputs removes the inputs of the corpus in corpusDir that are
+// not in the "go test fuzz v1" encoding, since the fuzzer fails to load the
+// corpus of a target holding one. It returns the number of inputs removed.
+func removeInvalidInputs(logger *slog.Logger, corpusDir string) (int, error) {
+	removed := 0
+	err := filepath.WalkDir(corpusDir, func(p string, d os.DirEntry,
+		err error) error {
+
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(corpusDir, p)
+		if err != nil {
+			return err
+		}
+		if _, _, ok := corpusEntryTarget(filepath.ToSlash(rel)); !ok {
+			return nil
+		}
+
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return fmt.Errorf("reading corpus input: %w", err)
+		}
+		err = fuzzinput.Validate(data)
+		if err == nil {
+			return nil
+		}
+
+		logger.Warn("Removing invalid corpus input", "path", rel,
+			"error", err)
+		if err := os.Remove(p); err != nil {
+			return fmt.Errorf("removing corpus input: %w", err)
+		}
+		removed++
+
+		return nil
+	})
+	if err != nil {
+		return removed, fmt.Errorf("validating corpus: %w", err)
+	}
+
+	return removed, nil
+}
+
+// openStoredCorpus downloads the stored corpus and opens it without
+// extracting it. It returns nil if no corpus is stored.
+func openStoredCorpus(ctx context.Context, logger *slog.Logger,
+	cfg *Config) (*zip.ReadCloser, error) {
+
+	s3Client, err := createS3Client(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("creating S3 client: %w", err)
+	}
+	if err := EnsureDirExists(filepath.Dir(cfg.CorpusDir)); err != nil {
+		return nil, err
+	}
+
+	m := newFuzzMetrics(cfg.NumWorkers)
+	corpusZipPath := cfg.CorpusDir + ".zip"
+	empty, err := downloadObject(ctx, s3Client, cfg.S3BucketName,
+		CorpusKey, corpusZipPath, logger, m)
+	if err != nil || empty {
+		return nil, err
+	}
+
+	r, err := zip.OpenReader(corpusZipPath)
+	if err != nil {
+		return nil, fmt.Errorf("opening corpus: %w", err)
+	}
+
+	return r, nil
+}
+
+// corpusEntryTarget returns the package and fuzz target of the input at the
+// slash separated path name of the corpus, which is
+// "<pkg>/testdata/fuzz/<target>/<input>".
+func corpusEntryTarget(name string) (string, string, bool) {
+	before, target, ok := strings.Cut("/"+path.Dir(name),
+		"/testdata/fuzz/")
+	if !ok || strings.Contains(target, "/") {
+		return "", "", false
+	}
+
+	pkg := strings.TrimPrefix(before, "/")
+	if pkg == "" {
+		pkg = "."
+	}
+
+	return pkg, target, true
+}
+
+// syncAndListTargets clones the project repository and lists the fuzz
+// targets of the configured packages, for the commands that need the
+// project's code.
+func syncAndListTargets(ctx context.Context, logger *slog.Logger,
+	cfg *Config) (map[string][]string, error) {
+
+	logger.Info("Syncing project repository", "repo_url",
+		SanitizeURL(cfg.ProjectSrcPath), "local_path", cfg.ProjectDir)
+	if _, err := cloneRepository(ctx, cfg, cfg.ProjectDir, 1); err != nil {
+		return nil, fmt.Errorf("syncing repository: %w", err)
+	}
+
+	pkgTargets, _, _, err := listPkgsFuzzTargets(ctx, logger, cfg,
+		newTestBinaries(cfg))
+	if err != nil {
+		return nil, err
+	}
+
+	return pkgTargets, nil
+}
+
+// fuzzTargetRegex matches the names of fuzz targets.
+var fuzzTargetRegex = regexp.MustCompile(`^Fuzz[\pL\pN_]*$`)
+
+// corpusImport merges inputs into the corpus in a directory, skipping the
+// inputs the corpus already holds.
+type corpusImport struct {
+	logger *slog.Logger
+
+	// corpusDir is the directory of the corpus.
+	corpusDir string
+
+	// pkg and target are the fuzz target inputs are imported for, unless
+	// their path tells otherwise.
+	pkg    string
+	target string
+
+	// fromRepo is set while importing the seed corpora of the
+	// repository, whose paths alone tell the fuzz target of their inputs.
+	fromRepo bool
+
+	// hashes maps the corpus directory of each target inputs were
+	// imported for to the content hashes of its inputs.
+	hashes map[string]map[string]bool
+
+	// imported, duplicates, invalid and skipped count the inputs that
+	// were imported, already in the corpus, not validly encoded, or not
+	// attributable to a fuzz target.
+	imported   int
+	duplicates int
+	invalid    int
+	skipped    int
+}
+
+// newCorpusImport returns an import into the corpus in corpusDir. Inputs
+// whose path does not tell their fuzz target are imported for the target of
+// the package pkg, if set.
+func newCorpusImport(logger *slog.Logger, corpusDir, pkg,
+	target string) *corpusImport {
+
+	return &corpusImport{
+		logger:    logger,
+		corpusDir: corpusDir,
+		pkg:       pkg,
+		target:    target,
+		hashes:    make(map[string]map[string]bool),
+	}
+}
+
+// inputTarget returns the package and fuzz target of the input at the slash
+// separated path rel. Inputs in a "<pkg>/testdata/fuzz/<target>" directory
+// belong to that target, and those in a top-level "testdata/fuzz/<target>"
+// directory to the configured package, or else to the root package ".". The
+// seed corpora of the repository only belong to the package they are in.
+// Otherwise, the configured target is used, or, if only the package is
+// configured, the input must be in a "<target>" directory as in Go's fuzzing
+// cache.
+func (ci *corpusImport) inputTarget(rel string) (string, string, bool) {
+	dir := path.Dir(rel)
+	elems := strings.Split(dir, "/")
+	if n := len(elems); n >= 3 && elems[n-3] == "testdata" &&
+		elems[n-2] == "fuzz" {
+
+		pkg := path.Join(elems[:n-3]...)
+		if pkg == "" && !ci.fromRepo {
+			pkg = ci.pkg
+		}
+		if pkg == "" {
+			pkg = "."
+		}
+		return pkg, elems[n-1], true
+	}
+
+	switch {
+	case ci.pkg == "" || ci.fromRepo:
+		return "", "", false
+
+	case ci.target != "":
+		return ci.pkg, ci.target, true
+
+	case strings.HasPrefix(path.Base(dir), "Fuzz"):
+		return ci.pkg, path.Base(dir), true
+
+	default:
+		return "", "", false
+	}
+}
+
+// add imports the input read from the slash separated path rel of source.
+func (ci *corpusImport) add(source, rel string, data []byte) error {
+	pkg, target, ok := ci.inputTarget(rel)
+	if ok {
+		pkg = path.Clean(pkg)
+		ok = fuzzTargetRegex.MatchString(target) && pkg != ".." &&
+			!strings.HasPrefix(pkg, "../") && !path.IsAbs(pkg)
+	}
+	if !ok {
+		ci.skipped++
+		ci.logger.Debug("Skipping input of unknown fuzz target",
+			"source", source, "path", rel)
+		return nil
+	}
+
+	if err := fuzzinput.Validate(data); err != nil {
+		ci.invalid++
+		ci.logger.Warn("Skipping invalid corpus input", "source",
+			source, "path", rel, "error", err)
+		return nil
+	}
+
+	dir := filepath.Join(ci.corpusDir, filepath.FromSlash(pkg),
+		"testdata", "fuzz", target)
+	hashes, err := ci.targetHashes(dir)
+	if err != nil {
+		return err
+	}
+
+	// Name the input after its hash, as the fuzzer does.
+	hash := fmt.Sprintf("%x", sha256.Sum256(data))
+	if hashes[hash] {
+		ci.duplicates++
+		return nil
+	}
+	if err := EnsureDirExists(dir); err != nil {
+		return err
+	}
+	err = os.WriteFile(filepath.Join(dir, hash[:16]), data, 0644)
+	if err != nil {
+		return fmt.Errorf("writing corpus input: %w", err)
+	}
+	hashes[hash] = true
+	ci.imported++
+
+	return nil
+}
+
+// targetHashes returns the content hashes of the inputs in the corpus
+// directory of a target, hashing them on first use.
+func (ci *corpusImport) targetHashes(dir string) (map[string]bool, error) {
+	if hashes, ok := ci.hashes[dir]; ok {
+		return hashes, nil
+	}
+
+	hashes := make(map[string]bool)
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("reading corpus: %w", err)
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("reading corpus input: %w",
+				err)
+		}
+		hashes[fmt.Sprintf("%x", sha256.Sum256(data))] = true
+	}
+	ci.hashes[dir] = hashes
+
+	return hashes, nil
+}
+
+// addDir imports the inputs in the directory tree at dir, whose paths are
+// taken relative to base.
+func (ci *corpusImport) addDir(dir, base string) error {
+	return filepath.WalkDir(dir, func(p string, d os.DirEntry,
+		err error) error {
+
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+
+		rel, err := filepath.Rel(base, p)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return fmt.Errorf("reading input: %w", err)
+		}
+
+		return ci.add(dir, filepath.ToSlash(rel), data)
+	})
+}
+
+// addRepoDir imports the seed corpora in the directory tree at dir of the
+// repository cloned at base.
+func (ci *corpusImport) addRepoDir(dir, base string) error {
+	ci.fromRepo = true
+	defer func() { ci.fromRepo = false }()
+
+	return ci.addDir(dir, base)
+}
+
+// addArchive imports the inputs in a zip or tar file, which may be gzip
+// compressed. The archive is read directly rather than extracted.
+func (ci *corpusImport) addArchive(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("reading archive: %w", err)
+	}
+
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		r, err := zip.NewReader(bytes.NewReader(data),
+			int64(len(data)))
+		if err != nil {
+			return fmt.Errorf("opening zip %q: %w", file, err)
+		}
+		for _, f := range r.File {
+			if !f.Mode().IsRegular() {
+				continue
+			}
+			input, err := readZipFile(f)
+			if err != nil {
+				return fmt.Errorf("reading %q from %q: %w",
+					f.Name, file, err)
+			}
+			if err := ci.add(file, f.Name, input); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var r io.Reader = bytes.NewReader(data)
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("opening gzip %q: %w", file, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading tar %q: %w", file, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		input, err := io.ReadAll(tr)
+		if err != nil {
+			return fmt.Errorf("reading %q from %q: %w", hdr.Name,
+				file, err)
+		}
+		if err := ci.add(file, hdr.Name, input); err != nil {
+			return err
+		}
+	}
+}
+
+// readZipFile returns the content of a file in a zip archive.
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	return io.ReadAll(rc)
+}
+
+// runCorpusImport implements the "corpus import" command: it merges inputs
+// from the repository's seed corpora, local directories and archives into the
+// stored corpus.
+func runCorpusImport(ctx context.Context, logger *slog.Logger,
+	cfg *Config) error {
+
+	opts := &cfg.Corpus.Import
+	if !opts.RepoTestdata && len(opts.Dirs) == 0 &&
+		len(opts.Archives) == 0 {
+
+		return fmt.Errorf("nothing to import: set --repo_testdata, " +
+			"--dir or --archive")
+	}
+	if opts.Target != "" && opts.Package == "" {
+		return fmt.Errorf("--target requires --package")
+	}
+
+	s3Client, err := createS3Client(ctx)
+	if err != nil {
+		return fmt.Errorf("creating S3 client: %w", err)
+	}
+	m := newFuzzMetrics(cfg.NumWorkers)
+	if err := fetchCorpus(ctx, logger, cfg, s3Client, m); err != nil {
+		return err
+	}
+
+	ci := newCorpusImport(logger, cfg.CorpusDir, opts.Package, opts.Target)
+	if opts.RepoTestdata {
+		logger.Info("Syncing project repository", "repo_url",
+			SanitizeURL(cfg.ProjectSrcPath))
+		_, err := cloneRepository(ctx, cfg, cfg.ProjectDir, 1)
+		if err != nil {
+			return fmt.Errorf("syncing repository: %w", err)
+		}
+
+		// Only the seed corpora of the fuzzed packages are imported,
+		// as those of other packages would never be fuzzed.
+		for _, pkg := range cfg.FuzzPkgsPath {
+			dir := filepath.Join(cfg.ProjectDir, pkg, "testdata",
+				"fuzz")
+			err := ci.addRepoDir(dir, cfg.ProjectDir)
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	for _, dir := range opts.Dirs {
+		dir = CleanAndExpandPath(dir)
+		if err := ci.addDir(dir, dir); err != nil {
+			return err
+		}
+	}
+	for _, file := range opts.Archives {
+		if err := ci.addArchive(CleanAndExpandPath(file)); err != nil {
+			return err
+		}
+	}
+
+	logger.Info("Imported corpus inputs", "imported", ci.imported,
+		"duplicates", ci.duplicates, "invalid", ci.invalid,
+		"skipped", ci.skipped)
+	if ci.imported == 0 {
+		return nil
+	}
+
+	return zipUploadCorpus(ctx, s3Client, cfg.S3BucketName, CorpusKey,
+		cfg.CorpusDir, logger, m)
+}
+
+// exportCorpus writes the inputs of the corpus archive r into outDir, keeping
+// the corpus layout and the modification times of the inputs. If pkg is set,
+// only the inputs of its fuzz targets are written, and if target is set too,
+// only those of that target. It returns the number of inputs written.
+func exportCorpus(r *zip.Reader, outDir, pkg, target string) (int, error) {
+	exported := 0
+	for _, f := range r.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		entryPkg, entryTarget, ok := corpusEntryTarget(f.Name)
+		if !ok || (pkg != "" && entryPkg != path.Clean(pkg)) ||
+			(target != "" && entryTarget != target) {
+
+			continue
+		}
+		if !filepath.IsLocal(filepath.FromSlash(f.Name)) {
+			return exported, fmt.Errorf("invalid corpus path %q",
+				f.Name)
+		}
+
+		data, err := readZipFile(f)
+		if err != nil {
+			return exported, fmt.Errorf("reading %q: %w", f.Name,
+				err)
+		}
+		dst := filepath.Join(outDir, filepath.FromSlash(f.Name))
+		if err := EnsureDirExists(filepath.Dir(dst)); err != nil {
+			return exported, err
+		}
+		if err := os.WriteFile(dst, data, 0644); err != nil {
+			return exported, fmt.Errorf("writing input: %w", err)
+		}
+		if err := os.Chtimes(dst, f.Modified, f.Modified); err != nil {
+			return exported, fmt.Errorf("setting input time: %w",
+				err)
+		}
+		exported++
+	}
+
+	return exported, nil
+}
+
+// runCorpusExport implements the "corpus export" command: it writes the
+// inputs of the stored corpus, or of one package or fuzz target, into a
+// directory.
+func runCorpusExport(ctx context.Context, logger *slog.Logger,
+	cfg *Config) error {
+
+	opts := &cfg.Corpus.Export
+	if opts.Target != "" && opts.Package == "" {
+		return fmt.Errorf("--target requires --package")
+	}
+
+	r, err := openStoredCorpus(ctx, logger, cfg)
+	if err != nil {
+		return err
+	}
+	if r == nil {
+		return fmt.Errorf("no corpus stored in bucket %q",
+			cfg.S3BucketName)
+	}
+	defer r.Close()
+
+	outDir := CleanAndExpandPath(opts.Out)
+	exported, err := exportCorpus(&r.Reader, outDir, opts.Package,
+		opts.Target)
+	if err != nil {
+		return err
+	}
+	if exported == 0 && opts.Package != "" {
+		what := fmt.Sprintf("package %q", opts.Package)
+		if opts.Target != "" {
+			what += fmt.Sprintf(", target %q", opts.Target)
+		}
+		return fmt.Errorf("no inputs stored for %s", what)
+	}
+
+	logger.Info("Exported corpus inputs", "inputs", exported, "out",
+		outDir)
+
+	return nil
+}
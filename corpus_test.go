@@ -0,0 +1,215 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRemoveInvalidInputs verifies that the corpus inputs that are not in the
+// "go test fuzz v1" encoding are removed, leaving other files alone.
+func TestRemoveInvalidInputs(t *testing.T) {
+	corpusDir := t.TempDir()
+	writeCorpusInput(t, corpusDir, "parser", "FuzzParse", "valid", "ok")
+	dir := filepath.Join(corpusDir, "parser", "testdata", "fuzz",
+		"FuzzParse")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "invalid"),
+		[]byte("go test fuzz v1\nstring(\"a\"\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(corpusDir, "parser",
+		"notes.txt"), []byte("not an input"), 0644))
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	removed, err := removeInvalidInputs(logger, corpusDir)
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+
+	assert.NoFileExists(t, filepath.Join(dir, "invalid"))
+	assert.FileExists(t, filepath.Join(dir, "valid"))
+	assert.FileExists(t, filepath.Join(corpusDir, "parser", "notes.txt"))
+}
+
+// corpusFiles returns the contents of the corpus inputs of a target, sorted.
+func corpusFiles(t *testing.T, corpusDir, pkg, target string) []string {
+	t.Helper()
+
+	dir := filepath.Join(corpusDir, pkg, "testdata", "fuzz", target)
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+
+	var contents []string
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		require.NoError(t, err)
+		contents = append(contents, string(data))
+	}
+	sort.Strings(contents)
+
+	return contents
+}
+
+// TestCorpusImport verifies that inputs are imported from directories and
+// archives into the corpus of the fuzz target their path tells, skipping
+// duplicates, invalid inputs and inputs of unknown targets.
+func TestCorpusImport(t *testing.T) {
+	const (
+		existing = "go test fuzz v1\nstring(\"existing\")\n"
+		first    = "go test fuzz v1\nstring(\"first\")\n"
+		second   = "go test fuzz v1\nstring(\"second\")\n"
+		third    = "go test fuzz v1\nint(3)\n"
+		invalid  = "not a corpus entry"
+	)
+
+	corpusDir := t.TempDir()
+	writeCorpusInput(t, corpusDir, "parser", "FuzzParse", "0123",
+		"existing")
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ci := newCorpusImport(logger, corpusDir, "", "")
+
+	// A directory in the corpus layout.
+	src := t.TempDir()
+	write := func(rel, content string) {
+		path := filepath.Join(src, rel)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	write("parser/testdata/fuzz/FuzzParse/a", existing)
+	write("parser/testdata/fuzz/FuzzParse/b", first)
+	write("parser/testdata/fuzz/FuzzParse/c", first)
+	write("parser/testdata/fuzz/FuzzParse/d", invalid)
+	write("parser/README", "unknown target")
+	require.NoError(t, ci.addDir(src, src))
+
+	// A gzip-compressed tar file of a fuzzing cache directory, for a
+	// package given on the command line.
+	var tarBuf bytes.Buffer
+	gz := gzip.NewWriter(&tarBuf)
+	tw := tar.NewWriter(gz)
+	for name, content := range map[string]string{
+		"FuzzParse/x": second,
+		"FuzzEval/y":  third,
+	} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(content)),
+			Typeflag: tar.TypeReg,
+		}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	tarPath := filepath.Join(t.TempDir(), "corpus.tar.gz")
+	require.NoError(t, os.WriteFile(tarPath, tarBuf.Bytes(), 0644))
+
+	ci.pkg = "parser"
+	require.NoError(t, ci.addArchive(tarPath))
+
+	// A zip file of inputs for a single target.
+	var zipBuf bytes.Buffer
+	zw := zip.NewWriter(&zipBuf)
+	w, err := zw.Create("inputs/z")
+	require.NoError(t, err)
+	_, err = w.Write([]byte(second))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	zipPath := filepath.Join(t.TempDir(), "corpus.zip")
+	require.NoError(t, os.WriteFile(zipPath, zipBuf.Bytes(), 0644))
+
+	ci.pkg, ci.target = "lexer", "FuzzLex"
+	require.NoError(t, ci.addArchive(zipPath))
+
+	assert.Equal(t, 4, ci.imported)
+	assert.Equal(t, 2, ci.duplicates)
+	assert.Equal(t, 1, ci.invalid)
+	assert.Equal(t, 1, ci.skipped)
+
+	assert.Equal(t, []string{existing, first, second},
+		corpusFiles(t, corpusDir, "parser", "FuzzParse"))
+	assert.Equal(t, []string{third},
+		corpusFiles(t, corpusDir, "parser", "FuzzEval"))
+	assert.Equal(t, []string{second},
+		corpusFiles(t, corpusDir, "lexer", "FuzzLex"))
+}
+
+// TestCorpusImportTarget verifies that the target of an input is taken from
+// its path, matching testdata/fuzz directories by path element, that seeds
+// of the root package belong to package ".", and that paths escaping the
+// corpus are rejected.
+func TestCorpusImportTarget(t *testing.T) {
+	tests := []struct {
+		pkg, target string
+		rel         string
+		wantPkg     string
+		wantTarget  string
+		ok          bool
+	}{
+		{rel: "a/b/testdata/fuzz/FuzzX/in", wantPkg: "a/b",
+			wantTarget: "FuzzX", ok: true},
+		{pkg: "p", rel: "testdata/fuzz/FuzzX/in", wantPkg: "p",
+			wantTarget: "FuzzX", ok: true},
+		{rel: "testdata/fuzz/FuzzX/in", wantPkg: ".",
+			wantTarget: "FuzzX", ok: true},
+		{rel: "a/xtestdata/fuzz/FuzzX/in"},
+		{rel: "a/testdata/fuzzy/FuzzX/in"},
+		{pkg: "p", rel: "a/xtestdata/fuzz/FuzzX/in", wantPkg: "p",
+			wantTarget: "FuzzX", ok: true},
+		{rel: "a/testdata/fuzz/FuzzX/sub/in"},
+		{pkg: "p", rel: "FuzzY/in", wantPkg: "p", wantTarget: "FuzzY",
+			ok: true},
+		{pkg: "p", target: "FuzzZ", rel: "any/in", wantPkg: "p",
+			wantTarget: "FuzzZ", ok: true},
+		{pkg: "p", rel: "other/in"},
+		{rel: "FuzzY/in"},
+	}
+	for _, tc := range tests {
+		ci := newCorpusImport(nil, "", tc.pkg, tc.target)
+		pkg, target, ok := ci.inputTarget(tc.rel)
+		assert.Equal(t, tc.ok, ok, tc.rel)
+		if ok {
+			assert.Equal(t, tc.wantPkg, pkg, tc.rel)
+			assert.Equal(t, tc.wantTarget, target, tc.rel)
+		}
+	}
+
+	// The seed corpora of the repository belong to the package they are
+	// in, regardless of the configured package.
+	repoDir := t.TempDir()
+	writeFiles(t, repoDir, map[string]string{
+		"testdata/fuzz/FuzzRoot/in":       "go test fuzz v1\nint(1)\n",
+		"lexer/testdata/fuzz/FuzzLex/in":  "go test fuzz v1\nint(2)\n",
+		"lexer/testdata/fuzz/FuzzLex/x/y": "go test fuzz v1\nint(3)\n",
+	})
+	repoCorpusDir := t.TempDir()
+	repoImport := newCorpusImport(slog.New(slog.NewTextHandler(
+		io.Discard, nil)), repoCorpusDir, "parser", "")
+	for _, pkg := range []string{".", "lexer"} {
+		dir := filepath.Join(repoDir, pkg, "testdata", "fuzz")
+		require.NoError(t, repoImport.addRepoDir(dir, repoDir))
+	}
+	assert.Equal(t, 2, repoImport.imported)
+	assert.Equal(t, 1, repoImport.skipped)
+	assert.Len(t, corpusFiles(t, repoCorpusDir, ".", "FuzzRoot"), 1)
+	assert.Len(t, corpusFiles(t, repoCorpusDir, "lexer", "FuzzLex"), 1)
+	assert.False(t, repoImport.fromRepo)
+
+	// Inputs of packages outside the corpus are skipped.
+	corpusDir := t.TempDir()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ci := newCorpusImport(logger, corpusDir, "", "")
+	require.NoError(t, ci.add("src", "../../testdata/fuzz/FuzzX/in",
+		[]byte("go test fuzz v1\nint(1)\n")))
+	assert.Equal(t, 1, ci.skipped)
+	assert.Zero(t, ci.imported)
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/go-continuous-fuzz/go-continuous-fuzz/fuzzinput"
+)
+
+// invalidSignature is the signature counted for inputs that are not in the
+// "go test fuzz v1" encoding.
+const invalidSignature = "invalid"
+
+// targetCorpusStats describes the stored corpus of a fuzz target.
+type targetCorpusStats struct {
+	Package string
+	Target  string
+
+	// Inputs is the number of inputs and Bytes their total size.
+	Inputs int
+	Bytes  int64
+
+	// Sizes are the sizes of the inputs, in ascending order.
+	Sizes []int64
+
+	// Newest is the modification time of the newest input.
+	Newest time.Time
+
+	// Signatures counts the inputs by the types of their values, e.g.
+	// "(string, int64)".
+	Signatures map[string]int
+}
+
+// percentileSize returns the size that p percent of the inputs do not
+// exceed.
+func (s *targetCorpusStats) percentileSize(p int) int64 {
+	if len(s.Sizes) == 0 {
+		return 0
+	}
+
+	return s.Sizes[(len(s.Sizes)-1)*p/100]
+}
+
+// corpusStats returns the statistics of the corpus of every fuzz target in the
+// corpus archive r, sorted by package and target.
+func corpusStats(r *zip.Reader) ([]*targetCorpusStats, error) {
+	byTarget := make(map[string]*targetCorpusStats)
+	for _, f := range r.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		pkg, target, ok := corpusEntryTarget(f.Name)
+		if !ok {
+			continue
+		}
+
+		key := pkg + "/" + target
+		s, ok := byTarget[key]
+		if !ok {
+			s = &targetCorpusStats{
+				Package:    pkg,
+				Target:     target,
+				Signatures: make(map[string]int),
+			}
+			byTarget[key] = s
+		}
+
+		data, err := readZipFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("reading %q: %w", f.Name, err)
+		}
+
+		s.Inputs++
+		s.Bytes += int64(len(data))
+		s.Sizes = append(s.Sizes, int64(len(data)))
+		if f.Modified.After(s.Newest) {
+			s.Newest = f.Modified
+		}
+
+		signature := invalidSignature
+		if vals, err := fuzzinput.Unmarshal(data); err == nil {
+			signature = fuzzinput.Signature(vals)
+		}
+		s.Signatures[signature]++
+	}
+
+	stats := make([]*targetCorpusStats, 0, len(byTarget))
+	for _, s := range byTarget {
+		sort.Slice(s.Sizes, func(i, j int) bool {
+			return s.Sizes[i] < s.Sizes[j]
+		})
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Package != stats[j].Package {
+			return stats[i].Package < stats[j].Package
+		}
+		return stats[i].Target < stats[j].Target
+	})
+
+	return stats, nil
+}
+
+// formatSignatures returns the signatures of a target's inputs, the most
+// common first, with the number of inputs having each.
+func formatSignatures(signatures map[string]int) string {
+	names := make([]string, 0, len(signatures))
+	for name := range signatures {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if signatures[names[i]] != signatures[names[j]] {
+			return signatures[names[i]] > signatures[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s: %d", name, signatures[name])
+	}
+
+	return strings.Join(parts, "; ")
+}
+
+// writeCorpusStats writes the statistics of the corpus as a table, with the
+// age of the newest inputs relative to now.
+func writeCorpusStats(w io.Writer, stats []*targetCorpusStats,
+	now time.Time) error {
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "PACKAGE\tTARGET\tINPUTS\tBYTES\tMIN\tP50\tP90\tMAX\t"+
+		"NEWEST\tSIGNATURES")
+	for _, s := range stats {
+		age := "-"
+		if !s.Newest.IsZero() {
+			age = now.Sub(s.Newest).Truncate(time.Second).String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%d\t%d\t%d\t%s\t%s\n",
+			s.Package, s.Target, s.Inputs, s.Bytes,
+			s.percentileSize(0), s.percentileSize(50),
+			s.percentileSize(90), s.percentileSize(100), age,
+			formatSignatures(s.Signatures))
+	}
+
+	return tw.Flush()
+}
+
+// runCorpusStats implements the "corpus stats" command: it prints the
+// statistics of the corpus of every fuzz target in the stored corpus.
+func runCorpusStats(ctx context.Context, logger *slog.Logger,
+	cfg *Config) error {
+
+	r, err := openStoredCorpus(ctx, logger, cfg)
+	if err != nil {
+		return err
+	}
+
+	var stats []*targetCorpusStats
+	if r != nil {
+		defer r.Close()
+
+		stats, err = corpusStats(&r.Reader)
+		if err != nil {
+			return err
+		}
+	}
+
+	return writeCorpusStats(os.Stdout, stats, time.Now())
+}
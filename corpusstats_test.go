@@ -0,0 +1,150 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// corpusArchive returns a corpus archive holding the given inputs, keyed by
+// path, all modified at the given time except the newest one.
+func corpusArchive(t *testing.T, inputs map[string]string, modified,
+	newest time.Time, newestPath string) *zip.Reader {
+
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range inputs {
+		header := &zip.FileHeader{Name: name, Modified: modified}
+		if name == newestPath {
+			header.Modified = newest
+		}
+		header.SetMode(0644)
+		w, err := zw.CreateHeader(header)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()),
+		int64(buf.Len()))
+	require.NoError(t, err)
+
+	return r
+}
+
+// TestCorpusStats verifies that the inputs of the corpus are counted per fuzz
+// target, with their sizes, the newest one and the types of their values.
+func TestCorpusStats(t *testing.T) {
+	const header = "go test fuzz v1\n"
+
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	r := corpusArchive(t, map[string]string{
+		"a/b/testdata/fuzz/FuzzX/1": header + "string(\"x\")\n",
+		"a/b/testdata/fuzz/FuzzX/2": header + "string(\"yy\")\n",
+		"a/b/testdata/fuzz/FuzzX/3": header + "[]byte(\"z\")\n" +
+			"int64(1)\n",
+		"a/b/testdata/fuzz/FuzzX/4": "garbage",
+		"testdata/fuzz/FuzzRoot/1":  header + "bool(true)\n",
+		"a/b/README":                "not an input",
+	}, now.Add(-2*time.Hour), now.Add(-90*time.Second),
+		"a/b/testdata/fuzz/FuzzX/2")
+
+	stats, err := corpusStats(r)
+	require.NoError(t, err)
+	require.Len(t, stats, 2)
+
+	s := stats[0]
+	assert.Equal(t, ".", s.Package)
+	assert.Equal(t, "FuzzRoot", s.Target)
+
+	s = stats[1]
+	assert.Equal(t, "a/b", s.Package)
+	assert.Equal(t, "FuzzX", s.Target)
+	assert.Equal(t, 4, s.Inputs)
+	assert.Equal(t, int64(7+28+29+37), s.Bytes)
+	assert.Equal(t, []int64{7, 28, 29, 37}, s.Sizes)
+	assert.Equal(t, int64(28), s.percentileSize(50))
+	assert.Equal(t, int64(37), s.percentileSize(100))
+	assert.True(t, s.Newest.Equal(now.Add(-90*time.Second)))
+	assert.Equal(t, map[string]int{
+		"(string)":        2,
+		"([]byte, int64)": 1,
+		invalidSignature:  1,
+	}, s.Signatures)
+
+	var out bytes.Buffer
+	require.NoError(t, writeCorpusStats(&out, stats, now))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.Equal(t, []string{"PACKAGE", "TARGET", "INPUTS", "BYTES", "MIN",
+		"P50", "P90", "MAX", "NEWEST", "SIGNATURES"},
+		strings.Fields(lines[0]))
+	assert.Equal(t, []string{"a/b", "FuzzX", "4", "101", "7", "28", "29",
+		"37", "1m30s", "(string):", "2;", "([]byte,", "int64):", "1;",
+		"invalid:", "1"}, strings.Fields(lines[2]))
+}
+
+// TestExportCorpus verifies that the inputs of a package or fuzz target are
+// exported with their modification time.
+func TestExportCorpus(t *testing.T) {
+	modified := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	inputs := map[string]string{
+		"a/testdata/fuzz/FuzzX/1": "x",
+		"a/testdata/fuzz/FuzzY/1": "y",
+		"b/testdata/fuzz/FuzzZ/1": "z",
+	}
+	r := corpusArchive(t, inputs, modified, modified, "")
+
+	tests := []struct {
+		pkg, target string
+		want        []string
+	}{
+		{want: []string{"a/testdata/fuzz/FuzzX/1",
+			"a/testdata/fuzz/FuzzY/1", "b/testdata/fuzz/FuzzZ/1"}},
+		{pkg: "a", want: []string{"a/testdata/fuzz/FuzzX/1",
+			"a/testdata/fuzz/FuzzY/1"}},
+		{pkg: "a", target: "FuzzY",
+			want: []string{"a/testdata/fuzz/FuzzY/1"}},
+		{pkg: "c"},
+	}
+	for _, tc := range tests {
+		out := t.TempDir()
+		n, err := exportCorpus(r, out, tc.pkg, tc.target)
+		require.NoError(t, err)
+		assert.Equal(t, len(tc.want), n)
+
+		var got []string
+		err = filepath.WalkDir(out, func(p string, d os.DirEntry,
+			err error) error {
+
+			if err != nil || d.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(out, p)
+			require.NoError(t, err)
+			rel = filepath.ToSlash(rel)
+			got = append(got, rel)
+
+			data, err := os.ReadFile(p)
+			require.NoError(t, err)
+			assert.Equal(t, inputs[rel], string(data))
+
+			info, err := d.Info()
+			require.NoError(t, err)
+			assert.True(t, info.ModTime().Equal(modified))
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, tc.want, got)
+	}
+}
@@ -0,0 +1,494 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// coverageDirName is the directory in the fuzz results directory that
+	// holds the coverage profiles and HTML reports of the latest cycle.
+	coverageDirName = "coverage"
+
+	// coverageHistoryFileName is the name of the file in the fuzz results
+	// directory that stores the coverage of the most recent cycles.
+	coverageHistoryFileName = "coverage.json"
+
+	// maxCoverageCycles is the number of cycles whose coverage is kept.
+	maxCoverageCycles = 100
+)
+
+// coverBlock is a block of statements of a coverage profile.
+type coverBlock struct {
+	// numStmt is the number of statements in the block.
+	numStmt int
+
+	// count is the number of times the block was executed, or whether it
+	// was in "set" mode.
+	count int64
+}
+
+// coverProfile is a coverage profile, as written by "go test -coverprofile".
+type coverProfile struct {
+	// mode is the coverage mode: "set", "count" or "atomic".
+	mode string
+
+	// blocks maps the position of each block, "file:start,end", to the
+	// block.
+	blocks map[string]coverBlock
+}
+
+// parseCoverProfile reads a coverage profile.
+func parseCoverProfile(r io.Reader) (*coverProfile, error) {
+	profile := &coverProfile{blocks: make(map[string]coverBlock)}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		if mode, ok := strings.CutPrefix(line, "mode: "); ok {
+			profile.mode = mode
+			continue
+		}
+
+		// Lines are "<file>:<start>,<end> <statements> <count>".
+		var (
+			block  coverBlock
+			err    error
+			fields = strings.Fields(line)
+		)
+		if len(fields) == 3 {
+			block.numStmt, err = strconv.Atoi(fields[1])
+			if err == nil {
+				block.count, err = strconv.ParseInt(fields[2],
+					10, 64)
+			}
+		}
+		if len(fields) != 3 || err != nil {
+			return nil, fmt.Errorf("malformed coverage line %q",
+				line)
+		}
+
+		profile.add(fields[0], block)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading coverage profile: %w", err)
+	}
+	if profile.mode == "" {
+		return nil, fmt.Errorf("coverage profile has no mode line")
+	}
+
+	return profile, nil
+}
+
+// add merges a block into the profile. Counts of a block seen before are
+// added up, or, in "set" mode, combined.
+func (p *coverProfile) add(pos string, block coverBlock) {
+	prev, ok := p.blocks[pos]
+	if ok {
+		if p.mode == "set" {
+			block.count = max(block.count, prev.count)
+		} else {
+			block.count += prev.count
+		}
+	}
+	p.blocks[pos] = block
+}
+
+// merge adds all blocks of other into the profile.
+func (p *coverProfile) merge(other *coverProfile) {
+	if p.mode == "" {
+		p.mode = other.mode
+	}
+	for pos, block := range other.blocks {
+		p.add(pos, block)
+	}
+}
+
+// statements returns the total number of statements of the profile and the
+// number of them that were executed.
+func (p *coverProfile) statements() (int, int) {
+	var total, covered int
+	for _, block := range p.blocks {
+		total += block.numStmt
+		if block.count > 0 {
+			covered += block.numStmt
+		}
+	}
+
+	return total, covered
+}
+
+// percent returns the percentage of statements that were executed.
+func (p *coverProfile) percent() float64 {
+	total, covered := p.statements()
+	if total == 0 {
+		return 0
+	}
+
+	return 100 * float64(covered) / float64(total)
+}
+
+// write writes the profile in the format of "go test -coverprofile", with the
+// blocks sorted by position.
+func (p *coverProfile) write(w io.Writer) error {
+	positions := make([]string, 0, len(p.blocks))
+	for pos := range p.blocks {
+		positions = append(positions, pos)
+	}
+	sort.Strings(positions)
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "mode: %s\n", p.mode)
+	for _, pos := range positions {
+		block := p.blocks[pos]
+		fmt.Fprintf(bw, "%s %d %d\n", pos, block.numStmt, block.count)
+	}
+
+	return bw.Flush()
+}
+
+// packageCoverage is the coverage of a package by the corpora of its fuzz
+// targets.
+type packageCoverage struct {
+	// Percent is the percentage of the package's statements covered by
+	// the corpora of all its targets.
+	Percent float64 `json:"percent"`
+
+	// Statements and Covered are the number of statements of the package
+	// and the number of them covered.
+	Statements int `json:"statements"`
+	Covered    int `json:"covered"`
+
+	// Targets maps each fuzz target to the percentage of statements
+	// covered by its corpus alone.
+	Targets map[string]float64 `json:"targets"`
+}
+
+// cycleCoverage is the coverage measured at the end of a cycle.
+type cycleCoverage struct {
+	// Cycle is the number of the cycle.
+	Cycle int `json:"cycle"`
+
+	// Commit is the commit fuzzed in the cycle.
+	Commit string `json:"commit"`
+
+	// Time is when the coverage was measured.
+	Time time.Time `json:"time"`
+
+	// Packages maps each fuzzed package to its coverage.
+	Packages map[string]*packageCoverage `json:"packages"`
+}
+
+// measureCoverage replays the corpus of every fuzz target with coverage
+// enabled, and writes the merged coverage profile and the HTML report of each
+// package into the coverage directory of the fuzz results. Packages whose
+// coverage could not be measured are logged and left out.
+func measureCoverage(ctx context.Context, logger *slog.Logger, cfg *Config,
+	pkgTargets map[string][]string, cycle int,
+	commit string) (*cycleCoverage, error) {
+
+	reportDir := filepath.Join(cfg.FuzzResultsPath, coverageDirName)
+	if err := EnsureDirExists(reportDir); err != nil {
+		return nil, err
+	}
+
+	coverage := &cycleCoverage{
+		Cycle:    cycle,
+		Commit:   commit,
+		Time:     time.Now(),
+		Packages: make(map[string]*packageCoverage),
+	}
+	for pkg, targets := range pkgTargets {
+		pkgCtx, span := startSpan(ctx, "package coverage",
+			attribute.String("package", pkg))
+		pc, err := measurePackageCoverage(pkgCtx, logger, cfg,
+			reportDir, pkg, targets)
+		if err == nil {
+			span.SetAttributes(attribute.Float64("percent",
+				pc.Percent))
+		}
+		endSpan(span, err)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			logger.Error("Failed to measure coverage", "package",
+				pkg, "error", err)
+			continue
+		}
+
+		coverage.Packages[pkg] = pc
+	}
+
+	return coverage, nil
+}
+
+// measurePackageCoverage measures the coverage of the package by the corpus
+// of each of its fuzz targets, and writes the merged profile and the HTML
+// report of the package into reportDir.
+func measurePackageCoverage(ctx context.Context, logger *slog.Logger,
+	cfg *Config, reportDir, pkg string,
+	targets []string) (*packageCoverage, error) {
+
+	pkgPath := filepath.Join(cfg.ProjectDir, pkg)
+	name := strings.ReplaceAll(pkg, "/", "_")
+
+	merged := &coverProfile{blocks: make(map[string]coverBlock)}
+	pc := &packageCoverage{Targets: make(map[string]float64)}
+	for _, target := range targets {
+		profile, err := replayCorpusCoverage(ctx, logger, cfg, pkg,
+			target, filepath.Join(reportDir,
+				name+"_"+target+".coverprofile"))
+		if err != nil {
+			return nil, fmt.Errorf("target %q: %w", target, err)
+		}
+
+		pc.Targets[target] = profile.percent()
+		merged.merge(profile)
+	}
+
+	profilePath := filepath.Join(reportDir, name+".coverprofile")
+	if err := writeCoverProfile(profilePath, merged); err != nil {
+		return nil, err
+	}
+	pc.Statements, pc.Covered = merged.statements()
+	pc.Percent = merged.percent()
+
+	// The report is rendered from the package directory, where the source
+	// files named by the profile are resolved.
+	var output bytes.Buffer
+	cmd := goCommand(ctx, cfg, pkgPath, "tool", "cover",
+		"-html="+profilePath, "-o", filepath.Join(reportDir,
+			name+".html"))
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("rendering HTML report: %w (output: "+
+			"%q)", err, strings.TrimSpace(output.String()))
+	}
+
+	return pc, nil
+}
+
+// replayCorpusCoverage runs the package's fuzz target over its seed corpus
+// and the corpus generated by fuzzing, writing the coverage profile to
+// profilePath. The generated corpus is temporarily copied into the package's
+// testdata/fuzz/<target> directory, where "go test" picks it up as seed
+// corpus.
+func replayCorpusCoverage(ctx context.Context, logger *slog.Logger,
+	cfg *Config, pkg, target, profilePath string) (*coverProfile, error) {
+
+	pkgPath := filepath.Join(cfg.ProjectDir, pkg)
+	corpusDir := filepath.Join(cfg.CorpusDir, pkg, "testdata", "fuzz",
+		target)
+	seedDir := filepath.Join(pkgPath, "testdata", "fuzz", target)
+	copied, err := copyCorpus(corpusDir, seedDir)
+	defer func() {
+		for _, path := range copied {
+			os.Remove(path)
+		}
+	}()
+	if err != nil {
+		return nil, err
+	}
+
+	var output bytes.Buffer
+	cmd := goCommand(ctx, cfg, pkgPath, "test",
+		fmt.Sprintf("-run=^%s$", target), "-coverprofile="+profilePath,
+		".")
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	// An input that fails the target, e.g. a crash that is not fixed yet,
+	// still leaves a complete profile behind.
+	runErr := cmd.Run()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	file, err := os.Open(profilePath)
+	if err != nil {
+		if runErr != nil {
+			return nil, fmt.Errorf("go test failed: %w (output: "+
+				"%q)", runErr,
+				strings.TrimSpace(output.String()))
+		}
+		return nil, fmt.Errorf("opening coverage profile: %w", err)
+	}
+	defer file.Close()
+
+	if runErr != nil {
+		logger.Warn("Fuzz target failed while measuring coverage",
+			"target", target, "error", runErr)
+	}
+
+	return parseCoverProfile(file)
+}
+
+// copyCorpus copies the corpus entries in srcDir that are missing from
+// dstDir, returning the paths of the copies. A missing srcDir is an empty
+// corpus.
+func copyCorpus(srcDir, dstDir string) ([]string, error) {
+	entries, err := os.ReadDir(srcDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading corpus: %w", err)
+	}
+	if err := EnsureDirExists(dstDir); err != nil {
+		return nil, err
+	}
+
+	var copied []string
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+
+		dst := filepath.Join(dstDir, entry.Name())
+		if _, err := os.Stat(dst); err == nil {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(srcDir, entry.Name()))
+		if err != nil {
+			return copied, fmt.Errorf("reading corpus entry: %w",
+				err)
+		}
+		if err := os.WriteFile(dst, data, 0644); err != nil {
+			return copied, fmt.Errorf("copying corpus entry: %w",
+				err)
+		}
+		copied = append(copied, dst)
+	}
+
+	return copied, nil
+}
+
+// writeCoverProfile writes the profile to path.
+func writeCoverProfile(path string, profile *coverProfile) error {
+	var buf bytes.Buffer
+	if err := profile.write(&buf); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("writing coverage profile: %w", err)
+	}
+
+	return nil
+}
+
+// loadCoverageHistory reads the coverage of the most recent cycles from the
+// results directory, oldest first. A missing history file yields no cycles.
+func loadCoverageHistory(resultsDir string) ([]*cycleCoverage, error) {
+	path := filepath.Join(resultsDir, coverageHistoryFileName)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var history []*cycleCoverage
+	if err := json.Unmarshal(data, &history); err != nil {
+		return nil, fmt.Errorf("decoding coverage history %q: %w", path,
+			err)
+	}
+
+	return history, nil
+}
+
+// saveCycleCoverage appends the coverage of a cycle to the history stored in
+// the results directory, keeping the last maxCoverageCycles cycles.
+func saveCycleCoverage(resultsDir string, coverage *cycleCoverage) error {
+	history, err := loadCoverageHistory(resultsDir)
+	if err != nil {
+		return err
+	}
+
+	history = append(history, coverage)
+	if len(history) > maxCoverageCycles {
+		history = history[len(history)-maxCoverageCycles:]
+	}
+
+	data, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding coverage history: %w", err)
+	}
+
+	if err := EnsureDirExists(resultsDir); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so that a crash of the daemon never
+	// leaves a truncated history behind.
+	path := filepath.Join(resultsDir, coverageHistoryFileName)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("writing coverage history: %w", err)
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+// reportCoverage measures the coverage of the cycle's fuzz targets, logs it
+// along with its change since the previous cycle, and appends it to the
+// coverage history.
+func reportCoverage(ctx context.Context, logger *slog.Logger, cfg *Config,
+	pkgTargets map[string][]string, cycle int, commit string) {
+
+	coverage, err := measureCoverage(ctx, logger, cfg, pkgTargets, cycle,
+		commit)
+	if err != nil {
+		logger.Error("Failed to measure coverage", "error", err)
+		return
+	}
+
+	history, err := loadCoverageHistory(cfg.FuzzResultsPath)
+	if err != nil {
+		logger.Error("Failed to load coverage history", "error", err)
+	}
+	var prev *cycleCoverage
+	if len(history) > 0 {
+		prev = history[len(history)-1]
+	}
+
+	pkgs := make([]string, 0, len(coverage.Packages))
+	for pkg := range coverage.Packages {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+	for _, pkg := range pkgs {
+		pc := coverage.Packages[pkg]
+		args := []any{"cycle", cycle, "package", pkg, "percent",
+			fmt.Sprintf("%.1f", pc.Percent), "statements",
+			pc.Statements, "covered", pc.Covered}
+		if prev != nil && prev.Packages[pkg] != nil {
+			args = append(args, "change", fmt.Sprintf("%+.1f",
+				pc.Percent-prev.Packages[pkg].Percent))
+		}
+		logger.Info("Package coverage", args...)
+	}
+
+	if err := saveCycleCoverage(cfg.FuzzResultsPath, coverage); err != nil {
+		logger.Error("Failed to save coverage history", "error", err)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCoverProfileMerge verifies that merging coverage profiles combines the
+// blocks covered by either profile.
+func TestCoverProfileMerge(t *testing.T) {
+	first, err := parseCoverProfile(strings.NewReader(`mode: set
+example.com/fuzz/parser/parse.go:3.30,4.12 1 1
+example.com/fuzz/parser/parse.go:4.12,6.3 1 0
+example.com/fuzz/parser/parse.go:7.2,7.14 2 1
+`))
+	require.NoError(t, err)
+	second, err := parseCoverProfile(strings.NewReader(`mode: set
+example.com/fuzz/parser/parse.go:3.30,4.12 1 1
+example.com/fuzz/parser/parse.go:4.12,6.3 1 1
+example.com/fuzz/parser/parse.go:7.2,7.14 2 0
+`))
+	require.NoError(t, err)
+
+	total, covered := first.statements()
+	assert.Equal(t, 4, total)
+	assert.Equal(t, 3, covered)
+	assert.InDelta(t, 75, first.percent(), 0.01)
+
+	merged := &coverProfile{blocks: make(map[string]coverBlock)}
+	merged.merge(first)
+	merged.merge(second)
+	assert.InDelta(t, 100, merged.percent(), 0.01)
+
+	var buf bytes.Buffer
+	require.NoError(t, merged.write(&buf))
+	assert.Equal(t, `mode: set
+example.com/fuzz/parser/parse.go:3.30,4.12 1 1
+example.com/fuzz/parser/parse.go:4.12,6.3 1 1
+example.com/fuzz/parser/parse.go:7.2,7.14 2 1
+`, buf.String())
+
+	_, err = parseCoverProfile(strings.NewReader("mode: set\nbogus\n"))
+	assert.Error(t, err)
+}
+
+// TestReportCoverage verifies that the coverage of a package by the corpus
+// generated by fuzzing is measured, written as profiles and an HTML report,
+// and appended to the coverage history.
+func TestReportCoverage(t *testing.T) {
+	dir := t.TempDir()
+	writeTestPackage(t, dir, "parser", `package parser
+
+import "testing"
+
+func FuzzParse(f *testing.F) {
+	f.Add("seed")
+	f.Fuzz(func(t *testing.T, s string) { Parse(s) })
+}
+`)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "parser",
+		"parse.go"), []byte(`package parser
+
+func Parse(s string) int {
+	if s == "magic" {
+		return 1
+	}
+	return 0
+}
+`), 0644))
+
+	cfg := &Config{
+		ProjectDir:      dir,
+		CorpusDir:       t.TempDir(),
+		FuzzResultsPath: t.TempDir(),
+		Coverage:        true,
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	pkgTargets := map[string][]string{"parser": {"FuzzParse"}}
+	ctx := context.Background()
+
+	// The seed corpus misses the branch.
+	reportCoverage(ctx, logger, cfg, pkgTargets, 1, "abc123")
+
+	// Fuzzing then generated an input reaching the branch.
+	corpusDir := filepath.Join(cfg.CorpusDir, "parser", "testdata",
+		"fuzz", "FuzzParse")
+	require.NoError(t, os.MkdirAll(corpusDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(corpusDir, "entry"),
+		[]byte("go test fuzz v1\nstring(\"magic\")\n"), 0644))
+	reportCoverage(ctx, logger, cfg, pkgTargets, 2, "def456")
+
+	history, err := loadCoverageHistory(cfg.FuzzResultsPath)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+
+	assert.Equal(t, 1, history[0].Cycle)
+	before := history[0].Packages["parser"]
+	require.NotNil(t, before)
+	assert.Equal(t, 3, before.Statements)
+	assert.Equal(t, 2, before.Covered)
+
+	assert.Equal(t, "def456", history[1].Commit)
+	after := history[1].Packages["parser"]
+	require.NotNil(t, after)
+	assert.Equal(t, 3, after.Covered)
+	assert.InDelta(t, 100, after.Percent, 0.01)
+	assert.InDelta(t, 100, after.Targets["FuzzParse"], 0.01)
+
+	reportDir := filepath.Join(cfg.FuzzResultsPath, coverageDirName)
+	assert.FileExists(t, filepath.Join(reportDir, "parser.coverprofile"))
+	assert.FileExists(t, filepath.Join(reportDir,
+		"parser_FuzzParse.coverprofile"))
+	html, err := os.ReadFile(filepath.Join(reportDir, "parser.html"))
+	require.NoError(t, err)
+	assert.Contains(t, string(html), "parse.go")
+
+	// The generated corpus is removed from the package again.
+	assert.NoFileExists(t, filepath.Join(dir, "parser", "testdata",
+		"fuzz", "FuzzParse", "entry"))
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// crashRecordSuffix is the file name suffix of persisted crash records.
+const crashRecordSuffix = ".crash.json"
+
+// crashClass categorizes the failure behind a crash.
+type crashClass string
+
+const (
+	// crashClassPanic is a panic or fatal runtime error in the code under
+	// test.
+	crashClassPanic crashClass = "panic"
+
+	// crashClassAssertion is a failure reported by the fuzz target
+	// through t.Error, t.Fatal and friends.
+	crashClassAssertion crashClass = "assertion"
+
+	// crashClassTimeout is an input that made the fuzzing process hang.
+	crashClassTimeout crashClass = "timeout"
+
+	// crashClassOOM is a fuzzing process that ran out of memory or was
+	// killed by a signal, typically by the OOM killer.
+	crashClassOOM crashClass = "oom"
+
+	// crashClassBuild is a package that failed to build or set up. It is
+	// reported as a broken target and never recorded as a crash.
+	crashClassBuild crashClass = "build"
+
+	// crashClassSeed is a failure of a seed corpus entry.
+	crashClassSeed crashClass = "seed"
+
+	// crashClassRace is a data race reported by the race detector.
+	crashClassRace crashClass = "race"
+
+	// crashClassLimit is a fuzzing process killed for exceeding the
+	// memory limit of its sandbox. The CPU limit only slows processes
+	// down, so it never causes a crash of this class.
+	crashClassLimit crashClass = "limit"
+)
+
+// crashRecord is the persistent, machine-readable description of a unique
+// crash. It is stored next to the human-readable crash log in the fuzz results
+// directory and is keyed by the crash signature.
+type crashRecord struct {
+	// Package is the package containing the crashing fuzz target.
+	Package string `json:"package"`
+
+	// Target is the name of the crashing fuzz target.
+	Target string `json:"target"`
+
+	// Signature is the short hash used to deduplicate the crash.
+	Signature string `json:"signature"`
+
+	// Class categorizes the failure behind the crash.
+	Class crashClass `json:"class"`
+
+	// LogFile is the name of the crash log within the results directory.
+	LogFile string `json:"log_file"`
+
+	// InputID is the name of the failing input written by the fuzzer. It
+	// is empty if the crash was caused by a seed corpus entry.
+	InputID string `json:"input_id,omitempty"`
+
+	// Input is the raw content of the failing input.
+	Input []byte `json:"input,omitempty"`
+
+	// FirstSeen is the time the crash was first detected.
+	FirstSeen time.Time `json:"first_seen"`
+
+	// LastSeen is the time the crash was most recently detected.
+	LastSeen time.Time `json:"last_seen"`
+
+	// Occurrences is the number of times the crash has been detected.
+	Occurrences int `json:"occurrences"`
+
+	// Fixed reports whether the failing input no longer reproduces the
+	// crash.
+	Fixed bool `json:"fixed"`
+
+	// FixedAt is the time the crash was detected as fixed.
+	FixedAt time.Time `json:"fixed_at"`
+
+	// FirstBadCommit is the commit that introduced the crash, as found by
+	// bisection. It is empty if the crash has not been bisected.
+	FirstBadCommit string `json:"first_bad_commit,omitempty"`
+
+	// BisectCandidates lists the commits, oldest first, that may have
+	// introduced the crash when bisection could not isolate a single one
+	// because some commits could not be tested.
+	BisectCandidates []string `json:"bisect_candidates,omitempty"`
+
+	// RegressionBranch is the branch of the clone at
+	// cfg.RegressionRepoPath adding the failing input to the testdata of
+	// the target. It is empty if no branch was created.
+	RegressionBranch string `json:"regression_branch,omitempty"`
+}
+
+// fuzzCrash describes a failure observed while running a fuzz target.
+type fuzzCrash struct {
+	// Record is the persisted record of the crash. It is nil if the crash
+	// could not be recorded.
+	Record *crashRecord
+
+	// IsNew reports whether the crash signature was observed for the
+	// first time.
+	IsNew bool
+
+	// Regression reports whether the crash had previously been detected
+	// as fixed.
+	Regression bool
+}
+
+// crashRecordFileName returns the name of the record file for the crash with
+// the given package, target and signature.
+func crashRecordFileName(pkg, target, signature string) string {
+	return fmt.Sprintf("%s_%s_%s%s", pkg, target, signature,
+		crashRecordSuffix)
+}
+
+// loadCrashRecord reads the crash record stored at path.
+func loadCrashRecord(path string) (*crashRecord, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rec crashRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("decoding crash record %q: %w", path,
+			err)
+	}
+
+	return &rec, nil
+}
+
+// saveCrashRecord writes the crash record into the results directory,
+// replacing any previous version of it.
+func saveCrashRecord(resultsDir string, rec *crashRecord) error {
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding crash record: %w", err)
+	}
+
+	path := filepath.Join(resultsDir, crashRecordFileName(rec.Package,
+		rec.Target, rec.Signature))
+
+	// Write to a temporary file first so that a crash of the daemon never
+	// leaves a truncated record behind.
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("writing crash record: %w", err)
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+// listCrashRecords returns all crash records stored in the results directory.
+// A missing results directory yields no records.
+func listCrashRecords(resultsDir string) ([]*crashRecord, error) {
+	entries, err := os.ReadDir(resultsDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var records []*crashRecord
+	for _, entry := range entries {
+		if entry.IsDir() ||
+			!strings.HasSuffix(entry.Name(), crashRecordSuffix) {
+
+			continue
+		}
+
+		rec, err := loadCrashRecord(filepath.Join(resultsDir,
+			entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+
+	return records, nil
+}
+
+// verifyCrashFixes replays the failing input of every unfixed crash whose
+// target still exists in the project. Crashes that no longer reproduce are
+// marked as fixed and announced through the notifier.
+//
+// Crashes caused by seed corpus entries carry no failing input and are never
+// marked as fixed here, and neither are crashes caused by the memory limit of
+// the sandbox, which the replay does not run under. Data races are replayed
+// with the race detector.
+func verifyCrashFixes(ctx context.Context, logger *slog.Logger, cfg *Config,
+	pkgTargets map[string][]string, n *notifier) {
+
+	records, err := listCrashRecords(cfg.FuzzResultsPath)
+	if err != nil {
+		logger.Error("Failed to list crash records", "error", err)
+		return
+	}
+
+	for _, rec := range records {
+		// The phase is bounded, and the remaining crashes are
+		// verified in the next cycle.
+		if ctx.Err() != nil {
+			logger.Warn("Stopped verifying crash fixes", "error",
+				ctx.Err())
+			return
+		}
+
+		if rec.Fixed || len(rec.Input) == 0 ||
+			rec.Class == crashClassLimit ||
+			!slices.Contains(pkgTargets[rec.Package], rec.Target) {
+
+			continue
+		}
+
+		pkgPath := filepath.Join(cfg.ProjectDir, rec.Package)
+		reproduced, err := replayFuzzInput(ctx, cfg, pkgPath,
+			rec.Target, rec.InputID, rec.Input,
+			rec.Class == crashClassRace)
+		if err != nil {
+			logger.Warn("Failed to replay crash input", "package",
+				rec.Package, "target", rec.Target, "signature",
+				rec.Signature, "error", err)
+			continue
+		}
+		if reproduced {
+			continue
+		}
+
+		logger.Info("Crash no longer reproduces; marking as fixed",
+			"package", rec.Package, "target", rec.Target,
+			"signature", rec.Signature)
+
+		rec.Fixed = true
+		rec.FixedAt = time.Now()
+		err = saveCrashRecord(cfg.FuzzResultsPath, rec)
+		if err != nil {
+			logger.Error("Failed to save crash record", "error",
+				err)
+			continue
+		}
+
+		n.notify(&event{
+			Type:    eventCrashFixed,
+			Time:    rec.FixedAt,
+			Package: rec.Package,
+			Target:  rec.Target,
+			Message: "Crash in " + rec.Package + "/" + rec.Target +
+				" no longer reproduces",
+			Crash: rec,
+		})
+	}
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// executorBinary runs fuzz targets through the precompiled test binary
+	// of their package.
+	executorBinary = "binary"
+
+	// executorLocal runs fuzz targets with "go test -fuzz" from source.
+	executorLocal = "local"
+)
+
+// FuzzRun describes a single run of a fuzz target.
+type FuzzRun struct {
+	// Task is the fuzz target to run.
+	Task
+
+	// PkgDir is the directory of the package, in which the fuzzing process
+	// runs and saves failing inputs to testdata/fuzz/<target>.
+	PkgDir string
+
+	// CorpusDir is the directory the generated corpus is stored in.
+	CorpusDir string
+
+	// FuzzTime is how long the target is fuzzed.
+	FuzzTime time.Duration
+}
+
+// FuzzProcess is a started run of a fuzz target.
+type FuzzProcess interface {
+	// Output returns the combined standard output and error of the
+	// fuzzing process. It must be read until EOF before calling Wait.
+	Output() io.Reader
+
+	// Wait waits for the fuzzing process to exit, releases its resources
+	// and returns the error it exited with, if any.
+	Wait() error
+
+	// LimitExceeded reports whether the fuzzing process was killed for
+	// exceeding the memory limit it runs under.
+	LimitExceeded() bool
+
+	// Stderr returns the standard error output the fuzzing process wrote
+	// separately from Output, e.g. compiler errors of "go test". It is
+	// complete once Wait returned.
+	Stderr() string
+}
+
+// Executor starts fuzz targets. Implementations decide how and where the
+// fuzzing process runs, e.g. as a local "go test" process or through a
+// precompiled test binary.
+type Executor interface {
+	// Start starts fuzzing the target described by run. A package that
+	// fails to compile is reported as a *testBuildError.
+	Start(ctx context.Context, run *FuzzRun) (FuzzProcess, error)
+}
+
+// newExecutor returns the executor selected by cfg.Executor.
+func newExecutor(logger *slog.Logger, cfg *Config,
+	bins *testBinaries) Executor {
+
+	if cfg.Executor == executorLocal {
+		return &localExecutor{logger: logger, cfg: cfg}
+	}
+
+	return &binaryExecutor{logger: logger, cfg: cfg, bins: bins}
+}
+
+// binaryExecutor runs fuzz targets through the test binaries compiled once
+// per cycle and shared by all workers.
+type binaryExecutor struct {
+	logger *slog.Logger
+	cfg    *Config
+	bins   *testBinaries
+}
+
+// Start runs the package's test binary with the "-test.fuzz" flags.
+func (e *binaryExecutor) Start(ctx context.Context,
+	run *FuzzRun) (FuzzProcess, error) {
+
+	binPath, err := e.bins.get(ctx, run.Package, run.Race)
+	if err != nil {
+		return nil, err
+	}
+	if binPath == "" {
+		return nil, fmt.Errorf("package %q has no test files",
+			run.Package)
+	}
+
+	cmd := exec.CommandContext(ctx, binPath,
+		fmt.Sprintf("-test.fuzz=^%s$", run.Target),
+		fmt.Sprintf("-test.fuzzcachedir=%s", run.CorpusDir),
+		fmt.Sprintf("-test.fuzztime=%s", run.FuzzTime),
+		"-test.parallel=1",
+	)
+	cmd.Dir = run.PkgDir
+
+	// The test binary's standard error belongs to its output, as with
+	// "go test".
+	return startCommand(e.logger, e.cfg, cmd, run, false)
+}
+
+// localExecutor runs fuzz targets with "go test -fuzz", compiling the package
+// for every run.
+type localExecutor struct {
+	logger *slog.Logger
+	cfg    *Config
+}
+
+// Start runs "go test -fuzz" in the package directory.
+func (e *localExecutor) Start(ctx context.Context,
+	run *FuzzRun) (FuzzProcess, error) {
+
+	args := []string{
+		"test",
+		fmt.Sprintf("-fuzz=^%s$", run.Target),
+		fmt.Sprintf("-test.fuzzcachedir=%s", run.CorpusDir),
+		fmt.Sprintf("-fuzztime=%s", run.FuzzTime),
+		"-parallel=1",
+	}
+	if run.Race {
+		args = append(args, "-race")
+	}
+
+	// "go test" writes the compiled test binary to its build cache, which
+	// must stay writable in the sandbox.
+	var writable []string
+	if e.cfg.Sandbox {
+		out, err := goCommand(ctx, e.cfg, run.PkgDir, "env",
+			"GOCACHE").Output()
+		if err != nil {
+			return nil, fmt.Errorf("locating build cache: %w", err)
+		}
+		writable = append(writable, strings.TrimSpace(string(out)))
+	}
+
+	// "go test" sends the output of the test binary to its standard
+	// output, and its own errors, like compiler errors, to its standard
+	// error.
+	return startCommand(e.logger, e.cfg, goCommand(ctx, e.cfg,
+		run.PkgDir, args...), run, true, writable...)
+}
+
+// cmdProcess is a fuzzing process run as a local command.
+type cmdProcess struct {
+	logger *slog.Logger
+	cmd    *exec.Cmd
+	output *os.File
+	stderr *tailBuffer
+	sb     *sandbox
+
+	// limitExceeded remembers, once the command exited, whether its
+	// sandbox killed it, as the sandbox is gone by then.
+	limitExceeded bool
+	exited        bool
+}
+
+// startCommand starts cmd as the fuzzing process of run, inside a sandbox if
+// cfg.Sandbox is set. If separateStderr is set, the standard error of cmd is
+// captured separately from its output. The writable directories are left
+// writable by the sandbox, besides those every fuzzing process writes to.
+func startCommand(logger *slog.Logger, cfg *Config, cmd *exec.Cmd,
+	run *FuzzRun, separateStderr bool, writable ...string) (FuzzProcess,
+	error) {
+
+	p := &cmdProcess{logger: logger, cmd: cmd}
+
+	// Confine the fuzzing process to a sandbox in which the whole file
+	// system is read-only, except for the package's testdata directory,
+	// where failing inputs are written, the corpus directory and a
+	// private temporary directory.
+	if cfg.Sandbox {
+		sb, err := newSandbox(cfg, run.Package, run.Target)
+		if err != nil {
+			return nil, fmt.Errorf("creating sandbox: %w", err)
+		}
+
+		testdataPath := filepath.Join(run.PkgDir, "testdata")
+		writable = append([]string{testdataPath, run.CorpusDir},
+			writable...)
+		for _, dir := range writable {
+			if err = EnsureDirExists(dir); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			err = sb.wrap(cmd, sandboxSpec{
+				ReadOnly: []string{"/"},
+				Writable: writable,
+			})
+		}
+		if err != nil {
+			sb.close(logger)
+			return nil, fmt.Errorf("sandboxing fuzz target: %w",
+				err)
+		}
+		p.sb = sb
+	}
+
+	output, outputWriter, err := os.Pipe()
+	if err != nil {
+		p.closeSandbox()
+		return nil, fmt.Errorf("stdout pipe failed: %w", err)
+	}
+	cmd.Stdout = outputWriter
+	if separateStderr {
+		p.stderr = &tailBuffer{max: maxStderrBytes}
+		cmd.Stderr = p.stderr
+	} else {
+		// Like "go test" does, send both the standard output and
+		// error of the test binary to a single pipe, so that panics
+		// and race reports are processed too.
+		cmd.Stderr = outputWriter
+	}
+
+	err = cmd.Start()
+	outputWriter.Close()
+	if err != nil {
+		output.Close()
+		p.closeSandbox()
+		return nil, fmt.Errorf("command start failed: %w", err)
+	}
+	p.output = output
+
+	return p, nil
+}
+
+// Output returns the read end of the output pipe.
+func (p *cmdProcess) Output() io.Reader {
+	return p.output
+}
+
+// Wait waits for the command and removes its sandbox.
+func (p *cmdProcess) Wait() error {
+	err := p.cmd.Wait()
+	p.output.Close()
+
+	p.limitExceeded = p.LimitExceeded()
+	p.exited = true
+	p.closeSandbox()
+
+	return err
+}
+
+// LimitExceeded reports whether the sandbox killed the command.
+func (p *cmdProcess) LimitExceeded() bool {
+	if p.exited {
+		return p.limitExceeded
+	}
+
+	return p.sb != nil && p.sb.limitExceeded()
+}
+
+// Stderr returns the captured standard error, if it was captured separately.
+func (p *cmdProcess) Stderr() string {
+	if p.stderr == nil {
+		return ""
+	}
+
+	return p.stderr.String()
+}
+
+// closeSandbox removes the command's sandbox, if any.
+func (p *cmdProcess) closeSandbox() {
+	if p.sb != nil {
+		p.sb.close(p.logger)
+	}
+}
+
+// maxStderrBytes bounds the standard error output kept for a fuzzing process.
+const maxStderrBytes = 64 << 10
+
+// tailBuffer is a writer keeping the last max bytes written to it.
+type tailBuffer struct {
+	max int
+	buf []byte
+}
+
+// Write appends p, dropping the oldest bytes beyond the limit.
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.max {
+		b.buf = b.buf[len(b.buf)-b.max:]
+	}
+
+	return len(p), nil
+}
+
+// String returns the kept bytes.
+func (b *tailBuffer) String() string {
+	return string(b.buf)
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRun is the recorded behavior of a fuzz target replayed by the fake
+// executor.
+type fakeRun struct {
+	// outputFile is the file in testdata/fuzz_output holding the
+	// recorded output of the fuzzing process.
+	outputFile string
+
+	// failingInputs maps the IDs of failing inputs the fuzzing process
+	// saves to their contents.
+	failingInputs map[string]string
+
+	// limitExceeded is reported by the process.
+	limitExceeded bool
+
+	// stderr is the separately captured standard error.
+	stderr string
+
+	// err is returned by Wait.
+	err error
+}
+
+// fakeExecutor is an executor replaying recorded fuzzing output instead of
+// running a fuzz target.
+type fakeExecutor struct {
+	// runs maps fuzz targets to their recorded behavior.
+	runs map[string]fakeRun
+}
+
+// Start replays the recorded run of the target, saving its failing inputs to
+// the package's testdata directory as the fuzzing process would.
+func (e *fakeExecutor) Start(_ context.Context,
+	run *FuzzRun) (FuzzProcess, error) {
+
+	recorded := e.runs[run.Target]
+	output, err := os.ReadFile(filepath.Join("testdata", "fuzz_output",
+		recorded.outputFile))
+	if err != nil {
+		return nil, err
+	}
+
+	inputDir := filepath.Join(run.PkgDir, "testdata", "fuzz", run.Target)
+	for id, input := range recorded.failingInputs {
+		if err := os.MkdirAll(inputDir, 0755); err != nil {
+			return nil, err
+		}
+		err := os.WriteFile(filepath.Join(inputDir, id), []byte(input),
+			0644)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &fakeProcess{
+		output:        bytes.NewReader(output),
+		limitExceeded: recorded.limitExceeded,
+		stderr:        recorded.stderr,
+		err:           recorded.err,
+	}, nil
+}
+
+// fakeProcess is a replayed fuzzing process.
+type fakeProcess struct {
+	output        io.Reader
+	limitExceeded bool
+	stderr        string
+	err           error
+}
+
+// Output returns the recorded output.
+func (p *fakeProcess) Output() io.Reader {
+	return p.output
+}
+
+// Wait returns the recorded exit error.
+func (p *fakeProcess) Wait() error {
+	return p.err
+}
+
+// LimitExceeded returns the recorded limit state.
+func (p *fakeProcess) LimitExceeded() bool {
+	return p.limitExceeded
+}
+
+// Stderr returns the recorded standard error.
+func (p *fakeProcess) Stderr() string {
+	return p.stderr
+}
+
+// newFakeExecutorConfig returns a config whose project, corpus and results
+// directories are temporary.
+func newFakeExecutorConfig(t *testing.T) *Config {
+	t.Helper()
+
+	return &Config{
+		ProjectDir:      t.TempDir(),
+		CorpusDir:       t.TempDir(),
+		FuzzResultsPath: t.TempDir(),
+	}
+}
+
+// buildFailedRun is the recorded run of a target whose package does not
+// compile, with the compiler errors "go test" writes to its standard error.
+var buildFailedRun = fakeRun{
+	outputFile: "build_failed.txt",
+	stderr: "# example.com/parser\n" +
+		"./parser.go:12:2: undefined: tokenize\n",
+	err: errors.New("exit status 1"),
+}
+
+// panicInput is the failing input saved by the recorded panic run.
+var panicInput = map[string]string{
+	"96d9d74e913de20a": "go test fuzz v1\nstring(\"ab00\")\n",
+}
+
+// TestExecuteFuzzTarget verifies that the output of a fuzzing process is
+// turned into crash records, without running a real fuzz target.
+func TestExecuteFuzzTarget(t *testing.T) {
+	tests := []struct {
+		name          string
+		run           fakeRun
+		expectCrash   bool
+		expectedClass crashClass
+	}{
+		{
+			name: "no failure",
+			run:  fakeRun{outputFile: "pass.txt"},
+		},
+		{
+			name: "panic",
+			run: fakeRun{
+				outputFile:    "panic.txt",
+				failingInputs: panicInput,
+			},
+			expectCrash:   true,
+			expectedClass: crashClassPanic,
+		},
+		{
+			name: "sandbox limit exceeded",
+			run: fakeRun{
+				outputFile:    "panic.txt",
+				failingInputs: panicInput,
+				limitExceeded: true,
+			},
+			expectCrash:   true,
+			expectedClass: crashClassLimit,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newFakeExecutorConfig(t)
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			executor := &fakeExecutor{runs: map[string]fakeRun{
+				"FuzzParse": tt.run,
+			}}
+			task := Task{Package: "parser", Target: "FuzzParse"}
+
+			crash, err := executeFuzzTarget(context.Background(),
+				logger, cfg, executor, task, time.Minute,
+				newCycleStats(1, ""))
+			require.NoError(t, err)
+
+			if !tt.expectCrash {
+				assert.Nil(t, crash)
+				return
+			}
+			require.NotNil(t, crash)
+			require.NotNil(t, crash.Record)
+			assert.True(t, crash.IsNew)
+			assert.Equal(t, tt.expectedClass, crash.Record.Class)
+			assert.Equal(t, "96d9d74e913de20a",
+				crash.Record.InputID)
+			assert.FileExists(t, filepath.Join(cfg.FuzzResultsPath,
+				crash.Record.LogFile))
+
+			// The failing input is removed from the package so
+			// it doesn't fail other targets.
+			assert.NoDirExists(t, filepath.Join(cfg.ProjectDir,
+				"parser", "testdata", "fuzz", "FuzzParse"))
+
+			// Replaying the same crash finds it known.
+			crash, err = executeFuzzTarget(context.Background(),
+				logger, cfg, executor, task, time.Minute,
+				newCycleStats(1, ""))
+			require.NoError(t, err)
+			require.NotNil(t, crash.Record)
+			assert.False(t, crash.IsNew)
+			assert.Equal(t, 2, crash.Record.Occurrences)
+		})
+	}
+}
+
+// TestExecuteFuzzTargetBuildFailure verifies that a target whose package fails
+// to build is reported as broken, with the build output, rather than recorded
+// as a crash.
+func TestExecuteFuzzTargetBuildFailure(t *testing.T) {
+	cfg := newFakeExecutorConfig(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	executor := &fakeExecutor{runs: map[string]fakeRun{
+		"FuzzParse": buildFailedRun,
+	}}
+	task := Task{Package: "parser", Target: "FuzzParse"}
+
+	crash, err := executeFuzzTarget(context.Background(), logger, cfg,
+		executor, task, time.Minute, newCycleStats(1, ""))
+	assert.Nil(t, crash)
+
+	var brokenErr *targetBrokenError
+	require.ErrorAs(t, err, &brokenErr)
+	assert.Contains(t, brokenErr.output, "undefined: tokenize")
+	assert.Contains(t, brokenErr.output, "[build failed]")
+
+	// No crash record is written for a broken target.
+	records, err := listCrashRecords(cfg.FuzzResultsPath)
+	require.NoError(t, err)
+	assert.Empty(t, records)
+}
+
+// TestRunWorker verifies that a worker runs every queued task, announces new
+// crashes and broken targets, records the result and statistics of every run
+// and marks the targets that did not crash as good.
+func TestRunWorker(t *testing.T) {
+	cfg := newFakeExecutorConfig(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	executor := &fakeExecutor{runs: map[string]fakeRun{
+		"FuzzParse": {
+			outputFile:    "panic.txt",
+			failingInputs: panicInput,
+		},
+		"FuzzEval":  {outputFile: "pass.txt"},
+		"FuzzLexer": buildFailedRun,
+	}}
+
+	state, err := loadFuzzState(cfg.FuzzResultsPath)
+	require.NoError(t, err)
+	state.setLastCommit("abc123")
+
+	sink := &recordingSink{}
+	n := newNotifierWithSinks(logger, sink)
+
+	taskQueue := NewTaskQueue()
+	taskQueue.Enqueue(Task{Package: "parser", Target: "FuzzParse"})
+	taskQueue.Enqueue(Task{Package: "parser", Target: "FuzzLexer"})
+	taskQueue.Enqueue(Task{Package: "parser", Target: "FuzzEval"})
+
+	stats := newCycleStats(1, "abc123")
+	err = runWorker(1, context.Background(), taskQueue, time.Minute,
+		logger, cfg, executor, n, newFuzzMetrics(1),
+		newFuzzStatus(cfg), state, stats)
+	require.NoError(t, err)
+	n.close()
+
+	assert.Equal(t, []eventType{eventNewCrash, eventTargetBuildFailure},
+		sink.events)
+	assert.Equal(t, "abc123", state.lastGoodCommit("parser", "FuzzEval"))
+	assert.Empty(t, state.lastGoodCommit("parser", "FuzzParse"))
+	assert.Empty(t, state.lastGoodCommit("parser", "FuzzLexer"))
+
+	expected := map[string]targetStatus{
+		"FuzzParse": targetStatusCrashed,
+		"FuzzLexer": targetStatusBroken,
+		"FuzzEval":  targetStatusOK,
+	}
+	for target, status := range expected {
+		data, err := os.ReadFile(filepath.Join(cfg.FuzzResultsPath,
+			targetResultFileName("parser", target)))
+		require.NoError(t, err)
+
+		var res targetResult
+		require.NoError(t, json.Unmarshal(data, &res))
+		assert.Equal(t, status, res.Status, target)
+		assert.Equal(t, "abc123", res.Commit, target)
+	}
+
+	// The progress reported by the recorded runs is aggregated per
+	// target.
+	assert.Equal(t, []targetStats{
+		{
+			Package:          "parser",
+			Target:           "FuzzEval",
+			Runs:             1,
+			FuzzTime:         5 * time.Second,
+			Execs:            520112,
+			NewInteresting:   2,
+			CorpusSize:       5,
+			BaselineCoverage: 3,
+		},
+		{
+			Package:  "parser",
+			Target:   "FuzzLexer",
+			Runs:     1,
+			Failures: 1,
+		},
+		{
+			Package:          "parser",
+			Target:           "FuzzParse",
+			Runs:             1,
+			Crashes:          1,
+			CorpusSize:       3,
+			BaselineCoverage: 3,
+		},
+	}, stats.snapshot())
+}
@@ -3,31 +3,50 @@ package main
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
 	"io"
 	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
+	"syscall"
 	"time"
 )
 
 // listPkgsFuzzTargets scans each package path listed in cfg.FuzzPkgsPath,
 // invokes listFuzzTargets to retrieve fuzz targets for that package, and
 // returns a map of package-to-targets along with the total number of fuzz
-// targets found across all packages. If any package listing fails, it returns
-// a non-nil error.
+// targets found across all packages. Packages whose test binary fails to
+// compile are left out of the map and returned with their build error instead,
+// so that the other packages can still be fuzzed. If any other package listing
+// fails, it returns a non-nil error.
 func listPkgsFuzzTargets(ctx context.Context, logger *slog.Logger,
-	cfg *Config) (map[string][]string, int, error) {
+	cfg *Config, bins *testBinaries) (map[string][]string, int,
+	map[string]*testBuildError, error) {
 
 	pkgToTargets := make(map[string][]string, len(cfg.FuzzPkgsPath))
+	broken := make(map[string]*testBuildError)
 	totalTargets := 0
 
 	for _, pkgPath := range cfg.FuzzPkgsPath {
-		targets, err := listFuzzTargets(ctx, logger, cfg, pkgPath)
+		targets, err := listFuzzTargets(ctx, logger, cfg, bins,
+			pkgPath)
+		var buildErr *testBuildError
+		if errors.As(err, &buildErr) {
+			logger.Error("Package failed to build; skipping its "+
+				"fuzz targets", "package", pkgPath, "output",
+				buildErr.output)
+			broken[pkgPath] = buildErr
+			continue
+		}
 		if err != nil {
-			return nil, 0, fmt.Errorf(
+			return nil, 0, nil, fmt.Errorf(
 				"failed to list fuzz targets for package "+
 					"%q: %w", pkgPath, err,
 			)
@@ -38,14 +57,16 @@ func listPkgsFuzzTargets(ctx context.Context, logger *slog.Logger,
 		totalTargets += count
 	}
 
-	return pkgToTargets, totalTargets, nil
+	return pkgToTargets, totalTargets, broken, nil
 }
 
 // listFuzzTargets discovers and returns a list of fuzz targets for the given
-// package. It uses "go test -list=^Fuzz" to list the functions and filters
-// those that start with "Fuzz".
+// package. It builds the package's test binary, runs it with
+// "-test.list=^Fuzz" to list the functions and filters those that start with
+// "Fuzz". A package whose test binary fails to compile is reported as a
+// *testBuildError.
 func listFuzzTargets(ctx context.Context, logger *slog.Logger,
-	cfg *Config, pkg string) ([]string, error) {
+	cfg *Config, bins *testBinaries, pkg string) ([]string, error) {
 
 	logger.Info("Discovering fuzz targets", "package", pkg)
 
@@ -53,9 +74,22 @@ func listFuzzTargets(ctx context.Context, logger *slog.Logger,
 	// default project directory.
 	pkgPath := filepath.Join(cfg.ProjectDir, pkg)
 
+	// Build the test binary, which is reused to run the fuzz targets.
+	binPath, err := bins.get(ctx, pkg, false)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if binPath == "" {
+		logger.Warn("No valid fuzz targets found", "package", pkg)
+		return nil, nil
+	}
+
 	// Prepare the command to list all test functions matching the pattern
 	// "^Fuzz". This leverages go's testing tool to identify fuzz targets.
-	cmd := exec.CommandContext(ctx, "go", "test", "-list=^Fuzz", ".")
+	cmd := exec.CommandContext(ctx, binPath, "-test.list=^Fuzz")
 
 	// Set the working directory to the package path.
 	cmd.Dir = pkgPath
@@ -69,8 +103,9 @@ func listFuzzTargets(ctx context.Context, logger *slog.Logger,
 	// Execute the command and check for errors, when the context wasn't
 	// canceled.
 	if err := cmd.Run(); err != nil && ctx.Err() == nil {
-		return nil, fmt.Errorf("go test failed for %q: %w (output: %q)",
-			pkg, err, strings.TrimSpace(stderr.String()))
+		return nil, fmt.Errorf("listing tests failed for %q: %w "+
+			"(output: %q)", pkg, err,
+			strings.TrimSpace(stderr.String()))
 	}
 
 	// targets holds the names of discovered fuzz targets.
@@ -94,14 +129,64 @@ func listFuzzTargets(ctx context.Context, logger *slog.Logger,
 	return targets, nil
 }
 
-// executeFuzzTarget runs the specified fuzz target for a package for a given
-// duration using the "go test" command. It sets up the necessary environment,
-// starts the command, streams its output, and logs any failures to a log file.
-func executeFuzzTarget(ctx context.Context, logger *slog.Logger, pkg string,
-	target string, cfg *Config, fuzzTime time.Duration) error {
+// sourceFuzzTargets returns, sorted, the fuzz targets declared in the test
+// files of the package in pkgDir: the top-level functions named Fuzz* taking a
+// single *testing.F. It parses the files without type checking them, so that
+// it works for packages that fail to build, and uses what it can parse of
+// files with syntax errors.
+func sourceFuzzTargets(pkgDir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(pkgDir, "*_test.go"))
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []string
+	fset := token.NewFileSet()
+	for _, file := range files {
+		f, _ := parser.ParseFile(fset, file, nil,
+			parser.SkipObjectResolution)
+		if f == nil {
+			continue
+		}
+
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv != nil ||
+				!fuzzTargetRegex.MatchString(fn.Name.Name) ||
+				len(fn.Type.Params.List) != 1 ||
+				len(fn.Type.Params.List[0].Names) > 1 {
+
+				continue
+			}
+
+			star, ok := fn.Type.Params.List[0].Type.(*ast.StarExpr)
+			if !ok {
+				continue
+			}
+			sel, ok := star.X.(*ast.SelectorExpr)
+			if ok && sel.Sel.Name == "F" &&
+				!slices.Contains(targets, fn.Name.Name) {
+
+				targets = append(targets, fn.Name.Name)
+			}
+		}
+	}
+	slices.Sort(targets)
 
+	return targets, nil
+}
+
+// executeFuzzTarget runs the task's fuzz target for a given duration through
+// the executor. It streams the output of the fuzzing process and logs any
+// failures to a log file, recording its progress in stats. The detected crash
+// is returned, or nil if the fuzz target did not fail.
+func executeFuzzTarget(ctx context.Context, logger *slog.Logger, cfg *Config,
+	executor Executor, task Task, fuzzTime time.Duration,
+	stats *cycleStats) (*fuzzCrash, error) {
+
+	pkg, target := task.Package, task.Target
 	logger.Info("Executing fuzz target", "package", pkg, "target", target,
-		"duration", fuzzTime)
+		"duration", fuzzTime, "race", task.Race)
 
 	// Construct the absolute path to the package directory within the
 	// default project directory.
@@ -114,47 +199,79 @@ func executeFuzzTarget(ctx context.Context, logger *slog.Logger, pkg string,
 	// fuzzing process.
 	maybeFailingCorpusPath := filepath.Join(pkgPath, "testdata", "fuzz")
 
-	// Prepare the arguments for the 'go test' command to run the specific
-	// fuzz target.
-	args := []string{
-		"test",
-		fmt.Sprintf("-fuzz=^%s$", target),
-		fmt.Sprintf("-test.fuzzcachedir=%s", corpusPath),
-		fmt.Sprintf("-fuzztime=%s", fuzzTime),
-		fmt.Sprintf("-parallel=1"),
-	}
-
-	// Initialize the 'go test' command with the specified arguments and
-	// context.
-	cmd := exec.CommandContext(ctx, "go", args...)
-	// Set the working directory for the command.
-	cmd.Dir = pkgPath
+	// Create a fuzzOutputProcessor to handle parsing and logging of fuzz
+	// output.
+	processor := NewFuzzOutputProcessor(logger.With("target",
+		target).With("package", pkg), cfg, maybeFailingCorpusPath, pkg,
+		target)
 
-	// Obtain a pipe to read the standard output of the command.
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return fmt.Errorf("stdout pipe failed: %w", err)
+	// Start the fuzzing process. A package that fails to compile makes
+	// the target broken rather than crashing.
+	proc, err := executor.Start(ctx, &FuzzRun{
+		Task:      task,
+		PkgDir:    pkgPath,
+		CorpusDir: corpusPath,
+		FuzzTime:  fuzzTime,
+	})
+	var buildErr *testBuildError
+	switch {
+	case errors.As(err, &buildErr):
+		return nil, &targetBrokenError{output: buildErr.output}
+
+	case err != nil:
+		if ctx.Err() != nil {
+			return nil, nil
+		}
+		return nil, err
 	}
-
-	// Start the execution of the 'go test' command.
-	if err := cmd.Start(); err != nil && ctx.Err() == nil {
-		return fmt.Errorf("command start failed: %w", err)
+	processor.limitExceeded = proc.LimitExceeded
+	processor.onProgress = func(progress fuzzProgress) {
+		stats.update(task, progress)
 	}
 
 	// Channel to signal if the fuzz target encountered a failure.
-	fuzzTargetFailingChan := make(chan bool, 1)
+	fuzzTargetFailingChan := make(chan *fuzzCrash, 1)
 
-	// Stream and process the standard output of 'go test', which may
-	// include both stdout and stderr content.
-	streamFuzzOutput(logger.With("target", target).With("package", pkg),
-		stdout, maybeFailingCorpusPath, cfg, pkg, target,
-		fuzzTargetFailingChan)
+	// Stream and process the output of the fuzzing process.
+	streamFuzzOutput(processor, proc.Output(), fuzzTargetFailingChan)
 
-	// Wait for the 'go test' command to finish execution.
-	err = cmd.Wait()
+	// Wait for the fuzzing process to finish execution.
+	err = proc.Wait()
+
+	stderr := strings.TrimSpace(proc.Stderr())
+	if stderr != "" {
+		processor.logger.Warn("Fuzzing process wrote to stderr",
+			"stderr", stderr)
+	}
+
+	// A package that failed to build was never fuzzed.
+	if processor.buildFailure != "" && ctx.Err() == nil {
+		return nil, &targetBrokenError{
+			output: strings.TrimSpace(stderr + "\n" +
+				processor.buildFailure),
+		}
+	}
 
 	// Check if the fuzz target encountered a failure.
-	isFailing := <-fuzzTargetFailingChan
+	crash := <-fuzzTargetFailingChan
+	isFailing := crash != nil
+
+	// A fuzzing process killed by a signal it did not get from us, most
+	// likely from the OOM killer or its sandbox's memory limit, never gets
+	// to report a failure. Record it as a crash of its own class rather
+	// than failing the worker.
+	if err != nil && ctx.Err() == nil && !isFailing &&
+		isSignalKill(err) {
+
+		class := crashClassOOM
+		if proc.LimitExceeded() {
+			class = crashClassLimit
+		}
+		crash = processor.recordCrash(class,
+			fmt.Sprintf("fuzzing process killed: %v\n", err),
+			"signal: killed\n", "", "")
+		isFailing = true
+	}
 
 	// Proceed to return an error only if the fuzz target did not fail
 	// (i.e., no failure was detected during fuzzing), and the command
@@ -162,21 +279,21 @@ func executeFuzzTarget(ctx context.Context, logger *slog.Logger, pkg string,
 	// cancellation of the context.
 	if err != nil {
 		if ctx.Err() == nil && !isFailing {
-			return fmt.Errorf("fuzz execution failed: %w", err)
+			return nil, &fuzzRunError{err: err, stderr: stderr}
 		}
 	}
 
-	// If the fuzz target fails, 'go test' saves the failing input in the
-	// package's testdata/fuzz/<FuzzTestName> directory. To prevent these
-	// saved inputs from causing subsequent test runs to fail (especially
-	// when running other fuzz targets), we remove the testdata directory to
-	// clean up the failing inputs.
+	// If the fuzz target fails, the fuzzing process saves the failing
+	// input in the package's testdata/fuzz/<FuzzTestName> directory. To
+	// prevent these saved inputs from causing subsequent test runs to fail
+	// (especially when running other fuzz targets), we remove the testdata
+	// directory to clean up the failing inputs.
 	if isFailing {
 		failingInputPath := filepath.Join(pkgPath, "testdata", "fuzz",
 			target)
 		if err := os.RemoveAll(failingInputPath); err != nil {
-			return fmt.Errorf("failing input cleanup failed: %w",
-				err)
+			return nil, fmt.Errorf("failing input cleanup "+
+				"failed: %w", err)
 		}
 	}
 
@@ -184,29 +301,96 @@ func executeFuzzTarget(ctx context.Context, logger *slog.Logger, pkg string,
 		"target", target,
 	)
 
-	return nil
+	return crash, nil
 }
 
 // streamFuzzOutput reads and processes the standard output of a fuzzing
 // process. It utilizes a fuzzOutputProcessor to parse each line of output,
 // identifying any errors or failures that occur during fuzzing. If a failure is
 // detected, it logs the error details and the corresponding failing test case
-// into the log file for analysis. The function signals completion through the
-// provided WaitGroup and communicates whether a failure was encountered via the
-// fuzzTargetFailingChan channel.
-func streamFuzzOutput(logger *slog.Logger, r io.Reader,
-	corpusPath string, cfg *Config, pkg string, target string,
-	failureChan chan bool) {
-
-	// Create a fuzzOutputProcessor to handle parsing and logging of fuzz
-	// output.
-	processor := NewFuzzOutputProcessor(logger, cfg, corpusPath, pkg,
-		target)
+// into the log file for analysis. The detected crash, or nil if no failure was
+// encountered, is communicated via the failureChan channel.
+func streamFuzzOutput(processor *fuzzOutputProcessor, r io.Reader,
+	failureChan chan *fuzzCrash) {
 
 	// Process the fuzzing output stream. This will log all output, detect
 	// failures, and write failure details to disk if encountered.
-	failureDetected := processor.processFuzzStream(r)
+	crash := processor.processFuzzStream(r)
 
 	// Communicate the result (failure detected or not) back to the caller.
-	failureChan <- failureDetected
+	failureChan <- crash
+}
+
+// replayFuzzInput runs a single failing input against the fuzz target in the
+// package at pkgPath, reporting whether the input still makes the target fail.
+// The input is temporarily placed in the package's testdata/fuzz/<target>
+// directory, where "go test" picks it up as a seed corpus entry. If race is
+// set, the test runs with the race detector, which is the only way a data race
+// can be reported. An input running longer than cfg.ReplayTimeout, and so
+// still hanging, is reported as failing.
+//
+// An error is returned if the package could not be tested at all, e.g.
+// because it no longer compiles.
+func replayFuzzInput(ctx context.Context, cfg *Config, pkgPath, target,
+	inputID string, input []byte, race bool) (bool, error) {
+
+	inputDir := filepath.Join(pkgPath, "testdata", "fuzz", target)
+	if err := EnsureDirExists(inputDir); err != nil {
+		return false, err
+	}
+
+	inputPath := filepath.Join(inputDir, inputID)
+	if err := os.WriteFile(inputPath, input, 0644); err != nil {
+		return false, fmt.Errorf("writing failing input: %w", err)
+	}
+	defer os.Remove(inputPath)
+
+	// Bound the replay of an input that hangs. The command gets as much
+	// time again to build the test.
+	ctx, cancel := context.WithTimeout(ctx, 2*cfg.ReplayTimeout)
+	defer cancel()
+
+	// Run only the seed corpus entry corresponding to the failing input.
+	args := []string{"test", fmt.Sprintf("-run=^%s$/^%s$", target,
+		inputID), fmt.Sprintf("-timeout=%s", cfg.ReplayTimeout)}
+	if race {
+		args = append(args, "-race")
+	}
+	cmd := goCommand(ctx, cfg, pkgPath, append(args, ".")...)
+
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+	if err == nil {
+		return false, nil
+	}
+
+	// A failing test reports "--- FAIL", and a hanging one is stopped by
+	// the test timeout; any other non-zero exit means the package could
+	// not be tested.
+	if strings.Contains(output.String(), "--- FAIL:") ||
+		strings.Contains(output.String(), "panic: test timed out") {
+
+		return true, nil
+	}
+
+	return false, fmt.Errorf("go test failed: %w (output: %q)", err,
+		strings.TrimSpace(output.String()))
+}
+
+// isSignalKill reports whether err is the exit error of a process that was
+// terminated by SIGKILL.
+func isSignalKill(err error) bool {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return false
+	}
+
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	return ok && status.Signaled() && status.Signal() == syscall.SIGKILL
 }
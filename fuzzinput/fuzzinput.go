@@ -0,0 +1,513 @@
+// Package fuzzinput parses and serializes the "go test fuzz v1" encoding of the
+// inputs of Go fuzz targets, as found in testdata/fuzz directories and in the
+// fuzzing cache.
+//
+// An input is the header line followed by one value per line, written as a
+// conversion of a literal to the value's type:
+//
+//	go test fuzz v1
+//	string("abc")
+//	[]byte("\x00\x01")
+//	int64(-3)
+//	rune('x')
+//	float64(+Inf)
+//	math.Float64frombits(0x7ff8000000000002)
+//	bool(true)
+//
+// Values are represented by their Go type: string, []byte, bool, int, int8,
+// int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32 and
+// float64. Since rune and byte are aliases of int32 and uint8, they decode to
+// those types.
+package fuzzinput
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Header is the first line of every input.
+const Header = "go test fuzz v1"
+
+// ErrNoValues is returned when decoding an input holding no values.
+var ErrNoValues = errors.New("no values")
+
+// Unmarshal decodes an input and returns its values.
+func Unmarshal(data []byte) ([]any, error) {
+	lines := strings.Split(string(data), "\n")
+	if strings.TrimSpace(lines[0]) != Header {
+		return nil, fmt.Errorf("missing %q header", Header)
+	}
+
+	var vals []any
+	for i, line := range lines[1:] {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		val, err := parseValue(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+2, err)
+		}
+		vals = append(vals, val)
+	}
+	if len(vals) == 0 {
+		return nil, ErrNoValues
+	}
+
+	return vals, nil
+}
+
+// Validate checks that data is an input in the "go test fuzz v1" encoding.
+func Validate(data []byte) error {
+	_, err := Unmarshal(data)
+	return err
+}
+
+// parseValue decodes the value on a line of an input.
+func parseValue(line string) (any, error) {
+	expr, err := parser.ParseExpr(line)
+	if err != nil {
+		return nil, fmt.Errorf("malformed value %q: %w", line, err)
+	}
+	call, ok := expr.(*ast.CallExpr)
+	if !ok || len(call.Args) != 1 || call.Ellipsis.IsValid() {
+		return nil, fmt.Errorf("malformed value %q: expected a "+
+			"conversion of a literal", line)
+	}
+	arg := call.Args[0]
+
+	var val any
+	switch fun := call.Fun.(type) {
+	case *ast.ArrayType:
+		elt, ok := fun.Elt.(*ast.Ident)
+		if fun.Len != nil || !ok || elt.Name != "byte" {
+			return nil, fmt.Errorf("unsupported type in %q", line)
+		}
+		var s string
+		s, err = parseString(arg)
+		val = []byte(s)
+
+	case *ast.SelectorExpr:
+		val, err = parseFloatBits(fun, arg)
+
+	case *ast.Ident:
+		val, err = parsePrimitive(fun.Name, arg)
+
+	default:
+		return nil, fmt.Errorf("unsupported type in %q", line)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("malformed value %q: %w", line, err)
+	}
+
+	return val, nil
+}
+
+// parseString decodes a string literal.
+func parseString(arg ast.Expr) (string, error) {
+	lit, ok := arg.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", errors.New("expected a string literal")
+	}
+
+	return strconv.Unquote(lit.Value)
+}
+
+// parseFloatBits decodes a math.Float32frombits or math.Float64frombits call,
+// which encodes NaNs with a payload.
+func parseFloatBits(fun *ast.SelectorExpr, arg ast.Expr) (any, error) {
+	pkg, ok := fun.X.(*ast.Ident)
+	if !ok || pkg.Name != "math" {
+		return nil, errors.New("unsupported function")
+	}
+	lit, ok := arg.(*ast.BasicLit)
+	if !ok || lit.Kind != token.INT {
+		return nil, errors.New("expected an integer literal")
+	}
+
+	switch fun.Sel.Name {
+	case "Float32frombits":
+		bits, err := strconv.ParseUint(lit.Value, 0, 32)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float32frombits(uint32(bits)), nil
+
+	case "Float64frombits":
+		bits, err := strconv.ParseUint(lit.Value, 0, 64)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(bits), nil
+
+	default:
+		return nil, errors.New("unsupported function")
+	}
+}
+
+// literal returns the text of a literal argument, with its sign, along with
+// its kind. Identifiers, e.g. true or Inf, have the kind token.IDENT.
+func literal(arg ast.Expr) (string, token.Token, error) {
+	sign := ""
+	if unary, ok := arg.(*ast.UnaryExpr); ok {
+		if unary.Op != token.SUB && unary.Op != token.ADD {
+			return "", 0, errors.New("expected a literal")
+		}
+		sign = unary.Op.String()
+		arg = unary.X
+	}
+
+	switch arg := arg.(type) {
+	case *ast.BasicLit:
+		return sign + arg.Value, arg.Kind, nil
+
+	case *ast.Ident:
+		return sign + arg.Name, token.IDENT, nil
+
+	default:
+		return "", 0, errors.New("expected a literal")
+	}
+}
+
+// parsePrimitive decodes the conversion of a literal to the named type.
+func parsePrimitive(typ string, arg ast.Expr) (any, error) {
+	if typ == "string" {
+		return parseString(arg)
+	}
+
+	lit, kind, err := literal(arg)
+	if err != nil {
+		return nil, err
+	}
+
+	switch typ {
+	case "bool":
+		if kind != token.IDENT {
+			return nil, errors.New("expected true or false")
+		}
+		return strconv.ParseBool(lit)
+
+	case "rune", "byte":
+		if kind == token.CHAR {
+			r, err := parseChar(lit)
+			if err != nil {
+				return nil, err
+			}
+			if typ == "rune" {
+				return r, nil
+			}
+			if r > math.MaxUint8 {
+				return nil, fmt.Errorf("%s overflows byte",
+					lit)
+			}
+			return byte(r), nil
+		}
+		if typ == "rune" {
+			return parseInt("int32", lit, kind)
+		}
+		return parseInt("uint8", lit, kind)
+
+	case "float32", "float64":
+		bitSize := 64
+		if typ == "float32" {
+			bitSize = 32
+		}
+		if kind != token.INT && kind != token.FLOAT &&
+			!(kind == token.IDENT && isFloatIdent(lit)) {
+
+			return nil, errors.New("expected a number")
+		}
+		f, err := strconv.ParseFloat(lit, bitSize)
+		if err != nil {
+			return nil, err
+		}
+		if bitSize == 32 {
+			return float32(f), nil
+		}
+		return f, nil
+
+	default:
+		return parseInt(typ, lit, kind)
+	}
+}
+
+// isFloatIdent reports whether lit, with its sign, is an identifier a float
+// value is written as.
+func isFloatIdent(lit string) bool {
+	switch strings.TrimLeft(lit, "+-") {
+	case "Inf", "NaN":
+		return true
+
+	default:
+		return false
+	}
+}
+
+// parseChar decodes a rune literal.
+func parseChar(lit string) (rune, error) {
+	s, err := strconv.Unquote(lit)
+	if err != nil {
+		return 0, err
+	}
+	r, size := utf8.DecodeRuneInString(s)
+	if size != len(s) {
+		return 0, fmt.Errorf("malformed rune literal %s", lit)
+	}
+
+	return r, nil
+}
+
+// parseInt decodes an integer literal of the named integer type.
+func parseInt(typ, lit string, kind token.Token) (any, error) {
+	if kind != token.INT {
+		return nil, errors.New("expected an integer")
+	}
+
+	switch typ {
+	case "int", "int8", "int16", "int32", "int64":
+		bitSize := map[string]int{"int": strconv.IntSize, "int8": 8,
+			"int16": 16, "int32": 32, "int64": 64}[typ]
+		n, err := strconv.ParseInt(lit, 0, bitSize)
+		if err != nil {
+			return nil, err
+		}
+		switch typ {
+		case "int":
+			return int(n), nil
+		case "int8":
+			return int8(n), nil
+		case "int16":
+			return int16(n), nil
+		case "int32":
+			return int32(n), nil
+		default:
+			return n, nil
+		}
+
+	case "uint", "uint8", "uint16", "uint32", "uint64":
+		bitSize := map[string]int{"uint": strconv.IntSize, "uint8": 8,
+			"uint16": 16, "uint32": 32, "uint64": 64}[typ]
+		n, err := strconv.ParseUint(strings.TrimPrefix(lit, "+"), 0,
+			bitSize)
+		if err != nil {
+			return nil, err
+		}
+		switch typ {
+		case "uint":
+			return uint(n), nil
+		case "uint8":
+			return uint8(n), nil
+		case "uint16":
+			return uint16(n), nil
+		case "uint32":
+			return uint32(n), nil
+		default:
+			return n, nil
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported type %s", typ)
+	}
+}
+
+// Marshal encodes values as an input, as the Go fuzzer writes them.
+func Marshal(vals ...any) ([]byte, error) {
+	if len(vals) == 0 {
+		return nil, ErrNoValues
+	}
+
+	b := bytes.NewBufferString(Header + "\n")
+	for _, val := range vals {
+		switch v := val.(type) {
+		case int, int8, int16, int64, uint, uint16, uint32, uint64,
+			bool:
+
+			fmt.Fprintf(b, "%T(%v)\n", v, v)
+
+		case float32:
+			if math.IsNaN(float64(v)) && math.Float32bits(v) !=
+				math.Float32bits(float32(math.NaN())) {
+
+				fmt.Fprintf(b, "math.Float32frombits(0x%x)\n",
+					math.Float32bits(v))
+			} else {
+				fmt.Fprintf(b, "float32(%v)\n", v)
+			}
+
+		case float64:
+			if math.IsNaN(v) && math.Float64bits(v) !=
+				math.Float64bits(math.NaN()) {
+
+				fmt.Fprintf(b, "math.Float64frombits(0x%x)\n",
+					math.Float64bits(v))
+			} else {
+				fmt.Fprintf(b, "float64(%v)\n", v)
+			}
+
+		case string:
+			fmt.Fprintf(b, "string(%q)\n", v)
+
+		case rune:
+			// Negative values, surrogate halves and values above
+			// unicode.MaxRune have no rune literal.
+			if utf8.ValidRune(v) {
+				fmt.Fprintf(b, "rune(%q)\n", v)
+			} else {
+				fmt.Fprintf(b, "int32(%v)\n", v)
+			}
+
+		case byte:
+			fmt.Fprintf(b, "byte(%q)\n", v)
+
+		case []byte:
+			fmt.Fprintf(b, "[]byte(%q)\n", v)
+
+		default:
+			return nil, fmt.Errorf("unsupported type %T", val)
+		}
+	}
+
+	return b.Bytes(), nil
+}
+
+// TypeName returns the name of the type of a value, as written in the
+// signature of a fuzz function.
+func TypeName(val any) string {
+	if _, ok := val.([]byte); ok {
+		return "[]byte"
+	}
+
+	return fmt.Sprintf("%T", val)
+}
+
+// Signature returns the types of values, e.g. "(string, int64)".
+func Signature(vals []any) string {
+	names := make([]string, len(vals))
+	for i, val := range vals {
+		names[i] = TypeName(val)
+	}
+
+	return "(" + strings.Join(names, ", ") + ")"
+}
+
+// GoSyntax returns a Go expression evaluating to the value, with the value's
+// type, e.g. `int64(-3)` or `"abc"`.
+func GoSyntax(val any) string {
+	switch v := val.(type) {
+	// Untyped string, boolean and integer constants default to the types
+	// of these values.
+	case string:
+		return strconv.Quote(v)
+
+	case bool:
+		return strconv.FormatBool(v)
+
+	case int:
+		return strconv.Itoa(v)
+
+	case []byte:
+		return fmt.Sprintf("[]byte(%q)", v)
+
+	case float32:
+		return floatSyntax("float32", float64(v),
+			math.Float32bits(v) != math.Float32bits(
+				float32(math.NaN())),
+			fmt.Sprintf("math.Float32frombits(0x%x)",
+				math.Float32bits(v)))
+
+	case float64:
+		return floatSyntax("float64", v,
+			math.Float64bits(v) != math.Float64bits(math.NaN()),
+			fmt.Sprintf("math.Float64frombits(0x%x)",
+				math.Float64bits(v)))
+
+	default:
+		return fmt.Sprintf("%T(%v)", v, v)
+	}
+}
+
+// floatSyntax returns a Go expression evaluating to the float f of the named
+// type. NaNs other than math.NaN() are written with the bits expression.
+func floatSyntax(typ string, f float64, payload bool, bits string) string {
+	switch {
+	case math.IsNaN(f) && payload:
+		return bits
+
+	case math.IsNaN(f):
+		return typ + "(math.NaN())"
+
+	case math.IsInf(f, 1):
+		return typ + "(math.Inf(1))"
+
+	case math.IsInf(f, -1):
+		return typ + "(math.Inf(-1))"
+
+	default:
+		return fmt.Sprintf("%s(%v)", typ, f)
+	}
+}
+
+// SeedCode returns the f.Add call adding values to the seed corpus of a fuzz
+// target.
+func SeedCode(vals []any) string {
+	args := make([]string, len(vals))
+	for i, val := range vals {
+		args[i] = GoSyntax(val)
+	}
+
+	return "f.Add(" + strings.Join(args, ", ") + ")"
+}
+
+// Render returns a human-readable description of values, one per line. Text
+// is quoted, while binary strings and byte slices are hex dumped.
+func Render(vals []any) string {
+	var b strings.Builder
+	for i, val := range vals {
+		var data []byte
+		switch v := val.(type) {
+		case string:
+			data = []byte(v)
+		case []byte:
+			data = v
+		default:
+			fmt.Fprintf(&b, "#%d %s: %s\n", i, TypeName(val),
+				GoSyntax(val))
+			continue
+		}
+
+		fmt.Fprintf(&b, "#%d %s (len %d):", i, TypeName(val),
+			len(data))
+		if isBinary(data) {
+			b.WriteString("\n" + hex.Dump(data))
+		} else {
+			fmt.Fprintf(&b, " %q\n", data)
+		}
+	}
+
+	return b.String()
+}
+
+// isBinary reports whether data is not printable text.
+func isBinary(data []byte) bool {
+	if !utf8.Valid(data) {
+		return true
+	}
+	for _, r := range string(data) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return true
+		}
+	}
+
+	return false
+}
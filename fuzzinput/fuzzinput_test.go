@@ -0,0 +1,184 @@
+package fuzzinput
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestUnmarshal verifies that every supported value type is decoded, and that
+// malformed inputs are rejected.
+func TestUnmarshal(t *testing.T) {
+	vals, err := Unmarshal([]byte(`go test fuzz v1
+string("a\nb")
+string(` + "`raw`" + `)
+[]byte("\x00\xff")
+bool(true)
+bool(false)
+int(-1)
+int8(-128)
+int16(0x7fff)
+int32(-5)
+int64(+9)
+uint(7)
+uint8(255)
+uint16(0)
+uint32(4294967295)
+uint64(18446744073709551615)
+rune('ü')
+rune(97)
+byte('\x01')
+byte(2)
+float32(1.5)
+float64(-2.25e10)
+float64(+Inf)
+float64(-Inf)
+float64(3)
+math.Float64frombits(0x7ff8000000000002)
+math.Float32frombits(0x7fc00001)
+`))
+	require.NoError(t, err)
+
+	require.Len(t, vals, 26)
+	assert.Equal(t, []any{
+		"a\nb", "raw", []byte{0, 0xff}, true, false, -1, int8(-128),
+		int16(0x7fff), int32(-5), int64(9), uint(7), uint8(255),
+		uint16(0), uint32(math.MaxUint32), uint64(math.MaxUint64),
+		'ü', int32('a'), byte(1), byte(2), float32(1.5), -2.25e10,
+		math.Inf(1), math.Inf(-1), float64(3),
+	}, vals[:24])
+	assert.Equal(t, uint64(0x7ff8000000000002),
+		math.Float64bits(vals[24].(float64)))
+	assert.Equal(t, uint32(0x7fc00001),
+		math.Float32bits(vals[25].(float32)))
+
+	// A NaN is written as such too.
+	vals, err = Unmarshal([]byte("go test fuzz v1\r\nfloat64(NaN)\r\n"))
+	require.NoError(t, err)
+	assert.True(t, math.IsNaN(vals[0].(float64)))
+
+	malformed := []string{
+		"",
+		"go test fuzz v1\n",
+		"go test fuzz v2\nint(1)\n",
+		"int(1)\n",
+		"go test fuzz v1\nint(1\n",
+		"go test fuzz v1\nint(1, 2)\n",
+		"go test fuzz v1\nint(x)\n",
+		"go test fuzz v1\nint(1.5)\n",
+		"go test fuzz v1\nint8(128)\n",
+		"go test fuzz v1\nuint(-1)\n",
+		"go test fuzz v1\nbool(1)\n",
+		"go test fuzz v1\nstring(1)\n",
+		"go test fuzz v1\n[4]byte(\"abcd\")\n",
+		"go test fuzz v1\n[]int(\"a\")\n",
+		"go test fuzz v1\nbyte('Ā')\n",
+		"go test fuzz v1\nrune(\"a\")\n",
+		"go test fuzz v1\nfloat64(\"1\")\n",
+		"go test fuzz v1\nfloat64(!1)\n",
+		"go test fuzz v1\nmap(1)\n",
+		"go test fuzz v1\nstrings.Float64frombits(1)\n",
+		"go test fuzz v1\nmath.Sqrt(1)\n",
+		"go test fuzz v1\n1\n",
+	}
+	for _, input := range malformed {
+		_, err := Unmarshal([]byte(input))
+		assert.Error(t, err, "%q", input)
+		assert.Error(t, Validate([]byte(input)), "%q", input)
+	}
+}
+
+// TestMarshal verifies that values are encoded as the Go fuzzer writes them,
+// and decode back to the same values.
+func TestMarshal(t *testing.T) {
+	vals := []any{
+		"a\"b", []byte{0, 'x'}, true, -1, int8(2), int16(3), int32(-1),
+		'x', int64(4), uint(5), byte('y'), uint16(6), uint32(7),
+		uint64(8), float32(1.5), 2.5, math.Inf(-1),
+		math.Float64frombits(0x7ff8000000000002),
+	}
+	data, err := Marshal(vals...)
+	require.NoError(t, err)
+	assert.Equal(t, `go test fuzz v1
+string("a\"b")
+[]byte("\x00x")
+bool(true)
+int(-1)
+int8(2)
+int16(3)
+int32(-1)
+rune('x')
+int64(4)
+uint(5)
+byte('y')
+uint16(6)
+uint32(7)
+uint64(8)
+float32(1.5)
+float64(2.5)
+float64(-Inf)
+math.Float64frombits(0x7ff8000000000002)
+`, string(data))
+
+	decoded, err := Unmarshal(data)
+	require.NoError(t, err)
+	assert.Equal(t, vals[:17], decoded[:17])
+	assert.Equal(t, math.Float64bits(vals[17].(float64)),
+		math.Float64bits(decoded[17].(float64)))
+
+	_, err = Marshal()
+	assert.ErrorIs(t, err, ErrNoValues)
+	_, err = Marshal([]int{1})
+	assert.Error(t, err)
+}
+
+// TestSeedCode verifies that values are written as the arguments of an f.Add
+// call with their exact types.
+func TestSeedCode(t *testing.T) {
+	assert.Equal(t, `f.Add("a\n", []byte("\x00"), true, 1, int64(-2), `+
+		`uint8(3), float64(1), float32(math.Inf(1)), `+
+		`float64(math.NaN()))`,
+		SeedCode([]any{"a\n", []byte{0}, true, 1, int64(-2), byte(3),
+			float64(1), float32(math.Inf(1)), math.NaN()}))
+	assert.Equal(t, "f.Add(math.Float32frombits(0x7fc00001))",
+		SeedCode([]any{math.Float32frombits(0x7fc00001)}))
+	assert.Equal(t, "(string, []byte, int32)",
+		Signature([]any{"", []byte{}, 'a'}))
+}
+
+// TestRender verifies that text is quoted and binary data hex dumped.
+func TestRender(t *testing.T) {
+	assert.Equal(t, `#0 string (len 6): "héllo"
+#1 []byte (len 4):
+00000000  00 01 ff 41                                       |...A|
+#2 int64: int64(-3)
+#3 bool: true
+`, Render([]any{"héllo", []byte{0, 1, 0xff, 'A'}, int64(-3), true}))
+}
+
+// FuzzUnmarshal verifies that decoding never panics, and that decoded values
+// encode to an input decoding to the same values.
+func FuzzUnmarshal(f *testing.F) {
+	f.Add([]byte("go test fuzz v1\nstring(\"abc\")\nint(1)\n"))
+	f.Add([]byte("go test fuzz v1\n[]byte(\"\\xff\")\nrune('x')\n"))
+	f.Add([]byte("go test fuzz v1\nfloat64(-Inf)\nbool(false)\n"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		vals, err := Unmarshal(data)
+		if err != nil {
+			return
+		}
+
+		encoded, err := Marshal(vals...)
+		require.NoError(t, err)
+		decoded, err := Unmarshal(encoded)
+		require.NoError(t, err)
+
+		// NaNs never compare equal, so compare the encodings.
+		reencoded, err := Marshal(decoded...)
+		require.NoError(t, err)
+		assert.Equal(t, string(encoded), string(reencoded))
+	})
+}
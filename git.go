@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// maxHistoryWalk bounds the number of commits walked when looking for a
+// commit in the history, so that an unrelated commit never causes the whole
+// history to be traversed.
+const maxHistoryWalk = 10000
+
+// cloneRepository clones the project repository into dir. A depth of zero
+// clones the full history.
+func cloneRepository(ctx context.Context, cfg *Config, dir string,
+	depth int) (*git.Repository, error) {
+
+	ctx, span := startSpan(ctx, "git clone",
+		attribute.String("repo_url", SanitizeURL(cfg.ProjectSrcPath)),
+		attribute.Int("depth", depth))
+	repo, err := git.PlainCloneContext(
+		ctx, dir, false, &git.CloneOptions{
+			URL: cfg.ProjectSrcPath,
+			// Temporary until the previous PR got merged
+			ReferenceName: plumbing.NewBranchReferenceName(
+				"fuzz-example"),
+			SingleBranch: true,
+			Depth:        depth,
+			// // Temporary until the previous PR got merged
+		},
+	)
+	if err == nil {
+		if commit, err := headCommit(repo); err == nil {
+			span.SetAttributes(attribute.String("commit", commit))
+		}
+	}
+	endSpan(span, err)
+
+	return repo, err
+}
+
+// headCommit returns the hash of the commit checked out in the repository.
+func headCommit(repo *git.Repository) (string, error) {
+	head, err := repo.Head()
+	if err != nil {
+		return "", fmt.Errorf("resolving HEAD: %w", err)
+	}
+
+	return head.Hash().String(), nil
+}
+
+// commitsBetween returns the commits following the first-parent history from
+// good (exclusive) to bad (inclusive), ordered from oldest to newest. An error
+// is returned if good is not a first-parent ancestor of bad.
+func commitsBetween(repo *git.Repository, good, bad string) ([]string,
+	error) {
+
+	commit, err := repo.CommitObject(plumbing.NewHash(bad))
+	if err != nil {
+		return nil, fmt.Errorf("resolving commit %s: %w", bad, err)
+	}
+
+	var commits []string
+	for i := 0; i < maxHistoryWalk; i++ {
+		if commit.Hash.String() == good {
+			// Reverse into chronological order.
+			for l, r := 0, len(commits)-1; l < r; l, r = l+1, r-1 {
+				commits[l], commits[r] = commits[r], commits[l]
+			}
+			return commits, nil
+		}
+		commits = append(commits, commit.Hash.String())
+
+		if commit.NumParents() == 0 {
+			break
+		}
+		commit, err = commit.Parent(0)
+		if err != nil {
+			return nil, fmt.Errorf("resolving parent of %s: %w",
+				commits[len(commits)-1], err)
+		}
+	}
+
+	return nil, fmt.Errorf("commit %s is not an ancestor of %s", good, bad)
+}
+
+// checkoutCommit force-checks out the given commit in the repository's
+// worktree, discarding any local modifications.
+func checkoutCommit(repo *git.Repository, hash string) error {
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+
+	return worktree.Checkout(&git.CheckoutOptions{
+		Hash:  plumbing.NewHash(hash),
+		Force: true,
+	})
+}
+
+// commitSummary returns the first line of the commit message together with
+// its author, for use in reports.
+func commitSummary(repo *git.Repository, hash string) string {
+	commit, err := repo.CommitObject(plumbing.NewHash(hash))
+	if err != nil {
+		return hash
+	}
+
+	subject, _, _ := strings.Cut(commit.Message, "\n")
+	return fmt.Sprintf("%s %s (%s)", hash[:12], subject,
+		commit.Author.Name)
+}
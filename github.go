@@ -0,0 +1,385 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-continuous-fuzz/go-continuous-fuzz/fuzzinput"
+)
+
+const (
+	// githubRequestTimeout bounds the duration of a single GitHub API
+	// request.
+	githubRequestTimeout = 30 * time.Second
+
+	// maxIssueLogBytes caps the amount of crash log embedded in an issue
+	// body, keeping it well below GitHub's 65536 character limit.
+	maxIssueLogBytes = 48 * 1024
+
+	// issueStateOpen and issueStateClosed are the GitHub issue states.
+	issueStateOpen   = "open"
+	issueStateClosed = "closed"
+)
+
+// githubIssue is the subset of the GitHub issue resource used by the tracker.
+type githubIssue struct {
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	State  string `json:"state"`
+}
+
+// githubIssueTracker opens a GitHub issue for every new unique crash, comments
+// on the issue whenever the crash recurs, and closes it once the crash is
+// detected as fixed. Issues are looked up by crash signature, which is always
+// part of the issue title, so that a crash never gets more than one issue.
+type githubIssueTracker struct {
+	// apiURL is the base URL of the GitHub REST API.
+	apiURL string
+
+	// repo is the repository, in owner/name form, that issues are filed
+	// in.
+	repo string
+
+	// token authenticates the API requests.
+	token string
+
+	// resultsDir is the directory holding the crash logs.
+	resultsDir string
+
+	// client performs the HTTP requests.
+	client *http.Client
+
+	// logger for informational and error messages.
+	logger *slog.Logger
+}
+
+// newGitHubIssueTracker returns an issue tracker for the repository configured
+// in cfg, or nil if issue filing is disabled.
+func newGitHubIssueTracker(logger *slog.Logger,
+	cfg *Config) *githubIssueTracker {
+
+	if cfg.GitHubRepo == "" {
+		return nil
+	}
+
+	return &githubIssueTracker{
+		apiURL:     strings.TrimSuffix(cfg.GitHubAPIURL, "/"),
+		repo:       cfg.GitHubRepo,
+		token:      cfg.GitHubToken,
+		resultsDir: cfg.FuzzResultsPath,
+		client:     &http.Client{Timeout: githubRequestTimeout},
+		logger:     logger,
+	}
+}
+
+// name identifies the sink in logs.
+func (t *githubIssueTracker) name() string {
+	return "github"
+}
+
+// notify files crash events in the issue tracker. Events unrelated to crashes
+// are ignored.
+func (t *githubIssueTracker) notify(ctx context.Context, ev *event) error {
+	switch ev.Type {
+	case eventNewCrash, eventCrashRecurrence, eventCrashRegression:
+		return t.reportCrash(ctx, ev.Crash)
+
+	case eventCrashFixed:
+		return t.resolveCrash(ctx, ev.Crash)
+
+	case eventCrashBisected:
+		return t.commentOnCrash(ctx, ev.Crash, ev.Message+".")
+	}
+
+	return nil
+}
+
+// reportCrash files the given crash. A new crash signature opens a new issue,
+// while a recurring signature adds a comment to the existing issue, reopening
+// it if it had been closed.
+func (t *githubIssueTracker) reportCrash(ctx context.Context,
+	rec *crashRecord) error {
+
+	issue, err := t.findIssue(ctx, rec.Signature)
+	if err != nil {
+		return err
+	}
+
+	if issue == nil {
+		issue, err = t.createIssue(ctx, rec)
+		if err != nil {
+			return err
+		}
+
+		t.logger.Info("Opened GitHub issue for crash", "issue",
+			issue.Number, "signature", rec.Signature)
+		return nil
+	}
+
+	comment := fmt.Sprintf("The crash was detected again at %s "+
+		"(occurrence %d).", rec.LastSeen.UTC().Format(time.RFC3339),
+		rec.Occurrences)
+
+	if issue.State == issueStateClosed {
+		if err := t.setIssueState(ctx, issue.Number,
+			issueStateOpen); err != nil {
+			return err
+		}
+		comment = "Reopening: the crash has regressed. " + comment
+
+		t.logger.Info("Reopened GitHub issue for regressed crash",
+			"issue", issue.Number, "signature", rec.Signature)
+	}
+
+	return t.commentOnIssue(ctx, issue.Number, comment)
+}
+
+// resolveCrash closes the issue of a crash that has been detected as fixed.
+// Nothing is done if the crash has no open issue.
+func (t *githubIssueTracker) resolveCrash(ctx context.Context,
+	rec *crashRecord) error {
+
+	issue, err := t.findIssue(ctx, rec.Signature)
+	if err != nil {
+		return err
+	}
+	if issue == nil || issue.State != issueStateOpen {
+		return nil
+	}
+
+	comment := fmt.Sprintf("The failing input no longer reproduces the "+
+		"crash as of %s. Closing.", rec.FixedAt.UTC().Format(
+		time.RFC3339))
+	if err := t.commentOnIssue(ctx, issue.Number, comment); err != nil {
+		return err
+	}
+
+	if err := t.setIssueState(ctx, issue.Number,
+		issueStateClosed); err != nil {
+		return err
+	}
+
+	t.logger.Info("Closed GitHub issue for fixed crash", "issue",
+		issue.Number, "signature", rec.Signature)
+	return nil
+}
+
+// commentOnCrash adds a comment to the issue of the crash. Nothing is done if
+// the crash has no issue.
+func (t *githubIssueTracker) commentOnCrash(ctx context.Context,
+	rec *crashRecord, comment string) error {
+
+	issue, err := t.findIssue(ctx, rec.Signature)
+	if err != nil {
+		return err
+	}
+	if issue == nil {
+		return nil
+	}
+
+	return t.commentOnIssue(ctx, issue.Number, comment)
+}
+
+// findIssue searches the repository for an issue, open or closed, whose title
+// contains the crash signature. It returns nil if there is none.
+func (t *githubIssueTracker) findIssue(ctx context.Context,
+	signature string) (*githubIssue, error) {
+
+	query := fmt.Sprintf("repo:%s is:issue in:title %s", t.repo,
+		signature)
+
+	var result struct {
+		Items []githubIssue `json:"items"`
+	}
+	err := t.do(ctx, http.MethodGet, "/search/issues?q="+
+		url.QueryEscape(query), nil, &result)
+	if err != nil {
+		return nil, fmt.Errorf("searching issues: %w", err)
+	}
+
+	// The search is a full-text match, so confirm that the signature
+	// really is part of the title.
+	for _, issue := range result.Items {
+		if strings.Contains(issue.Title, issueTitleSignature(
+			signature)) {
+
+			return &issue, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// createIssue opens a new issue describing the crash.
+func (t *githubIssueTracker) createIssue(ctx context.Context,
+	rec *crashRecord) (*githubIssue, error) {
+
+	request := map[string]string{
+		"title": fmt.Sprintf("Fuzz crash in %s/%s %s", rec.Package,
+			rec.Target, issueTitleSignature(rec.Signature)),
+		"body": t.issueBody(rec),
+	}
+
+	var issue githubIssue
+	err := t.do(ctx, http.MethodPost, "/repos/"+t.repo+"/issues",
+		request, &issue)
+	if err != nil {
+		return nil, fmt.Errorf("creating issue: %w", err)
+	}
+
+	return &issue, nil
+}
+
+// commentOnIssue adds a comment to the given issue.
+func (t *githubIssueTracker) commentOnIssue(ctx context.Context, number int,
+	comment string) error {
+
+	path := fmt.Sprintf("/repos/%s/issues/%d/comments", t.repo, number)
+	err := t.do(ctx, http.MethodPost, path, map[string]string{
+		"body": comment,
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("commenting on issue #%d: %w", number, err)
+	}
+
+	return nil
+}
+
+// setIssueState opens or closes the given issue.
+func (t *githubIssueTracker) setIssueState(ctx context.Context, number int,
+	state string) error {
+
+	path := fmt.Sprintf("/repos/%s/issues/%d", t.repo, number)
+	err := t.do(ctx, http.MethodPatch, path, map[string]string{
+		"state": state,
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("setting issue #%d state to %s: %w", number,
+			state, err)
+	}
+
+	return nil
+}
+
+// issueBody renders the markdown body of a crash issue, containing the failing
+// input and the crash log with its stack trace.
+func (t *githubIssueTracker) issueBody(rec *crashRecord) string {
+	var b strings.Builder
+
+	b.WriteString("A new crash was found by continuous fuzzing.\n\n")
+	fmt.Fprintf(&b, "- **Package:** `%s`\n", rec.Package)
+	fmt.Fprintf(&b, "- **Target:** `%s`\n", rec.Target)
+	fmt.Fprintf(&b, "- **Signature:** `%s`\n", rec.Signature)
+	fmt.Fprintf(&b, "- **First seen:** %s\n\n",
+		rec.FirstSeen.UTC().Format(time.RFC3339))
+
+	b.WriteString("### Failing input\n\n")
+	if len(rec.Input) == 0 {
+		b.WriteString("The failure occurred while testing a seed " +
+			"corpus entry.\n\n")
+	} else {
+		fmt.Fprintf(&b, "`%s`:\n\n```\n%s\n```\n\n"+
+			"Committing this file makes `go test` replay the "+
+			"input as a regression test.\n\n", regressionInputPath(
+			rec.Package, rec.Target, rec.InputID),
+			strings.TrimRight(string(rec.Input), "\n"))
+
+		// Decoded values are easier to read than the encoding, and the
+		// f.Add call can be pasted into the fuzz target.
+		if vals, err := fuzzinput.Unmarshal(rec.Input); err == nil {
+			fmt.Fprintf(&b, "Decoded values:\n\n```\n%s```\n\n"+
+				"Seed corpus entry:\n\n```go\n%s\n```\n\n",
+				fuzzinput.Render(vals),
+				fuzzinput.SeedCode(vals))
+		}
+	}
+
+	// The crash log holds the failure output including the stack trace.
+	b.WriteString("### Crash log\n\n```\n")
+	b.WriteString(t.readCrashLog(rec))
+	b.WriteString("\n```\n")
+
+	return b.String()
+}
+
+// readCrashLog returns the crash log of the record, truncated to
+// maxIssueLogBytes.
+func (t *githubIssueTracker) readCrashLog(rec *crashRecord) string {
+	data, err := os.ReadFile(filepath.Join(t.resultsDir, rec.LogFile))
+	if err != nil {
+		return fmt.Sprintf("<< failed to read crash log: %v >>", err)
+	}
+
+	log := strings.TrimSpace(string(data))
+	if len(log) > maxIssueLogBytes {
+		log = log[:maxIssueLogBytes] + "\n<< truncated >>"
+	}
+
+	return log
+}
+
+// do performs an authenticated GitHub API request. The request body, if any,
+// is encoded as JSON, and the response is decoded into out unless it is nil.
+func (t *githubIssueTracker) do(ctx context.Context, method, path string,
+	body, out any) error {
+
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, t.apiURL+path,
+		reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+t.token)
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			t.logger.Error("Failed to close response body",
+				"error", err)
+		}
+	}()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status %s: %s", resp.Status,
+			strings.TrimSpace(string(msg)))
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// issueTitleSignature returns the form in which a crash signature appears in
+// issue titles.
+func issueTitleSignature(signature string) string {
+	return "[" + signature + "]"
+}
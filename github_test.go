@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeGitHubIssue is an issue stored by fakeGitHub.
+type fakeGitHubIssue struct {
+	githubIssue
+	Body     string
+	Comments []string
+}
+
+// fakeGitHub is an in-memory implementation of the subset of the GitHub REST
+// API used by githubIssueTracker, allowing the tracker to be tested offline.
+type fakeGitHub struct {
+	t     *testing.T
+	repo  string
+	token string
+
+	mu     sync.Mutex
+	issues []*fakeGitHubIssue
+}
+
+// newFakeGitHub starts an httptest server serving the fake GitHub API for the
+// given repository. The server is closed when the test ends.
+func newFakeGitHub(t *testing.T, repo, token string) (*fakeGitHub,
+	*httptest.Server) {
+
+	gh := &fakeGitHub{t: t, repo: repo, token: token}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /search/issues", gh.searchIssues)
+	mux.HandleFunc("POST /repos/{owner}/{name}/issues", gh.createIssue)
+	mux.HandleFunc("POST /repos/{owner}/{name}/issues/{number}/comments",
+		gh.createComment)
+	mux.HandleFunc("PATCH /repos/{owner}/{name}/issues/{number}",
+		gh.updateIssue)
+
+	server := httptest.NewServer(gh.authenticate(mux))
+	t.Cleanup(server.Close)
+
+	return gh, server
+}
+
+// authenticate rejects requests that don't carry the expected token.
+func (gh *fakeGitHub) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+gh.token {
+			http.Error(w, `{"message":"Bad credentials"}`,
+				http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// searchIssues serves a simplified issue search: every whitespace-separated
+// term of the query that is not a qualifier must appear in the title.
+func (gh *fakeGitHub) searchIssues(w http.ResponseWriter, r *http.Request) {
+	gh.mu.Lock()
+	defer gh.mu.Unlock()
+
+	var terms []string
+	for _, term := range strings.Fields(r.URL.Query().Get("q")) {
+		if key, value, ok := strings.Cut(term, ":"); ok {
+			if key == "repo" && value != gh.repo {
+				gh.writeJSON(w, map[string]any{
+					"items": []any{},
+				})
+				return
+			}
+			continue
+		}
+		terms = append(terms, term)
+	}
+
+	items := []githubIssue{}
+	for _, issue := range gh.issues {
+		matches := true
+		for _, term := range terms {
+			if !strings.Contains(issue.Title, term) {
+				matches = false
+			}
+		}
+		if matches {
+			items = append(items, issue.githubIssue)
+		}
+	}
+
+	gh.writeJSON(w, map[string]any{"items": items})
+}
+
+// createIssue stores a new open issue.
+func (gh *fakeGitHub) createIssue(w http.ResponseWriter, r *http.Request) {
+	gh.mu.Lock()
+	defer gh.mu.Unlock()
+
+	var req struct {
+		Title string `json:"title"`
+		Body  string `json:"body"`
+	}
+	gh.decode(r, &req)
+
+	issue := &fakeGitHubIssue{
+		githubIssue: githubIssue{
+			Number: len(gh.issues) + 1,
+			Title:  req.Title,
+			State:  issueStateOpen,
+		},
+		Body: req.Body,
+	}
+	gh.issues = append(gh.issues, issue)
+
+	w.WriteHeader(http.StatusCreated)
+	gh.writeJSON(w, issue.githubIssue)
+}
+
+// createComment appends a comment to an existing issue.
+func (gh *fakeGitHub) createComment(w http.ResponseWriter, r *http.Request) {
+	gh.mu.Lock()
+	defer gh.mu.Unlock()
+
+	issue := gh.lookup(w, r)
+	if issue == nil {
+		return
+	}
+
+	var req struct {
+		Body string `json:"body"`
+	}
+	gh.decode(r, &req)
+	issue.Comments = append(issue.Comments, req.Body)
+
+	w.WriteHeader(http.StatusCreated)
+	gh.writeJSON(w, map[string]any{"body": req.Body})
+}
+
+// updateIssue changes the state of an existing issue.
+func (gh *fakeGitHub) updateIssue(w http.ResponseWriter, r *http.Request) {
+	gh.mu.Lock()
+	defer gh.mu.Unlock()
+
+	issue := gh.lookup(w, r)
+	if issue == nil {
+		return
+	}
+
+	var req struct {
+		State string `json:"state"`
+	}
+	gh.decode(r, &req)
+	issue.State = req.State
+
+	gh.writeJSON(w, issue.githubIssue)
+}
+
+// lookup returns the issue addressed by the request, replying with 404 if it
+// doesn't exist.
+func (gh *fakeGitHub) lookup(w http.ResponseWriter,
+	r *http.Request) *fakeGitHubIssue {
+
+	repo := r.PathValue("owner") + "/" + r.PathValue("name")
+	for _, issue := range gh.issues {
+		if repo == gh.repo && fmt.Sprint(issue.Number) ==
+			r.PathValue("number") {
+
+			return issue
+		}
+	}
+
+	http.Error(w, `{"message":"Not Found"}`, http.StatusNotFound)
+	return nil
+}
+
+// decode decodes the JSON request body into v.
+func (gh *fakeGitHub) decode(r *http.Request, v any) {
+	data, err := io.ReadAll(r.Body)
+	require.NoError(gh.t, err)
+	require.NoError(gh.t, json.Unmarshal(data, v))
+}
+
+// writeJSON encodes v as the JSON response body.
+func (gh *fakeGitHub) writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	require.NoError(gh.t, json.NewEncoder(w).Encode(v))
+}
+
+// snapshot returns a copy of the stored issues.
+func (gh *fakeGitHub) snapshot() []fakeGitHubIssue {
+	gh.mu.Lock()
+	defer gh.mu.Unlock()
+
+	issues := make([]fakeGitHubIssue, 0, len(gh.issues))
+	for _, issue := range gh.issues {
+		issues = append(issues, *issue)
+	}
+	return issues
+}
+
+// TestGitHubIssueTrackerLifecycle verifies that the issue tracker opens a
+// single issue per crash signature, comments on recurrences, closes the issue
+// when the crash is fixed and reopens it when the crash regresses.
+func TestGitHubIssueTrackerLifecycle(t *testing.T) {
+	const (
+		repo  = "owner/project"
+		token = "secret-token"
+	)
+	gh, server := newFakeGitHub(t, repo, token)
+
+	resultsDir := t.TempDir()
+	rec := &crashRecord{
+		Package:   "parser",
+		Target:    "FuzzParseComplex",
+		Signature: "342a5c470d17be27",
+		LogFile: "parser_FuzzParseComplex_342a5c470d17be27_" +
+			"failure.log",
+		InputID:     "771e938e4458e983",
+		Input:       []byte("go test fuzz v1\nstring(\"0\")\n"),
+		FirstSeen:   time.Now(),
+		LastSeen:    time.Now(),
+		Occurrences: 1,
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(resultsDir, rec.LogFile),
+		[]byte("panic: runtime error: index out of range\n"), 0644))
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	tracker := newGitHubIssueTracker(logger, &Config{
+		GitHubRepo:      repo,
+		GitHubToken:     token,
+		GitHubAPIURL:    server.URL,
+		FuzzResultsPath: resultsDir,
+	})
+	ctx := context.Background()
+
+	// A new crash opens an issue containing the input and the stack.
+	require.NoError(t, tracker.notify(ctx, newCrashEvent(&fuzzCrash{
+		Record: rec, IsNew: true,
+	})))
+	issues := gh.snapshot()
+	require.Len(t, issues, 1)
+	assert.Contains(t, issues[0].Title, "parser/FuzzParseComplex")
+	assert.Contains(t, issues[0].Title, "[342a5c470d17be27]")
+	assert.Contains(t, issues[0].Body, `string("0")`)
+	assert.Contains(t, issues[0].Body, "```go\nf.Add(\"0\")\n```")
+	assert.Contains(t, issues[0].Body,
+		"`parser/testdata/fuzz/FuzzParseComplex/771e938e4458e983`")
+	assert.Contains(t, issues[0].Body, "index out of range")
+	assert.Equal(t, issueStateOpen, issues[0].State)
+
+	// A recurrence comments on the existing issue instead of opening a
+	// duplicate.
+	rec.Occurrences++
+	require.NoError(t, tracker.notify(ctx, newCrashEvent(&fuzzCrash{
+		Record: rec,
+	})))
+	issues = gh.snapshot()
+	require.Len(t, issues, 1)
+	require.Len(t, issues[0].Comments, 1)
+	assert.Contains(t, issues[0].Comments[0], "occurrence 2")
+
+	// Resolving the crash closes the issue.
+	rec.Fixed = true
+	rec.FixedAt = time.Now()
+	require.NoError(t, tracker.notify(ctx, &event{
+		Type: eventCrashFixed, Crash: rec,
+	}))
+	issues = gh.snapshot()
+	assert.Equal(t, issueStateClosed, issues[0].State)
+	assert.Len(t, issues[0].Comments, 2)
+
+	// A regression reopens the closed issue.
+	rec.Fixed = false
+	rec.Occurrences++
+	require.NoError(t, tracker.notify(ctx, newCrashEvent(&fuzzCrash{
+		Record: rec, Regression: true,
+	})))
+	issues = gh.snapshot()
+	require.Len(t, issues, 1)
+	assert.Equal(t, issueStateOpen, issues[0].State)
+	require.Len(t, issues[0].Comments, 3)
+	assert.Contains(t, issues[0].Comments[2], "regressed")
+}
+
+// TestGitHubIssueTrackerErrors verifies that API failures, such as rejected
+// credentials, are surfaced as errors.
+func TestGitHubIssueTrackerErrors(t *testing.T) {
+	_, server := newFakeGitHub(t, "owner/project", "secret-token")
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	tracker := newGitHubIssueTracker(logger, &Config{
+		GitHubRepo:   "owner/project",
+		GitHubToken:  "wrong-token",
+		GitHubAPIURL: server.URL,
+	})
+
+	err := tracker.reportCrash(context.Background(), &crashRecord{
+		Signature: "0345b61f9a8eecc9",
+	})
+	assert.ErrorContains(t, err, "401")
+}
+
+// TestNewGitHubIssueTrackerDisabled verifies that no tracker is created when
+// no repository is configured.
+func TestNewGitHubIssueTrackerDisabled(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	assert.Nil(t, newGitHubIssueTracker(logger, &Config{}))
+}
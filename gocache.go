@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// bytesPerMB is the number of bytes in a megabyte of cache size limits.
+const bytesPerMB = 1 << 20
+
+// goEnv returns the environment of every "go" command run against the fuzzed
+// project: the process environment with the configured build and module cache
+// directories and offline settings applied.
+func goEnv(cfg *Config) []string {
+	env := os.Environ()
+
+	if cfg.GoCacheDir != "" {
+		env = append(env, "GOCACHE="+cfg.GoCacheDir)
+	}
+	if cfg.GoModCacheDir != "" {
+		env = append(env, "GOMODCACHE="+cfg.GoModCacheDir)
+	}
+	if cfg.GoProxyOff {
+		env = append(env, "GOPROXY=off")
+	}
+	if cfg.GoModVendor {
+		// Keep any flags set by the user.
+		goFlags := strings.TrimSpace(os.Getenv("GOFLAGS") + " " +
+			"-mod=vendor")
+		env = append(env, "GOFLAGS="+goFlags)
+	}
+
+	return env
+}
+
+// goCommand returns a "go" command with the given arguments, run in dir with
+// the environment returned by goEnv.
+func goCommand(ctx context.Context, cfg *Config, dir string,
+	args ...string) *exec.Cmd {
+
+	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Dir = dir
+	cmd.Env = goEnv(cfg)
+
+	return cmd
+}
+
+// pruneGoCaches keeps the persistent build and module caches within their
+// configured size limits. The build cache is trimmed by removing its least
+// recently used entries, which Go simply rebuilds when needed. The module
+// cache cannot be trimmed safely file by file, so it is cleaned entirely once
+// it exceeds its limit.
+func pruneGoCaches(ctx context.Context, logger *slog.Logger, cfg *Config) {
+	if cfg.GoCacheDir != "" && cfg.GoCacheMaxSizeMB > 0 {
+		removed, err := pruneDirLRU(cfg.GoCacheDir,
+			int64(cfg.GoCacheMaxSizeMB)*bytesPerMB)
+		if err != nil {
+			logger.Error("Failed to prune build cache", "dir",
+				cfg.GoCacheDir, "error", err)
+		} else if removed > 0 {
+			logger.Info("Pruned build cache", "dir", cfg.GoCacheDir,
+				"removed_bytes", removed)
+		}
+	}
+
+	if cfg.GoModCacheDir != "" && cfg.GoModCacheMaxSizeMB > 0 {
+		size, err := dirSize(cfg.GoModCacheDir)
+		if err != nil {
+			logger.Error("Failed to measure module cache", "dir",
+				cfg.GoModCacheDir, "error", err)
+			return
+		}
+		if size <= int64(cfg.GoModCacheMaxSizeMB)*bytesPerMB {
+			return
+		}
+
+		// "go clean -modcache" takes care of the read-only files in
+		// the module cache.
+		cmd := goCommand(ctx, cfg, "", "clean", "-modcache")
+		if output, err := cmd.CombinedOutput(); err != nil {
+			logger.Error("Failed to clean module cache", "dir",
+				cfg.GoModCacheDir, "error", err, "output",
+				strings.TrimSpace(string(output)))
+			return
+		}
+		logger.Info("Cleaned module cache", "dir", cfg.GoModCacheDir,
+			"removed_bytes", size)
+	}
+}
+
+// cacheFile is a regular file in a cache directory.
+type cacheFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// pruneDirLRU removes the least recently modified files from dir until its
+// total size is at most maxBytes, and returns the number of bytes removed.
+// The Go build cache refreshes the modification time of the entries it uses,
+// so this removes the least recently used entries first.
+func pruneDirLRU(dir string, maxBytes int64) (int64, error) {
+	var (
+		files []cacheFile
+		total int64
+	)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry,
+		err error) error {
+
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files = append(files, cacheFile{
+			path:    path,
+			size:    info.Size(),
+			modTime: info.ModTime(),
+		})
+		total += info.Size()
+
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+
+	var removed int64
+	for _, file := range files {
+		if total-removed <= maxBytes {
+			break
+		}
+		if err := os.Remove(file.path); err != nil {
+			return removed, err
+		}
+		removed += file.size
+	}
+
+	return removed, nil
+}
+
+// dirSize returns the total size of the regular files in dir, or zero if dir
+// does not exist.
+func dirSize(dir string) (int64, error) {
+	var total int64
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry,
+		err error) error {
+
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		total += info.Size()
+
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+
+	return total, err
+}
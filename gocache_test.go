@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPruneDirLRU verifies that the least recently modified files are removed
+// until the directory fits within the size limit.
+func TestPruneDirLRU(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+
+	// Files named after their age in hours, 100 bytes each.
+	for _, age := range []int{3, 1, 4, 2} {
+		subDir := filepath.Join(dir, "0"+string(rune('0'+age)))
+		require.NoError(t, os.MkdirAll(subDir, 0755))
+
+		path := filepath.Join(subDir, "entry-a")
+		require.NoError(t, os.WriteFile(path, make([]byte, 100), 0644))
+
+		modTime := now.Add(-time.Duration(age) * time.Hour)
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+
+	removed, err := pruneDirLRU(dir, 250)
+	require.NoError(t, err)
+	assert.EqualValues(t, 200, removed)
+
+	assert.FileExists(t, filepath.Join(dir, "01", "entry-a"))
+	assert.FileExists(t, filepath.Join(dir, "02", "entry-a"))
+	assert.NoFileExists(t, filepath.Join(dir, "03", "entry-a"))
+	assert.NoFileExists(t, filepath.Join(dir, "04", "entry-a"))
+
+	size, err := dirSize(dir)
+	require.NoError(t, err)
+	assert.EqualValues(t, 200, size)
+
+	// A missing cache directory has nothing to prune.
+	removed, err = pruneDirLRU(filepath.Join(dir, "missing"), 0)
+	require.NoError(t, err)
+	assert.Zero(t, removed)
+}
+
+// TestGoEnv verifies that the cache directories and offline settings are
+// passed to go commands, preserving the user's GOFLAGS.
+func TestGoEnv(t *testing.T) {
+	t.Setenv("GOFLAGS", "-trimpath")
+
+	env := goEnv(&Config{
+		GoCacheDir:    "/cache/build",
+		GoModCacheDir: "/cache/mod",
+		GoProxyOff:    true,
+		GoModVendor:   true,
+	})
+	assert.Contains(t, env, "GOCACHE=/cache/build")
+	assert.Contains(t, env, "GOMODCACHE=/cache/mod")
+	assert.Contains(t, env, "GOPROXY=off")
+
+	// Later entries take precedence in exec.Cmd.Env.
+	assert.Equal(t, "GOFLAGS=-trimpath -mod=vendor", env[len(env)-1])
+
+	env = goEnv(&Config{})
+	assert.NotContains(t, env, "GOPROXY=off")
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// watchdogInterval is how often the watchdog checks for hung fuzz targets.
+const watchdogInterval = 30 * time.Second
+
+// Phases of the scheduler loop, reported by the status and checked by the
+// liveness probe.
+const (
+	phaseStarting    = "starting"
+	phaseSyncing     = "syncing repository"
+	phaseDownloading = "downloading corpus"
+	phaseBuilding    = "building fuzz targets"
+	phaseVerifying   = "verifying crash fixes"
+	phaseMinimizing  = "minimizing corpus"
+	phaseFuzzing     = "fuzzing"
+	phaseWaiting     = "waiting for the next cycle"
+	phaseUploading   = "uploading corpus"
+	phaseCoverage    = "measuring coverage"
+	phaseFinishing   = "finishing cycle"
+)
+
+// healthReport is the response of the health and readiness endpoints.
+type healthReport struct {
+	// Status is "ok", or "fail" if any check failed.
+	Status string `json:"status"`
+
+	// Problems describe the failed checks.
+	Problems []string `json:"problems,omitempty"`
+}
+
+// enterPhase records that the scheduler loop entered phase, which is expected
+// to complete within expected. The liveness probe fails once it overruns.
+func (s *fuzzStatus) enterPhase(phase string, expected time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.phase = phase
+	s.phaseDeadline = time.Now().Add(expected)
+}
+
+// setRepoSynced records whether the latest sync of the project repository
+// succeeded.
+func (s *fuzzStatus) setRepoSynced(synced bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.repoSynced = synced
+}
+
+// setStorageErr records the result of the latest corpus transfer.
+func (s *fuzzStatus) setStorageErr(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.storageErr = err
+}
+
+// setWorkersRunning records whether the fuzzing workers of the current cycle
+// are running.
+func (s *fuzzStatus) setWorkersRunning(running bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.workersRunning = running
+}
+
+// overdueWorkers returns the workers whose task overran its timeout by more
+// than the watchdog margin. The caller must hold the lock.
+func (s *fuzzStatus) overdueWorkers(now time.Time) []workerStatus {
+	var overdue []workerStatus
+	for _, worker := range s.workers {
+		if now.Sub(worker.Started) > worker.Timeout+s.watchdogMargin {
+			overdue = append(overdue, worker)
+		}
+	}
+
+	return overdue
+}
+
+// liveness checks that the scheduler loop is within the expected time of its
+// current phase, and that no fuzz target overran its timeout by more than the
+// watchdog margin.
+func (s *fuzzStatus) liveness() healthReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var problems []string
+	if !s.phaseDeadline.IsZero() && now.After(s.phaseDeadline) {
+		problems = append(problems, fmt.Sprintf("scheduler stuck in "+
+			"phase %q for %s", s.phase,
+			now.Sub(s.phaseDeadline).Round(time.Second)))
+	}
+	for _, worker := range s.overdueWorkers(now) {
+		problems = append(problems, fmt.Sprintf("worker %d running "+
+			"%s/%s overran its timeout of %s", worker.ID,
+			worker.Task.Package, worker.Task.Target,
+			worker.Timeout))
+	}
+
+	return newHealthReport(problems)
+}
+
+// readiness checks that the corpus storage is reachable, the project
+// repository is synced and the fuzzing workers are running.
+func (s *fuzzStatus) readiness() healthReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var problems []string
+	if s.storageErr != nil {
+		problems = append(problems, "storage unreachable: "+
+			s.storageErr.Error())
+	}
+	if !s.repoSynced {
+		problems = append(problems, "repository not synced")
+	}
+	if !s.workersRunning {
+		problems = append(problems, "workers not running")
+	}
+
+	return newHealthReport(problems)
+}
+
+// newHealthReport returns the report of checks that found the problems.
+func newHealthReport(problems []string) healthReport {
+	if len(problems) > 0 {
+		return healthReport{Status: "fail", Problems: problems}
+	}
+
+	return healthReport{Status: "ok"}
+}
+
+// registerHealth adds the liveness and readiness endpoints to mux.
+func (s *fuzzStatus) registerHealth(mux *http.ServeMux) {
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter,
+		_ *http.Request) {
+
+		writeHealthReport(w, s.liveness())
+	})
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter,
+		_ *http.Request) {
+
+		writeHealthReport(w, s.readiness())
+	})
+}
+
+// writeHealthReport writes the report, with the status code telling whether
+// all checks passed.
+func writeHealthReport(w http.ResponseWriter, report healthReport) {
+	if report.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	writeJSON(w, report)
+}
+
+// runWatchdog periodically logs the fuzz targets that overran their timeout
+// by more than the watchdog margin, until ctx is canceled. Each overrun is
+// logged once.
+func (s *fuzzStatus) runWatchdog(ctx context.Context, logger *slog.Logger) {
+	ticker := time.NewTicker(watchdogInterval)
+	defer ticker.Stop()
+
+	// flagged holds the start times of the overrunning tasks already
+	// logged, by worker.
+	flagged := make(map[int]time.Time)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+		}
+
+		s.mu.Lock()
+		overdue := s.overdueWorkers(time.Now())
+		s.mu.Unlock()
+
+		for _, worker := range overdue {
+			if flagged[worker.ID].Equal(worker.Started) {
+				continue
+			}
+			flagged[worker.ID] = worker.Started
+
+			logger.Error("Fuzz target overran its timeout; it may "+
+				"be hung", "workerID", worker.ID, "package",
+				worker.Task.Package, "target",
+				worker.Task.Target, "timeout", worker.Timeout,
+				"running", time.Since(worker.Started).Round(
+					time.Second))
+		}
+	}
+}
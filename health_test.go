@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// getHealth fetches a health endpoint, returning its status code and report.
+func getHealth(t *testing.T, server *httptest.Server,
+	path string) (int, healthReport) {
+
+	t.Helper()
+
+	resp, err := http.Get(server.URL + path)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var report healthReport
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+
+	return resp.StatusCode, report
+}
+
+// TestHealthz verifies that the liveness probe fails while the scheduler loop
+// overruns the expected time of its phase, or a fuzz target overruns its
+// timeout by more than the watchdog margin.
+func TestHealthz(t *testing.T) {
+	status := newFuzzStatus(&Config{
+		FuzzResultsPath: t.TempDir(),
+		WatchdogMargin:  time.Minute,
+	})
+	server := httptest.NewServer(newHTTPHandler(newFuzzMetrics(1),
+		status))
+	defer server.Close()
+
+	code, report := getHealth(t, server, "/healthz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok", report.Status)
+
+	// A fuzz target running within its timeout and the margin is fine.
+	task := Task{Package: "parser", Target: "FuzzParse"}
+	status.enterPhase(phaseFuzzing, time.Hour)
+	status.workerStarted(1, task, time.Minute)
+	code, _ = getHealth(t, server, "/healthz")
+	assert.Equal(t, http.StatusOK, code)
+
+	// Once it overran both, it's considered hung.
+	status.mu.Lock()
+	worker := status.workers[1]
+	worker.Started = time.Now().Add(-3 * time.Minute)
+	status.workers[1] = worker
+	status.mu.Unlock()
+
+	code, report = getHealth(t, server, "/healthz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "fail", report.Status)
+	require.Len(t, report.Problems, 1)
+	assert.Contains(t, report.Problems[0], "parser/FuzzParse")
+
+	status.workerFinished(1)
+	code, _ = getHealth(t, server, "/healthz")
+	assert.Equal(t, http.StatusOK, code)
+
+	// A phase running past its deadline means the loop is stuck.
+	status.enterPhase(phaseSyncing, -time.Second)
+	code, report = getHealth(t, server, "/healthz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	require.Len(t, report.Problems, 1)
+	assert.Contains(t, report.Problems[0], phaseSyncing)
+}
+
+// TestReadyz verifies that the readiness probe passes only while the storage is
+// reachable, the repository is synced and the workers are running.
+func TestReadyz(t *testing.T) {
+	status := newFuzzStatus(&Config{FuzzResultsPath: t.TempDir()})
+	server := httptest.NewServer(newHTTPHandler(newFuzzMetrics(1),
+		status))
+	defer server.Close()
+
+	code, report := getHealth(t, server, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Len(t, report.Problems, 2)
+
+	status.setRepoSynced(true)
+	status.setWorkersRunning(true)
+	code, report = getHealth(t, server, "/readyz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok", report.Status)
+	assert.Empty(t, report.Problems)
+
+	status.setStorageErr(errors.New("connection refused"))
+	code, report = getHealth(t, server, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, []string{"storage unreachable: connection refused"},
+		report.Problems)
+}
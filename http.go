@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+// httpShutdownTimeout bounds the time spent waiting for in-flight requests
+// when the HTTP server shuts down.
+const httpShutdownTimeout = 5 * time.Second
+
+// newHTTPHandler returns the handler of the HTTP listener, serving the
+// metrics at /metrics, and the status API and dashboard.
+func newHTTPHandler(m *fuzzMetrics, status *fuzzStatus) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", m)
+	status.register(mux)
+
+	return mux
+}
+
+// startHTTPServer serves handler on addr until ctx is canceled, and returns
+// the address it listens on.
+func startHTTPServer(ctx context.Context, logger *slog.Logger, addr string,
+	handler http.Handler) (net.Addr, error) {
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listening on %q: %w", addr, err)
+	}
+
+	server := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		err := server.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("HTTP server failed", "error", err)
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(
+			context.Background(), httpShutdownTimeout)
+		defer cancel()
+
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			logger.Error("HTTP server shutdown failed", "error",
+				err)
+		}
+	}()
+
+	logger.Info("HTTP server listening", "address", listener.Addr())
+
+	return listener.Addr(), nil
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	// logFormatText writes logs as logfmt-style key=value pairs.
+	logFormatText = "text"
+
+	// logFormatJSON writes logs as one JSON object per line.
+	logFormatJSON = "json"
+
+	// fuzzerOutputOff disables logging the output of fuzzing processes.
+	fuzzerOutputOff = "off"
+)
+
+// newLogger returns the logger configured by cfg, and the file it writes to,
+// which must be closed on exit. The file is nil if logs go to the standard
+// output.
+func newLogger(cfg *Config) (*slog.Logger, io.Closer, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.LogLevel)); err != nil {
+		return nil, nil, fmt.Errorf("invalid log level %q: %w",
+			cfg.LogLevel, err)
+	}
+
+	var (
+		w      io.Writer = os.Stdout
+		closer io.Closer
+	)
+	if cfg.LogFile != "" {
+		file, err := newRotatingFile(cfg.LogFile,
+			int64(cfg.LogMaxSizeMB)*bytesPerMB, cfg.LogMaxBackups)
+		if err != nil {
+			return nil, nil, err
+		}
+		w, closer = file, file
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	if cfg.LogFormat == logFormatJSON {
+		return slog.New(slog.NewJSONHandler(w, opts)), closer, nil
+	}
+
+	return slog.New(slog.NewTextHandler(w, opts)), closer, nil
+}
+
+// logFuzzerOutput logs a line of the output of a fuzzing process at the level
+// set by cfg.FuzzerOutputLevel, unless it is off. The level defaults to info.
+func logFuzzerOutput(logger *slog.Logger, cfg *Config, line string) {
+	if cfg.FuzzerOutputLevel == fuzzerOutputOff {
+		return
+	}
+
+	// The level is one of the choices validated by the flag parser.
+	level := slog.LevelInfo
+	if cfg.FuzzerOutputLevel != "" {
+		_ = level.UnmarshalText([]byte(cfg.FuzzerOutputLevel))
+	}
+
+	logger.Log(context.Background(), level, "Fuzzer output", "message",
+		line)
+}
+
+// rotatingFile is a log file that is rotated once it grows beyond a maximum
+// size. Rotated files are renamed to <path>.1, <path>.2 and so on, the lowest
+// number being the most recent. It is safe for concurrent use.
+type rotatingFile struct {
+	mu sync.Mutex
+
+	// path is the path of the current log file.
+	path string
+
+	// maxSize is the size in bytes beyond which the file is rotated. The
+	// file is never rotated if it is 0.
+	maxSize int64
+
+	// maxBackups is the number of rotated files kept.
+	maxBackups int
+
+	// file is the current log file, and size its size.
+	file *os.File
+	size int64
+}
+
+// newRotatingFile opens the log file at path, appending to it if it exists.
+func newRotatingFile(path string, maxSize int64,
+	maxBackups int) (*rotatingFile, error) {
+
+	if err := EnsureDirExists(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+
+	f := &rotatingFile{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// open opens the current log file. The caller must hold the lock, if the file
+// is in use.
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND,
+		0644)
+	if err != nil {
+		return fmt.Errorf("opening log file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("opening log file: %w", err)
+	}
+
+	f.file = file
+	f.size = info.Size()
+
+	return nil
+}
+
+// Write appends p to the log file, rotating it first if p would make it grow
+// beyond the maximum size.
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+
+	return n, err
+}
+
+// rotate moves the current log file to the first backup, shifting the older
+// backups and dropping the oldest one, and opens a new current file. The
+// caller must hold the lock.
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("closing log file: %w", err)
+	}
+
+	if f.maxBackups <= 0 {
+		if err := os.Remove(f.path); err != nil {
+			return fmt.Errorf("removing log file: %w", err)
+		}
+		return f.open()
+	}
+
+	for i := f.maxBackups - 1; i > 0; i-- {
+		err := os.Rename(f.backupPath(i), f.backupPath(i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("rotating log file: %w", err)
+		}
+	}
+	if err := os.Rename(f.path, f.backupPath(1)); err != nil {
+		return fmt.Errorf("rotating log file: %w", err)
+	}
+
+	return f.open()
+}
+
+// backupPath returns the path of the i-th most recent backup.
+func (f *rotatingFile) backupPath(i int) string {
+	return fmt.Sprintf("%s.%d", f.path, i)
+}
+
+// Close closes the current log file.
+func (f *rotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.file.Close()
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRotatingFile verifies that the log file is rotated before it grows
+// beyond the maximum size, and that only the configured number of backups is
+// kept.
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "fuzz.log")
+	f, err := newRotatingFile(path, 10, 2)
+	require.NoError(t, err)
+
+	for _, line := range []string{"first\n", "second\n", "third\n",
+		"fourth\n"} {
+
+		_, err := f.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	require.NoError(t, f.Close())
+
+	read := func(path string) string {
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		return string(data)
+	}
+	assert.Equal(t, "fourth\n", read(path))
+	assert.Equal(t, "third\n", read(path+".1"))
+	assert.Equal(t, "second\n", read(path+".2"))
+	assert.NoFileExists(t, path+".3")
+
+	// Reopening the file appends to it, counting its existing size.
+	f, err = newRotatingFile(path, 10, 2)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("fifth\n"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	assert.Equal(t, "fifth\n", read(path))
+	assert.Equal(t, "fourth\n", read(path+".1"))
+	assert.Equal(t, "third\n", read(path+".2"))
+}
+
+// TestRotatingFileNoBackups verifies that the log file is truncated on
+// rotation if no backups are kept.
+func TestRotatingFileNoBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fuzz.log")
+	f, err := newRotatingFile(path, 10, 0)
+	require.NoError(t, err)
+
+	for _, line := range []string{"first\n", "second\n"} {
+		_, err := f.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	require.NoError(t, f.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "second\n", string(data))
+	assert.NoFileExists(t, path+".1")
+}
+
+// TestNewLogger verifies that the logger writes JSON to the log file and drops
+// messages below the configured level.
+func TestNewLogger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fuzz.log")
+	logger, closer, err := newLogger(&Config{
+		LogFormat:     logFormatJSON,
+		LogLevel:      "warn",
+		LogFile:       path,
+		LogMaxSizeMB:  1,
+		LogMaxBackups: 1,
+	})
+	require.NoError(t, err)
+	require.NotNil(t, closer)
+
+	logger.Info("Dropped")
+	logger.Warn("Kept", "target", "FuzzFoo")
+	require.NoError(t, closer.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 1)
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal(t, "WARN", entry["level"])
+	assert.Equal(t, "Kept", entry["msg"])
+	assert.Equal(t, "FuzzFoo", entry["target"])
+}
+
+// TestLogFuzzerOutput verifies that fuzzer output lines are logged at the
+// configured level, or not at all if it is off.
+func TestLogFuzzerOutput(t *testing.T) {
+	tests := []struct {
+		level string
+		want  string
+	}{
+		{level: "", want: "level=INFO"},
+		{level: "debug", want: "level=DEBUG"},
+		{level: "error", want: "level=ERROR"},
+		{level: fuzzerOutputOff, want: ""},
+	}
+	for _, tc := range tests {
+		var buf bytes.Buffer
+		logger := slog.New(slog.NewTextHandler(&buf,
+			&slog.HandlerOptions{Level: slog.LevelDebug}))
+
+		logFuzzerOutput(logger, &Config{FuzzerOutputLevel: tc.level},
+			"fuzz: elapsed: 3s")
+
+		if tc.want == "" {
+			assert.Empty(t, buf.String(), tc.level)
+			continue
+		}
+		assert.Contains(t, buf.String(), tc.want, tc.level)
+		assert.Contains(t, buf.String(), `message="fuzz: elapsed: 3s"`)
+	}
+}
@@ -12,11 +12,28 @@ import (
 	flags "github.com/jessevdk/go-flags"
 )
 
-// main is the entry point of the application. It sets up signal handling for
-// graceful shutdown, loads configuration, and starts the continuous fuzzing
-// cycles.
+// main is the entry point of the application. It runs the daemon or the given
+// command, and exits with a non-zero status if it failed.
 func main() {
-	// Initialize a structured logger that outputs logs in text format.
+	// When re-executed as the init process of a sandbox, set it up and
+	// hand over to the fuzzing process.
+	if len(os.Args) > 1 && os.Args[1] == sandboxInitArg {
+		runSandboxInit()
+	}
+
+	// Exit only once run returned, so that its deferred calls flush the
+	// traces and close the log file.
+	if err := run(); err != nil {
+		os.Exit(1)
+	}
+}
+
+// run sets up signal handling for graceful shutdown, loads configuration, and
+// starts the continuous fuzzing cycles or runs the given command. Errors are
+// logged before being returned.
+func run() error {
+	// Initialize a structured logger that outputs logs in text format,
+	// until the configured one is set up.
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 
 	// Load configuration settings from environment variables or command
@@ -26,18 +43,45 @@ func main() {
 		var fe *flags.Error
 		if errors.As(err, &fe) && fe.Type == flags.ErrHelp {
 			// help requested
-			os.Exit(0)
+			return nil
 		}
 
 		// Print error if not due to help request.
 		logger.Error("Failed to load configuration", "error", err)
-		os.Exit(1)
+		return err
+	}
+
+	// Switch to the logger configured by the user.
+	logger, logFile, err := newLogger(cfg)
+	if err != nil {
+		slog.Error("Failed to set up logging", "error", err)
+		return err
+	}
+	if logFile != nil {
+		defer logFile.Close()
 	}
 
 	// Create a cancellable context to manage the application's lifecycle.
 	appCtx, cancelApp := context.WithCancel(context.Background())
 	defer cancelApp()
 
+	// Export the traces of the cycles, if enabled. The pending spans are
+	// flushed on exit.
+	shutdownTracing, err := setupTracing(appCtx, cfg)
+	if err != nil {
+		logger.Error("Failed to set up tracing", "error", err)
+		return err
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(),
+			tracingShutdownTimeout)
+		defer cancel()
+
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Error("Failed to flush traces", "error", err)
+		}
+	}()
+
 	// Set up signal handling for graceful shutdown on SIGINT and SIGTERM.
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
@@ -48,8 +92,42 @@ func main() {
 		cancelApp()
 	}()
 
+	// Run the command given on the command line, if any, instead of the
+	// fuzzing daemon.
+	if cfg.command != "" {
+		err := runCommand(appCtx, logger, cfg)
+		cleanupWorkspace(logger, cfg)
+		if err != nil {
+			logger.Error("Command failed", "command", cfg.command,
+				"error", err)
+			return err
+		}
+		return nil
+	}
+
+	// Collect the metrics and status of the daemon, and serve them if the
+	// HTTP listener is enabled.
+	m := newFuzzMetrics(cfg.NumWorkers)
+	status := newFuzzStatus(cfg)
+	go status.runWatchdog(appCtx, logger)
+	if cfg.HTTPListen != "" {
+		_, err := startHTTPServer(appCtx, logger, cfg.HTTPListen,
+			newHTTPHandler(m, status))
+		if err != nil {
+			logger.Error("Failed to start HTTP server", "error",
+				err)
+			return err
+		}
+	}
+
 	// Start the continuous fuzzing cycles.
-	startFuzzCycles(appCtx, logger, cfg, cfg.SyncFrequency)
+	err = startFuzzCycles(appCtx, logger, cfg, m, status,
+		cfg.SyncFrequency)
+	if err != nil {
+		logger.Error("Fuzzing cycles failed", "error", err)
+		return err
+	}
 
 	logger.Info("Program exited.")
+	return nil
 }
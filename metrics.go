@@ -0,0 +1,406 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metricsNamespace prefixes the names of all exported metrics.
+const metricsNamespace = "go_continuous_fuzz_"
+
+// metricsContentType is the content type of the Prometheus text exposition
+// format.
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// metricKind is the type of a metric in the exposition format.
+type metricKind string
+
+const (
+	metricCounter   metricKind = "counter"
+	metricGauge     metricKind = "gauge"
+	metricHistogram metricKind = "histogram"
+)
+
+var (
+	// cycleDurationBuckets are the histogram buckets of cycle durations,
+	// in seconds.
+	cycleDurationBuckets = []float64{60, 300, 600, 1800, 3600, 7200,
+		21600, 86400}
+
+	// cloneDurationBuckets are the histogram buckets of repository clone
+	// durations, in seconds.
+	cloneDurationBuckets = []float64{1, 5, 10, 30, 60, 120, 300, 600}
+
+	// s3DurationBuckets are the histogram buckets of S3 transfer
+	// latencies, in seconds.
+	s3DurationBuckets = []float64{0.1, 0.5, 1, 5, 10, 30, 60, 300}
+)
+
+// metricSeries is a single labeled series of a metric family.
+type metricSeries struct {
+	labelValues []string
+
+	// value is the value of a counter or gauge.
+	value float64
+
+	// bucketCounts, sum and count are the observations of a histogram.
+	// bucketCounts are not cumulative.
+	bucketCounts []uint64
+	sum          float64
+	count        uint64
+}
+
+// metricFamily is a metric with all its labeled series.
+type metricFamily struct {
+	name    string
+	help    string
+	kind    metricKind
+	labels  []string
+	buckets []float64
+	series  map[string]*metricSeries
+}
+
+// with returns the series with the given label values, creating it if needed.
+func (f *metricFamily) with(labelValues ...string) *metricSeries {
+	key := strings.Join(labelValues, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &metricSeries{labelValues: labelValues}
+		if f.kind == metricHistogram {
+			s.bucketCounts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+
+	return s
+}
+
+// observe adds an observation to the histogram series with the given label
+// values.
+func (f *metricFamily) observe(v float64, labelValues ...string) {
+	s := f.with(labelValues...)
+	for i, le := range f.buckets {
+		if v <= le {
+			s.bucketCounts[i]++
+			break
+		}
+	}
+	s.sum += v
+	s.count++
+}
+
+// write writes the family in the text exposition format.
+func (f *metricFamily) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", f.name, f.help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.kind)
+
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := f.series[key]
+		if f.kind != metricHistogram {
+			fmt.Fprintf(w, "%s%s %s\n", f.name,
+				formatLabels(f.labels, s.labelValues),
+				formatMetricValue(s.value))
+			continue
+		}
+
+		bucketLabels := append(slices.Clone(f.labels), "le")
+		bucketValue := func(le string) string {
+			return formatLabels(bucketLabels, append(
+				slices.Clone(s.labelValues), le))
+		}
+
+		var cumulative uint64
+		for i, le := range f.buckets {
+			cumulative += s.bucketCounts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", f.name,
+				bucketValue(formatMetricValue(le)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, bucketValue("+Inf"),
+			s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", f.name,
+			formatLabels(f.labels, s.labelValues),
+			formatMetricValue(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", f.name,
+			formatLabels(f.labels, s.labelValues), s.count)
+	}
+}
+
+// formatLabels formats a label set, e.g. `{package="parser",target="Fuzz"}`.
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + `="` + labelValueReplacer.Replace(values[i]) +
+			`"`
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// labelValueReplacer escapes label values for the exposition format.
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n",
+	`\n`)
+
+// formatMetricValue formats a sample value for the exposition format.
+func formatMetricValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// fuzzMetrics collects the metrics of the daemon and serves them in the
+// Prometheus text exposition format. It is safe for concurrent use.
+type fuzzMetrics struct {
+	mu sync.Mutex
+
+	// families are all metric families, in exposition order.
+	families []*metricFamily
+
+	// stats are the statistics of the running cycle, from which the
+	// per-target metrics are collected when scraped.
+	stats *cycleStats
+
+	cyclesTotal          *metricFamily
+	cyclesFailedTotal    *metricFamily
+	cycleDuration        *metricFamily
+	cloneDuration        *metricFamily
+	s3TransferBytes      *metricFamily
+	s3TransferDuration   *metricFamily
+	crashesTotal         *metricFamily
+	workers              *metricFamily
+	workersBusy          *metricFamily
+	workerBusySeconds    *metricFamily
+	targetExecsPerSec    *metricFamily
+	targetExecs          *metricFamily
+	targetCorpusSize     *metricFamily
+	targetNewInteresting *metricFamily
+}
+
+// newFuzzMetrics returns the metrics of a daemon running numWorkers workers.
+func newFuzzMetrics(numWorkers int) *fuzzMetrics {
+	m := &fuzzMetrics{}
+
+	m.cyclesTotal = m.family("cycles_total", "Number of fuzzing "+
+		"cycles started.", metricCounter, nil)
+	m.cyclesFailedTotal = m.family("cycles_failed_total", "Number of "+
+		"fuzzing cycles that failed.", metricCounter, nil)
+	m.cycleDuration = m.family("cycle_duration_seconds", "Duration of "+
+		"completed fuzzing cycles.", metricHistogram, nil,
+		cycleDurationBuckets...)
+	m.cloneDuration = m.family("clone_duration_seconds", "Duration of "+
+		"cloning the project repository.", metricHistogram, nil,
+		cloneDurationBuckets...)
+	m.s3TransferBytes = m.family("s3_transfer_bytes_total", "Number of "+
+		"corpus bytes transferred to or from S3.", metricCounter,
+		[]string{"direction"})
+	m.s3TransferDuration = m.family("s3_transfer_duration_seconds",
+		"Latency of corpus transfers to or from S3.", metricHistogram,
+		[]string{"direction"}, s3DurationBuckets...)
+	m.crashesTotal = m.family("crashes_total", "Number of crashes "+
+		"found, by class and whether they were new, known or "+
+		"regressed.", metricCounter, []string{"class", "kind"})
+	m.workers = m.family("workers", "Number of fuzzing workers.",
+		metricGauge, nil)
+	m.workersBusy = m.family("workers_busy", "Number of fuzzing "+
+		"workers running a fuzz target.", metricGauge, nil)
+	m.workerBusySeconds = m.family("worker_busy_seconds_total", "Time "+
+		"spent by workers running fuzz targets.", metricCounter, nil)
+	m.targetExecsPerSec = m.family("target_execs_per_second", "Current "+
+		"execution rate of a fuzz target, or its average over the "+
+		"cycle if not running.", metricGauge, targetLabels)
+	m.targetExecs = m.family("target_execs", "Number of inputs "+
+		"executed by a fuzz target in the current cycle.", metricGauge,
+		targetLabels)
+	m.targetCorpusSize = m.family("target_corpus_size", "Corpus size "+
+		"of a fuzz target.", metricGauge, targetLabels)
+	m.targetNewInteresting = m.family("target_new_interesting", "Number "+
+		"of new interesting inputs found by a fuzz target in the "+
+		"current cycle.", metricGauge, targetLabels)
+
+	m.workers.with().value = float64(numWorkers)
+
+	return m
+}
+
+// targetLabels are the labels of per-target metrics.
+var targetLabels = []string{"package", "target"}
+
+// family registers a new metric family.
+func (m *fuzzMetrics) family(name, help string, kind metricKind,
+	labels []string, buckets ...float64) *metricFamily {
+
+	f := &metricFamily{
+		name:    metricsNamespace + name,
+		help:    help,
+		kind:    kind,
+		labels:  labels,
+		buckets: buckets,
+		series:  make(map[string]*metricSeries),
+	}
+	m.families = append(m.families, f)
+
+	// Expose unlabeled metrics before their first update.
+	if len(labels) == 0 {
+		f.with()
+	}
+
+	return f
+}
+
+// cycleStarted counts a new cycle whose per-target statistics are stats.
+func (m *fuzzMetrics) cycleStarted(stats *cycleStats) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.cyclesTotal.with().value++
+	m.stats = stats
+}
+
+// cycleCompleted records the duration of a completed cycle.
+func (m *fuzzMetrics) cycleCompleted(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.cycleDuration.observe(d.Seconds())
+}
+
+// cycleFailed counts a failed cycle.
+func (m *fuzzMetrics) cycleFailed() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.cyclesFailedTotal.with().value++
+}
+
+// observeClone records the duration of cloning the project repository.
+func (m *fuzzMetrics) observeClone(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.cloneDuration.observe(d.Seconds())
+}
+
+// observeS3Transfer records a corpus transfer of n bytes in the given
+// direction, "upload" or "download".
+func (m *fuzzMetrics) observeS3Transfer(direction string, n int64,
+	d time.Duration) {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.s3TransferBytes.with(direction).value += float64(n)
+	m.s3TransferDuration.observe(d.Seconds(), direction)
+}
+
+// crashFound counts a recorded crash.
+func (m *fuzzMetrics) crashFound(crash *fuzzCrash) {
+	if crash.Record == nil {
+		return
+	}
+
+	kind := "known"
+	switch {
+	case crash.IsNew:
+		kind = "new"
+
+	case crash.Regression:
+		kind = "regression"
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.crashesTotal.with(string(crash.Record.Class), kind).value++
+}
+
+// workerStarted records that a worker started running a fuzz target.
+func (m *fuzzMetrics) workerStarted() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.workersBusy.with().value++
+}
+
+// workerFinished records that a worker finished running a fuzz target for d.
+func (m *fuzzMetrics) workerFinished(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.workersBusy.with().value--
+	m.workerBusySeconds.with().value += d.Seconds()
+}
+
+// collectTargets refreshes the per-target metrics from the statistics of the
+// running cycle. The caller must hold the lock.
+func (m *fuzzMetrics) collectTargets() {
+	for _, f := range []*metricFamily{m.targetExecsPerSec, m.targetExecs,
+		m.targetCorpusSize, m.targetNewInteresting} {
+
+		f.series = make(map[string]*metricSeries)
+	}
+	if m.stats == nil {
+		return
+	}
+
+	for _, ts := range m.stats.snapshot() {
+		execsPerSec := ts.ExecsPerSec()
+		execs := ts.Execs
+		corpusSize := ts.CorpusSize
+		newInteresting := ts.NewInteresting
+		if p := ts.Current; p != nil {
+			execsPerSec = float64(p.ExecsPerSec)
+			execs += p.Execs
+			corpusSize = p.TotalCorpus
+			newInteresting += p.NewInteresting
+		}
+
+		m.targetExecsPerSec.with(ts.Package, ts.Target).value =
+			execsPerSec
+		m.targetExecs.with(ts.Package, ts.Target).value =
+			float64(execs)
+		m.targetCorpusSize.with(ts.Package, ts.Target).value =
+			float64(corpusSize)
+		m.targetNewInteresting.with(ts.Package, ts.Target).value =
+			float64(newInteresting)
+	}
+}
+
+// write writes all metrics in the text exposition format.
+func (m *fuzzMetrics) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.collectTargets()
+	for _, f := range m.families {
+		f.write(w)
+	}
+}
+
+// ServeHTTP serves the metrics to a Prometheus scrape.
+func (m *fuzzMetrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", metricsContentType)
+	m.write(w)
+}
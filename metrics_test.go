@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMetricsEndpoint verifies that the recorded metrics are served in the
+// Prometheus text exposition format by a local HTTP server.
+func TestMetricsEndpoint(t *testing.T) {
+	m := newFuzzMetrics(2)
+
+	stats := newCycleStats(1, "abc123")
+	m.cycleStarted(stats)
+	m.observeClone(3 * time.Second)
+	m.observeS3Transfer("download", 1024, 200*time.Millisecond)
+	m.observeS3Transfer("upload", 2048, 2*time.Second)
+	m.workerStarted()
+	m.workerStarted()
+	m.workerFinished(time.Minute)
+	m.crashFound(&fuzzCrash{
+		Record: &crashRecord{Class: crashClassPanic},
+		IsNew:  true,
+	})
+	m.crashFound(&fuzzCrash{Record: &crashRecord{Class: crashClassPanic}})
+	m.cycleCompleted(90 * time.Second)
+
+	// One target finished its run, the other one is still running.
+	done := Task{Package: "parser", Target: "FuzzParse"}
+	stats.update(done, fuzzProgress{
+		Elapsed:        10 * time.Second,
+		Execs:          5000,
+		NewInteresting: 4,
+		TotalCorpus:    40,
+	})
+	stats.finishRun(done, targetStatusOK)
+	stats.update(Task{Package: "lexer", Target: "FuzzLex"}, fuzzProgress{
+		Elapsed:        2 * time.Second,
+		Execs:          300,
+		ExecsPerSec:    150,
+		NewInteresting: 1,
+		TotalCorpus:    7,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	status := newFuzzStatus(&Config{FuzzResultsPath: t.TempDir()})
+	addr, err := startHTTPServer(ctx, logger, "127.0.0.1:0",
+		newHTTPHandler(m, status))
+	require.NoError(t, err)
+
+	resp, err := http.Get("http://" + addr.String() + "/metrics")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, metricsContentType, resp.Header.Get("Content-Type"))
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	// Compare the samples without the namespace of their names.
+	var lines []string
+	for _, line := range strings.Split(string(body), "\n") {
+		line = strings.Replace(line, metricsNamespace, "", 1)
+		lines = append(lines, line)
+	}
+
+	for _, expected := range []string{
+		"# TYPE cycles_total counter",
+		"cycles_total 1",
+		"cycles_failed_total 0",
+		"# TYPE cycle_duration_seconds histogram",
+		`cycle_duration_seconds_bucket{le="60"} 0`,
+		`cycle_duration_seconds_bucket{le="300"} 1`,
+		`cycle_duration_seconds_bucket{le="+Inf"} 1`,
+		"cycle_duration_seconds_sum 90",
+		"cycle_duration_seconds_count 1",
+		"clone_duration_seconds_count 1",
+		`s3_transfer_bytes_total{direction="download"} 1024`,
+		`s3_transfer_bytes_total{direction="upload"} 2048`,
+		`s3_transfer_duration_seconds_bucket{direction="download",` +
+			`le="0.5"} 1`,
+		`crashes_total{class="panic",kind="known"} 1`,
+		`crashes_total{class="panic",kind="new"} 1`,
+		"workers 2",
+		"workers_busy 1",
+		"worker_busy_seconds_total 60",
+		`target_execs_per_second{package="lexer",target="FuzzLex"} ` +
+			`150`,
+		`target_execs_per_second{package="parser",` +
+			`target="FuzzParse"} 500`,
+		`target_execs{package="parser",target="FuzzParse"} 5000`,
+		`target_corpus_size{package="lexer",target="FuzzLex"} 7`,
+		`target_new_interesting{package="parser",target="FuzzParse"} ` +
+			`4`,
+	} {
+		assert.Contains(t, lines, expected)
+	}
+}
+
+// TestFormatLabels verifies that label values are escaped.
+func TestFormatLabels(t *testing.T) {
+	assert.Equal(t, `{a="x\\y",b="\"q\"\n"}`, formatLabels(
+		[]string{"a", "b"}, []string{`x\y`, "\"q\"\n"}))
+	assert.Empty(t, formatLabels(nil, nil))
+}
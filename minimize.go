@@ -0,0 +1,438 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"golang.org/x/sync/errgroup"
+)
+
+// targetMinimization summarizes the minimization of a fuzz target's corpus.
+type targetMinimization struct {
+	// Package is the package of the fuzz target.
+	Package string
+
+	// Target is the fuzz target.
+	Target string
+
+	// Inputs is the number of inputs in the corpus before minimization.
+	Inputs int
+
+	// Kept is the number of inputs kept.
+	Kept int
+}
+
+// corpusInput is an input of a corpus being minimized.
+type corpusInput struct {
+	// name is the file name of the input.
+	name string
+
+	// size is the size of the input file.
+	size int64
+
+	// blocks are the positions of the coverage blocks the input executes.
+	blocks []string
+
+	// keep is set if the input must be kept regardless of its coverage,
+	// because replaying it failed.
+	keep bool
+}
+
+// minimizeCorpus minimizes the corpus of every fuzz target in cfg.CorpusDir.
+// Each input is replayed on its own with coverage enabled, and only the
+// inputs that cover blocks neither the seed corpus nor the inputs kept before
+// cover are kept. The removed inputs are moved into archiveDir, keeping their
+// path relative to the corpus directory. Targets whose corpus could not be
+// minimized are logged and left untouched.
+func minimizeCorpus(ctx context.Context, logger *slog.Logger, cfg *Config,
+	pkgTargets map[string][]string,
+	archiveDir string) ([]targetMinimization, error) {
+
+	pkgs := make([]string, 0, len(pkgTargets))
+	for pkg := range pkgTargets {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+
+	var results []targetMinimization
+	for _, pkg := range pkgs {
+		bin, err := buildCoverageBinary(ctx, cfg, pkg)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			logger.Error("Failed to build coverage binary; "+
+				"skipping package", "package", pkg, "error",
+				err)
+			continue
+		}
+
+		for _, target := range pkgTargets[pkg] {
+			res, err := minimizeTargetCorpus(ctx, logger, cfg, bin,
+				pkg, target, archiveDir)
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if err != nil {
+				logger.Error("Failed to minimize corpus",
+					"package", pkg, "target", target,
+					"error", err)
+				continue
+			}
+
+			results = append(results, res)
+		}
+	}
+
+	return results, nil
+}
+
+// buildCoverageBinary compiles the package's test binary with coverage
+// enabled and returns its path.
+func buildCoverageBinary(ctx context.Context, cfg *Config,
+	pkg string) (string, error) {
+
+	if err := EnsureDirExists(cfg.BinDir); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(cfg.BinDir,
+		strings.ReplaceAll(pkg, "/", "_")+"_cover.test")
+	cmd := goCommand(ctx, cfg, filepath.Join(cfg.ProjectDir, pkg), "test",
+		"-c", "-cover", "-o", path, ".")
+
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	if err := cmd.Run(); err != nil {
+		return "", &testBuildError{
+			pkg:    pkg,
+			output: strings.TrimSpace(output.String()),
+			err:    err,
+		}
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return "", fmt.Errorf("package %q has no test files", pkg)
+	}
+
+	return path, nil
+}
+
+// minimizeTargetCorpus minimizes the corpus of a fuzz target, replaying its
+// inputs through the coverage binary bin of its package.
+func minimizeTargetCorpus(ctx context.Context, logger *slog.Logger,
+	cfg *Config, bin, pkg, target,
+	archiveDir string) (targetMinimization, error) {
+
+	res := targetMinimization{Package: pkg, Target: target}
+
+	relDir := filepath.Join(pkg, "testdata", "fuzz", target)
+	corpusDir := filepath.Join(cfg.CorpusDir, relDir)
+	entries, err := os.ReadDir(corpusDir)
+	if os.IsNotExist(err) {
+		return res, nil
+	}
+	if err != nil {
+		return res, fmt.Errorf("reading corpus: %w", err)
+	}
+
+	profileDir, err := os.MkdirTemp(cfg.BinDir, "coverage-")
+	if err != nil {
+		return res, err
+	}
+	defer os.RemoveAll(profileDir)
+
+	// The seed corpus is always replayed by the fuzzer, so the inputs
+	// only need to cover what it misses.
+	pkgPath := filepath.Join(cfg.ProjectDir, pkg)
+	seedBlocks, err := replayInputCoverage(ctx, bin, pkgPath, target, "",
+		filepath.Join(profileDir, "seed.coverprofile"))
+	if err != nil {
+		return res, fmt.Errorf("replaying seed corpus: %w", err)
+	}
+	covered := make(map[string]bool, len(seedBlocks))
+	for _, block := range seedBlocks {
+		covered[block] = true
+	}
+
+	// Replay the inputs as seed corpus entries. Inputs that are already
+	// part of the seed corpus are redundant.
+	copied, err := copyCorpus(corpusDir,
+		filepath.Join(pkgPath, "testdata", "fuzz", target))
+	defer func() {
+		for _, path := range copied {
+			os.Remove(path)
+		}
+	}()
+	if err != nil {
+		return res, err
+	}
+
+	inputs := make([]*corpusInput, 0, len(copied))
+	for _, path := range copied {
+		info, err := os.Stat(path)
+		if err != nil {
+			return res, err
+		}
+		inputs = append(inputs, &corpusInput{
+			name: filepath.Base(path),
+			size: info.Size(),
+		})
+	}
+
+	g, gCtx := errgroup.WithContext(ctx)
+	g.SetLimit(max(cfg.NumWorkers, 1))
+	for i, input := range inputs {
+		g.Go(func() error {
+			blocks, err := replayInputCoverage(gCtx, bin, pkgPath,
+				target, input.name, filepath.Join(profileDir,
+					fmt.Sprintf("%d.coverprofile", i)))
+			if gCtx.Err() != nil {
+				return gCtx.Err()
+			}
+			if err != nil {
+				logger.Warn("Failed to replay corpus input; "+
+					"keeping it", "package", pkg, "target",
+					target, "input", input.name, "error",
+					err)
+				input.keep = true
+				return nil
+			}
+
+			input.blocks = blocks
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return res, err
+	}
+
+	kept := selectCorpusInputs(inputs, covered)
+
+	// Archive every input that is not kept.
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		res.Inputs++
+		if kept[entry.Name()] {
+			res.Kept++
+			continue
+		}
+
+		if err := EnsureDirExists(filepath.Join(archiveDir,
+			relDir)); err != nil {
+
+			return res, err
+		}
+		err := os.Rename(filepath.Join(corpusDir, entry.Name()),
+			filepath.Join(archiveDir, relDir, entry.Name()))
+		if err != nil {
+			return res, fmt.Errorf("archiving corpus input: %w",
+				err)
+		}
+	}
+
+	return res, nil
+}
+
+// selectCorpusInputs greedily selects the inputs to keep: those whose replay
+// failed, and then, starting with the inputs covering the most blocks, every
+// input covering a block not covered yet. covered holds the blocks already
+// covered, and is updated with the blocks of the kept inputs. Ties are broken
+// in favor of smaller inputs, which are faster to replay.
+func selectCorpusInputs(inputs []*corpusInput,
+	covered map[string]bool) map[string]bool {
+
+	sorted := append([]*corpusInput(nil), inputs...)
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if len(a.blocks) != len(b.blocks) {
+			return len(a.blocks) > len(b.blocks)
+		}
+		if a.size != b.size {
+			return a.size < b.size
+		}
+		return a.name < b.name
+	})
+
+	kept := make(map[string]bool)
+	for _, input := range sorted {
+		if input.keep {
+			kept[input.name] = true
+			continue
+		}
+
+		for _, block := range input.blocks {
+			if !covered[block] {
+				kept[input.name] = true
+				break
+			}
+		}
+		if kept[input.name] {
+			for _, block := range input.blocks {
+				covered[block] = true
+			}
+		}
+	}
+
+	return kept
+}
+
+// replayInputCoverage runs the fuzz target over the seed corpus entry named
+// input through the coverage binary bin, or over the whole seed corpus if
+// input is empty, and returns the positions of the blocks it executed.
+func replayInputCoverage(ctx context.Context, bin, pkgPath, target, input,
+	profilePath string) ([]string, error) {
+
+	pattern := "^" + regexp.QuoteMeta(target) + "$"
+	if input != "" {
+		pattern += "/^" + regexp.QuoteMeta(input) + "$"
+	}
+
+	cmd := exec.CommandContext(ctx, bin, "-test.run="+pattern,
+		"-test.coverprofile="+profilePath)
+	cmd.Dir = pkgPath
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("%w (output: %q)", err,
+			strings.TrimSpace(string(output)))
+	}
+
+	file, err := os.Open(profilePath)
+	if err != nil {
+		return nil, fmt.Errorf("opening coverage profile: %w", err)
+	}
+	defer file.Close()
+
+	profile, err := parseCoverProfile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var blocks []string
+	for pos, block := range profile.blocks {
+		if block.count > 0 {
+			blocks = append(blocks, pos)
+		}
+	}
+
+	return blocks, nil
+}
+
+// minimizeStoredCorpus minimizes the corpus in cfg.CorpusDir and uploads the
+// removed inputs as a zip archive under cfg.CorpusArchivePrefix. It returns
+// the number of removed inputs. If the archive cannot be uploaded, the removed
+// inputs are restored, so that no input is ever lost.
+func minimizeStoredCorpus(ctx context.Context, logger *slog.Logger,
+	cfg *Config, s3Client *s3.Client, m *fuzzMetrics,
+	pkgTargets map[string][]string) (int, error) {
+
+	if err := os.RemoveAll(cfg.ArchiveDir); err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(cfg.ArchiveDir)
+
+	results, err := minimizeCorpus(ctx, logger, cfg, pkgTargets,
+		cfg.ArchiveDir)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, res := range results {
+		removed += res.Inputs - res.Kept
+		logger.Info("Minimized corpus", "package", res.Package,
+			"target", res.Target, "inputs", res.Inputs, "kept",
+			res.Kept)
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+
+	key := fmt.Sprintf("%scorpus-%s.zip", cfg.CorpusArchivePrefix,
+		time.Now().UTC().Format("20060102T150405Z"))
+	buf, err := zipDir(cfg.ArchiveDir, logger)
+	if err == nil {
+		err = uploadObject(ctx, s3Client, cfg.S3BucketName, key, buf,
+			logger, m)
+	}
+	if err != nil {
+		restoreErr := restoreArchivedInputs(cfg.ArchiveDir,
+			cfg.CorpusDir)
+		if restoreErr != nil {
+			logger.Error("Failed to restore archived inputs",
+				"error", restoreErr)
+		}
+		return 0, fmt.Errorf("archiving removed inputs: %w", err)
+	}
+
+	logger.Info("Archived removed corpus inputs", "inputs", removed,
+		"key", key)
+
+	return removed, nil
+}
+
+// restoreArchivedInputs moves the inputs in archiveDir back into corpusDir.
+func restoreArchivedInputs(archiveDir, corpusDir string) error {
+	return filepath.WalkDir(archiveDir, func(path string, d os.DirEntry,
+		err error) error {
+
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(archiveDir, path)
+		if err != nil {
+			return err
+		}
+
+		return os.Rename(path, filepath.Join(corpusDir, rel))
+	})
+}
+
+// runCorpusMinimize implements the "corpus minimize" command: it minimizes
+// the stored corpus of every fuzz target of the configured packages, and
+// uploads the minimized corpus once the removed inputs are archived.
+func runCorpusMinimize(ctx context.Context, logger *slog.Logger,
+	cfg *Config) error {
+
+	pkgTargets, err := syncAndListTargets(ctx, logger, cfg)
+	if err != nil {
+		return err
+	}
+
+	s3Client, err := createS3Client(ctx)
+	if err != nil {
+		return fmt.Errorf("creating S3 client: %w", err)
+	}
+	m := newFuzzMetrics(cfg.NumWorkers)
+	if err := fetchCorpus(ctx, logger, cfg, s3Client, m); err != nil {
+		return err
+	}
+
+	removed, err := minimizeStoredCorpus(ctx, logger, cfg, s3Client, m,
+		pkgTargets)
+	if err != nil {
+		return err
+	}
+	if removed == 0 {
+		logger.Info("Corpus is already minimal")
+		return nil
+	}
+
+	return zipUploadCorpus(ctx, s3Client, cfg.S3BucketName, CorpusKey,
+		cfg.CorpusDir, logger, m)
+}
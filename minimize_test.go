@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCorpusInput writes a "go test fuzz v1" input holding the string value
+// into the corpus directory of the fuzz target.
+func writeCorpusInput(t *testing.T, corpusDir, pkg, target, name,
+	value string) {
+
+	t.Helper()
+
+	dir := filepath.Join(corpusDir, pkg, "testdata", "fuzz", target)
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name),
+		[]byte("go test fuzz v1\nstring(\""+value+"\")\n"), 0644))
+}
+
+// TestMinimizeCorpus verifies that minimization keeps the smallest inputs
+// preserving the coverage of the corpus, and archives the others.
+func TestMinimizeCorpus(t *testing.T) {
+	dir := t.TempDir()
+	writeTestPackage(t, dir, "parser", `package parser
+
+import "testing"
+
+func FuzzParse(f *testing.F) {
+	f.Add("seed")
+	f.Fuzz(func(t *testing.T, s string) { Parse(s) })
+}
+`)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "parser",
+		"parse.go"), []byte(`package parser
+
+import "strings"
+
+func Parse(s string) int {
+	if strings.HasPrefix(s, "a") {
+		return 1
+	}
+	if strings.HasPrefix(s, "b") {
+		return 2
+	}
+	return 0
+}
+`), 0644))
+
+	tmp := t.TempDir()
+	cfg := &Config{
+		ProjectDir: dir,
+		CorpusDir:  filepath.Join(tmp, TmpCorpusDir),
+		BinDir:     filepath.Join(tmp, TmpBinDir),
+		NumWorkers: 2,
+	}
+	archiveDir := filepath.Join(tmp, TmpArchiveDir)
+
+	// Both "alpha" and "ab" cover the first branch, "zzz" covers nothing
+	// the seed corpus misses.
+	inputs := map[string]string{
+		"alpha":  "alpha",
+		"ab":     "ab",
+		"banana": "banana",
+		"zzz":    "zzz",
+	}
+	for name, value := range inputs {
+		writeCorpusInput(t, cfg.CorpusDir, "parser", "FuzzParse", name,
+			value)
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	results, err := minimizeCorpus(context.Background(), logger, cfg,
+		map[string][]string{"parser": {"FuzzParse"}}, archiveDir)
+	require.NoError(t, err)
+	assert.Equal(t, []targetMinimization{{
+		Package: "parser",
+		Target:  "FuzzParse",
+		Inputs:  4,
+		Kept:    2,
+	}}, results)
+
+	relDir := filepath.Join("parser", "testdata", "fuzz", "FuzzParse")
+	names := func(dir string) []string {
+		entries, err := os.ReadDir(filepath.Join(dir, relDir))
+		require.NoError(t, err)
+
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		return names
+	}
+	assert.Equal(t, []string{"ab", "banana"}, names(cfg.CorpusDir))
+	assert.Equal(t, []string{"alpha", "zzz"}, names(archiveDir))
+
+	// The corpus is replayed from the package's testdata, where no input
+	// is left behind.
+	assert.Empty(t, names(dir))
+
+	// Restoring the archive yields the original corpus.
+	require.NoError(t, restoreArchivedInputs(archiveDir, cfg.CorpusDir))
+	assert.Equal(t, []string{"ab", "alpha", "banana", "zzz"},
+		names(cfg.CorpusDir))
+}
+
+// TestSelectCorpusInputs verifies that inputs are selected greedily by the
+// number of blocks they cover, and that inputs that failed to replay are
+// always kept.
+func TestSelectCorpusInputs(t *testing.T) {
+	inputs := []*corpusInput{
+		{name: "small", size: 1, blocks: []string{"a"}},
+		{name: "wide", size: 10, blocks: []string{"a", "b", "c"}},
+		{name: "extra", size: 5, blocks: []string{"c", "d"}},
+		{name: "seeded", size: 1, blocks: []string{"s"}},
+		{name: "failed", size: 1, keep: true},
+	}
+	covered := map[string]bool{"s": true}
+
+	kept := selectCorpusInputs(inputs, covered)
+	assert.Equal(t, map[string]bool{
+		"wide":   true,
+		"extra":  true,
+		"failed": true,
+	}, kept)
+	assert.Equal(t, map[string]bool{
+		"a": true, "b": true, "c": true, "d": true, "s": true,
+	}, covered)
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	// notificationQueueSize is the number of events that can be waiting
+	// for delivery before new events are dropped.
+	notificationQueueSize = 256
+
+	// notificationTimeout bounds the time spent delivering a single event
+	// to a single sink, including retries.
+	notificationTimeout = 2 * time.Minute
+)
+
+// eventType identifies the kind of a notification event.
+type eventType string
+
+const (
+	// eventNewCrash is emitted when a crash with a previously unseen
+	// signature is found.
+	eventNewCrash eventType = "new_crash"
+
+	// eventCrashRecurrence is emitted when a known, unfixed crash is found
+	// again.
+	eventCrashRecurrence eventType = "crash_recurrence"
+
+	// eventCrashRegression is emitted when a crash that had been detected
+	// as fixed is found again.
+	eventCrashRegression eventType = "crash_regression"
+
+	// eventCrashFixed is emitted when the failing input of a crash no
+	// longer reproduces it.
+	eventCrashFixed eventType = "crash_fixed"
+
+	// eventCrashBisected is emitted when the commit that introduced a
+	// crash has been found.
+	eventCrashBisected eventType = "crash_bisected"
+
+	// eventCycleFailure is emitted when a fuzzing cycle aborts.
+	eventCycleFailure eventType = "cycle_failure"
+
+	// eventTargetBuildFailure is emitted when the fuzz targets of a
+	// package cannot be built.
+	eventTargetBuildFailure eventType = "target_build_failure"
+)
+
+// event is a notification about something that happened while fuzzing. Its
+// JSON encoding is the payload delivered to webhooks.
+type event struct {
+	// Type identifies the kind of event.
+	Type eventType `json:"type"`
+
+	// Time is the time the event occurred.
+	Time time.Time `json:"time"`
+
+	// Package is the package the event relates to, if any.
+	Package string `json:"package,omitempty"`
+
+	// Target is the fuzz target the event relates to, if any.
+	Target string `json:"target,omitempty"`
+
+	// Message is a human-readable summary of the event.
+	Message string `json:"message"`
+
+	// Error describes the failure behind the event, if any.
+	Error string `json:"error,omitempty"`
+
+	// Crash is the record of the crash the event relates to, if any.
+	Crash *crashRecord `json:"crash,omitempty"`
+
+	// SuppressedEvents is the number of events dropped by rate limiting
+	// since the previous delivery to the same sink.
+	SuppressedEvents int `json:"suppressed_events,omitempty"`
+}
+
+// newCrashEvent returns the event describing the given crash.
+func newCrashEvent(crash *fuzzCrash) *event {
+	rec := crash.Record
+	ev := &event{
+		Type:    eventCrashRecurrence,
+		Time:    rec.LastSeen,
+		Package: rec.Package,
+		Target:  rec.Target,
+		Message: "Known crash detected again in " + rec.Package + "/" +
+			rec.Target,
+		Crash: rec,
+	}
+
+	switch {
+	case crash.IsNew:
+		ev.Type = eventNewCrash
+		ev.Message = "New crash detected in " + rec.Package + "/" +
+			rec.Target
+
+	case crash.Regression:
+		ev.Type = eventCrashRegression
+		ev.Message = "Fixed crash regressed in " + rec.Package + "/" +
+			rec.Target
+	}
+
+	return ev
+}
+
+// notificationSink delivers events to an external system.
+type notificationSink interface {
+	// name identifies the sink in logs.
+	name() string
+
+	// notify delivers the event. Sinks may ignore event types they are
+	// not interested in.
+	notify(ctx context.Context, ev *event) error
+}
+
+// notifier fans events out to all configured sinks. Events are queued and
+// delivered in order by a background goroutine, so that slow or unreachable
+// sinks never hold up fuzzing.
+type notifier struct {
+	// logger for informational and error messages.
+	logger *slog.Logger
+
+	// sinks receive every event.
+	sinks []notificationSink
+
+	// queue holds events waiting for delivery.
+	queue chan *event
+
+	// done is closed once all queued events have been delivered after the
+	// notifier was closed.
+	done chan struct{}
+
+	// mu guards closed and sending on the queue.
+	mu sync.Mutex
+
+	// closed reports whether the queue has been closed.
+	closed bool
+}
+
+// newNotifier creates a notifier delivering events to the sinks configured in
+// cfg and starts its delivery goroutine.
+func newNotifier(logger *slog.Logger, cfg *Config) *notifier {
+	var sinks []notificationSink
+
+	if tracker := newGitHubIssueTracker(logger, cfg); tracker != nil {
+		sinks = append(sinks, tracker)
+	}
+	if cfg.WebhookURL != "" {
+		sinks = append(sinks, newWebhookSink(logger, cfg))
+	}
+
+	return newNotifierWithSinks(logger, sinks...)
+}
+
+// newNotifierWithSinks creates a notifier delivering events to the given sinks
+// and starts its delivery goroutine.
+func newNotifierWithSinks(logger *slog.Logger,
+	sinks ...notificationSink) *notifier {
+
+	n := &notifier{
+		logger: logger,
+		sinks:  sinks,
+		queue:  make(chan *event, notificationQueueSize),
+		done:   make(chan struct{}),
+	}
+	go n.run()
+
+	return n
+}
+
+// notify queues the event for delivery. The event is dropped if the queue is
+// full or the notifier has been closed.
+func (n *notifier) notify(ev *event) {
+	if len(n.sinks) == 0 {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.closed {
+		n.logger.Warn("Notifier closed; dropping event", "type",
+			ev.Type)
+		return
+	}
+
+	select {
+	case n.queue <- ev:
+	default:
+		n.logger.Warn("Notification queue full; dropping event",
+			"type", ev.Type)
+	}
+}
+
+// close stops accepting events and waits until all queued events have been
+// delivered.
+func (n *notifier) close() {
+	n.mu.Lock()
+	if !n.closed {
+		n.closed = true
+		close(n.queue)
+	}
+	n.mu.Unlock()
+
+	<-n.done
+}
+
+// run delivers queued events to every sink until the queue is closed.
+func (n *notifier) run() {
+	defer close(n.done)
+
+	for ev := range n.queue {
+		for _, sink := range n.sinks {
+			ctx, cancel := context.WithTimeout(context.Background(),
+				notificationTimeout)
+			err := sink.notify(ctx, ev)
+			cancel()
+
+			if err != nil {
+				n.logger.Error("Failed to deliver notification",
+					"sink", sink.name(), "type", ev.Type,
+					"error", err)
+			}
+		}
+	}
+}
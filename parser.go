@@ -9,6 +9,9 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
+
+	"github.com/go-continuous-fuzz/go-continuous-fuzz/fuzzinput"
 )
 
 var (
@@ -41,6 +44,10 @@ var (
 	)
 )
 
+// maxRecentOutputLines is the number of output lines preceding a failure that
+// are kept for classifying it.
+const maxRecentOutputLines = 500
+
 // fuzzOutputProcessor handles parsing and logging of fuzzing output streams,
 // detecting failures, and capturing/logging failing input data.
 type fuzzOutputProcessor struct {
@@ -61,6 +68,28 @@ type fuzzOutputProcessor struct {
 
 	// File handle for writing failure logs.
 	logFile *os.File
+
+	// buildFailure holds the output of a failed build of the package
+	// under test, if the fuzzing output reported one.
+	buildFailure string
+
+	// progress is the latest progress reported by the fuzzing process.
+	progress fuzzProgress
+
+	// onProgress, if set, is called with every progress report of the
+	// fuzzing process.
+	onProgress func(fuzzProgress)
+
+	// limitExceeded reports whether the fuzzing process was killed for
+	// exceeding the memory limit of its sandbox. It is nil if the
+	// process is not sandboxed.
+	limitExceeded func() bool
+
+	// recentOutput holds the most recent output lines preceding the
+	// failure. A fuzzing process that hangs or dies prints its last words
+	// before the failure is reported, so these lines are needed to
+	// classify the failure.
+	recentOutput []string
 }
 
 // NewFuzzOutputProcessor constructs a fuzzOutputProcessor for the given logger,
@@ -78,47 +107,77 @@ func NewFuzzOutputProcessor(logger *slog.Logger, cfg *Config, corpusDir, pkg,
 }
 
 // processFuzzStream reads each line from the fuzzing output stream, logs all
-// lines, and captures failure details if a failure is detected. Returns true if
-// a failure was found and processed, false otherwise.
-func (fp *fuzzOutputProcessor) processFuzzStream(stream io.Reader) bool {
+// lines, and captures failure details if a failure is detected. Returns the
+// detected crash, or nil if the fuzz target did not fail or its package could
+// not be built, in which case buildFailure is set.
+func (fp *fuzzOutputProcessor) processFuzzStream(stream io.Reader) *fuzzCrash {
 	scanner := bufio.NewScanner(stream)
 
-	// Scan until a failure line is found; if not found, return false.
+	// Scan until a failure line is found; if not found, return nil.
 	if !fp.scanUntilFailure(scanner) {
-		return false
+		return nil
 	}
 
 	// Process and log failure lines, capturing error data.
-	fp.processFailureLines(scanner)
-
-	return true
+	return fp.processFailureLines(scanner)
 }
 
-// scanUntilFailure scans the output until a failure indicator (--- FAIL:) is
-// found. Returns true if a failure line is detected, false otherwise.
+// scanUntilFailure scans the output until a failure indicator (--- FAIL:, or
+// a build failure) is found. Returns true if a failure line is detected, false
+// otherwise.
 func (fp *fuzzOutputProcessor) scanUntilFailure(scanner *bufio.Scanner) bool {
 	for scanner.Scan() {
 		line := scanner.Text()
-		fp.logger.Info("Fuzzer output", "message", line)
+		logFuzzerOutput(fp.logger, fp.cfg, line)
+
+		// Track the progress of the fuzzing process.
+		if parseFuzzProgress(line, &fp.progress) &&
+			fp.onProgress != nil {
+
+			fp.onProgress(fp.progress)
+		}
 
 		// Detect the start of a failure section.
 		if strings.Contains(line, "--- FAIL:") {
 			return true
 		}
+
+		// Keep the summary line of a build failure, as it's the only
+		// hint of the failure when the compiler errors were written to
+		// the separate standard error.
+		fp.rememberLine(line)
+		if isBuildFailureLine(line) {
+			return true
+		}
 	}
 	return false
 }
 
-// processFailureLines processes lines after a failure is detected, writes them
-// to a log file, and attempts to extract and log the failing input data.
-func (fp *fuzzOutputProcessor) processFailureLines(scanner *bufio.Scanner) {
+// rememberLine adds the line to the recent output, dropping the oldest line
+// beyond maxRecentOutputLines.
+func (fp *fuzzOutputProcessor) rememberLine(line string) {
+	if len(fp.recentOutput) == maxRecentOutputLines {
+		fp.recentOutput = fp.recentOutput[1:]
+	}
+	fp.recentOutput = append(fp.recentOutput, line)
+}
+
+// processFailureLines processes lines after a failure is detected, classifies
+// the failure, writes it to a log file, and attempts to extract and log the
+// failing input data. The returned crash is nil if the failure is a build
+// failure, which is not a crash of the target; otherwise it is never nil, but
+// its record is nil if the crash could not be recorded.
+func (fp *fuzzOutputProcessor) processFailureLines(
+	scanner *bufio.Scanner) *fuzzCrash {
+
 	var errorLog string
 	var errorInput string
 	var errorData string
+	var inputID string
 
 	for scanner.Scan() {
 		line := scanner.Text()
-		fp.logger.Info("Fuzzer output", "message", line)
+		logFuzzerOutput(fp.logger, fp.cfg, line)
 
 		// Write the current line to the failure log.
 		errorLog = errorLog + line + "\n"
@@ -156,36 +215,154 @@ func (fp *fuzzOutputProcessor) processFailureLines(scanner *bufio.Scanner) {
 		// Read and store the input data associated with the failing
 		// target and ID.
 		errorInput = fp.readFailingInput(target, id)
+		inputID = id
 	}
 
+	// Classify the failure using both the output preceding the failure
+	// and the failure section itself.
+	recentOutput := strings.Join(fp.recentOutput, "\n")
+	class := classifyFailure(recentOutput + "\n" + errorLog)
+
+	// A package that failed to build was never fuzzed. It is reported as
+	// a broken target rather than recorded as a crash.
+	if class == crashClassBuild {
+		fp.buildFailure = recentOutput + "\n" + errorLog
+		return nil
+	}
+
+	// A data race is identified by its two conflicting accesses rather
+	// than by the location where the test failed, which is always the
+	// same for races.
+	if class == crashClassRace {
+		raceData := raceSignatureData(recentOutput + "\n" + errorLog)
+		if raceData != "" {
+			errorData = raceData
+		}
+	}
+
+	// A fuzzing process killed by its sandbox is reported as such rather
+	// than as the failure this caused.
+	if fp.limitExceeded != nil && fp.limitExceeded() {
+		class = crashClassLimit
+	}
+
+	// When the fuzzing process hung or died, or raced, its last output
+	// holds the stack traces, so keep it in the crash log.
+	if class == crashClassTimeout || class == crashClassOOM ||
+		class == crashClassRace || class == crashClassLimit {
+
+		errorLog = fmt.Sprintf("%s\n\n=== Output preceding the "+
+			"failure ===\n%s\n", errorLog, recentOutput)
+	}
+
+	return fp.recordCrash(class, errorLog, errorData, errorInput, inputID)
+}
+
+// recordCrash writes the crash log of a new crash and creates or updates the
+// crash record. The returned crash is never nil, but its record is nil if the
+// crash could not be recorded.
+func (fp *fuzzOutputProcessor) recordCrash(class crashClass, errorLog,
+	errorData, errorInput, inputID string) *fuzzCrash {
+
+	crash := &fuzzCrash{}
+
 	// Ensure the results directory exists.
 	if err := EnsureDirExists(fp.cfg.FuzzResultsPath); err != nil {
 		fp.logger.Error("Failed to create fuzz results directory",
 			"error", err)
-		return
+		return crash
 	}
 
 	// Check if the crash has already been recorded to avoid duplicate
 	// logging.
-	isKnown, logFileName, err := fp.isCrashDuplicate(errorData)
+	isKnown, logFileName, err := fp.isCrashDuplicate(errorData, class)
 	if err != nil {
 		fp.logger.Error("Failed to perform crash deduplication",
 			"error", err)
-		return
+		return crash
 	}
 	if isKnown {
 		fp.logger.Info("Known crash detected. Please fix the failing "+
-			"testcase.", "log_file", logFileName)
-		return
+			"testcase.", "log_file", logFileName, "class", class)
+	} else {
+		// A new unique crash has been detected. Proceed to log the
+		// crash details.
+		if err := fp.writeCrashLog(logFileName, class, errorLog,
+			errorInput); err != nil {
+			fp.logger.Error("Failed to write crash log", "error",
+				err)
+			return crash
+		}
+	}
+
+	// Keep the machine-readable crash record up to date, so the crash can
+	// be tracked and verified across cycles.
+	rec, regression, err := fp.updateCrashRecord(errorData, logFileName,
+		class, inputID)
+	if err != nil {
+		fp.logger.Error("Failed to update crash record", "error", err)
+		return crash
+	}
+
+	crash.Record = rec
+	crash.IsNew = !isKnown
+	crash.Regression = regression
+
+	return crash
+}
+
+// updateCrashRecord creates or updates the crash record for the crash with the
+// given error data, reporting whether the crash had previously been marked as
+// fixed.
+func (fp *fuzzOutputProcessor) updateCrashRecord(errorData, logFileName string,
+	class crashClass, inputID string) (*crashRecord, bool, error) {
+
+	signature := ComputeSHA256Short(fp.packageName, fp.targetName,
+		errorData)
+	recordPath := filepath.Join(fp.cfg.FuzzResultsPath,
+		crashRecordFileName(fp.packageName, fp.targetName, signature))
+
+	now := time.Now()
+	rec, err := loadCrashRecord(recordPath)
+	switch {
+	// Crashes logged before records were introduced have no record yet,
+	// so treat them like a first occurrence.
+	case os.IsNotExist(err):
+		rec = &crashRecord{
+			Package:   fp.packageName,
+			Target:    fp.targetName,
+			Signature: signature,
+			LogFile:   logFileName,
+			FirstSeen: now,
+		}
+
+	case err != nil:
+		return nil, false, err
 	}
 
-	// A new unique crash has been detected. Proceed to log the crash
-	// details.
-	if err := fp.writeCrashLog(logFileName, errorLog,
-		errorInput); err != nil {
-		fp.logger.Error("Failed to write crash log", "error", err)
-		return
+	regression := rec.Fixed
+	rec.Class = class
+	rec.Fixed = false
+	rec.FixedAt = time.Time{}
+	rec.LastSeen = now
+	rec.Occurrences++
+
+	// Always keep the latest failing input, since it is the one that
+	// reproduces the crash against the current code.
+	if inputID != "" {
+		input, err := os.ReadFile(filepath.Join(fp.corpusDir,
+			fp.targetName, inputID))
+		if err == nil {
+			rec.InputID = inputID
+			rec.Input = input
+		}
 	}
+
+	if err := saveCrashRecord(fp.cfg.FuzzResultsPath, rec); err != nil {
+		return nil, false, err
+	}
+
+	return rec, regression, nil
 }
 
 // parseFileAndLine attempts to extract stack-trace line indicating a fuzzing
@@ -215,34 +392,52 @@ func parseFileAndLine(errorLine string) string {
 
 // isCrashDuplicate checks whether a crash with the same hash has already been
 // logged. Returns true if the crash is already known, false otherwise, along
-// with the generated log file name.
-func (fp *fuzzOutputProcessor) isCrashDuplicate(errorData string) (bool,
-	string, error) {
+// with the log file name of the crash.
+func (fp *fuzzOutputProcessor) isCrashDuplicate(errorData string,
+	class crashClass) (bool, string, error) {
 
 	// Compute a short signature hash for the crash to help with
 	// deduplication.
 	crashHash := ComputeSHA256Short(fp.packageName, fp.targetName,
 		errorData)
 
-	// Construct the log file name using the package, target name and crash
-	// hash.
-	logFileName := fmt.Sprintf("%s_%s_%s_failure.log", fp.packageName,
-		fp.targetName, crashHash)
+	// A crash with a record is known, and keeps its log file.
+	rec, err := loadCrashRecord(filepath.Join(fp.cfg.FuzzResultsPath,
+		crashRecordFileName(fp.packageName, fp.targetName, crashHash)))
+	switch {
+	case err == nil:
+		return true, rec.LogFile, nil
+
+	case !os.IsNotExist(err):
+		return false, "", fmt.Errorf("checking for existing crash "+
+			"record: %w", err)
+	}
 
-	// Check if a log file with the same signature already exists in the
-	// fuzz results directory.
-	isKnown, err := FileExistsInDir(fp.cfg.FuzzResultsPath, logFileName)
+	// Crashes logged before failures were classified have a log file
+	// without the class in its name.
+	legacyLogFileName := fmt.Sprintf("%s_%s_%s_failure.log",
+		fp.packageName, fp.targetName, crashHash)
+	isKnown, err := FileExistsInDir(fp.cfg.FuzzResultsPath,
+		legacyLogFileName)
 	if err != nil {
 		return false, "", fmt.Errorf("checking for existing crash "+
 			"log: %w", err)
 	}
+	if isKnown {
+		return true, legacyLogFileName, nil
+	}
+
+	// Construct the log file name using the package, target name, crash
+	// hash and failure class.
+	logFileName := fmt.Sprintf("%s_%s_%s_%s.log", fp.packageName,
+		fp.targetName, crashHash, class)
 
-	return isKnown, logFileName, nil
+	return false, logFileName, nil
 }
 
 // writeCrashLog creates and writes crash logs into a file at the given location
-func (fp *fuzzOutputProcessor) writeCrashLog(logFileName, errorLog,
-	errorInput string) error {
+func (fp *fuzzOutputProcessor) writeCrashLog(logFileName string,
+	class crashClass, errorLog, errorInput string) error {
 
 	// Construct the log file path for storing failure details.
 	logPath := filepath.Join(fp.cfg.FuzzResultsPath, logFileName)
@@ -264,6 +459,14 @@ func (fp *fuzzOutputProcessor) writeCrashLog(logFileName, errorLog,
 
 	fp.logger.Info("Failure log initialized", "path", logPath)
 
+	// Write a short header identifying the crash.
+	_, err = fmt.Fprintf(fp.logFile, "=== Crash report ===\nPackage: %s\n"+
+		"Target: %s\nClass: %s\n\n", fp.packageName, fp.targetName,
+		class)
+	if err != nil {
+		return fmt.Errorf("failed to write crash header: %w", err)
+	}
+
 	// Write the error logs to the failure log file.
 	if errorLog != "" {
 		_, err = fp.logFile.WriteString(errorLog)
@@ -334,7 +537,89 @@ func (fp *fuzzOutputProcessor) readFailingInput(target, id string) string {
 	}
 
 	// If reading succeeds, format the content with a header indicating it's
-	// a failing test case.
-	return fmt.Sprintf("\n\n=== Failing testcase (%s) ===\n%s",
-		failingInputPath, data)
+	// a failing test case, followed by its decoded values, the code adding
+	// it to the seed corpus and where to commit it as a regression test.
+	return fmt.Sprintf("\n\n=== Failing testcase (%s) ===\n%s%s\n"+
+		"=== Regression test ===\nCommit the failing testcase as %s "+
+		"to make \"go test\" replay it.\n", failingInputPath, data,
+		describeFailingInput(data), regressionInputPath(
+			fp.packageName, target, id))
+}
+
+// describeFailingInput returns the readable values of a failing input, with
+// binary data hex dumped, and the f.Add call adding the input to the seed
+// corpus of its fuzz target.
+func describeFailingInput(input []byte) string {
+	vals, err := fuzzinput.Unmarshal(input)
+	if err != nil {
+		return fmt.Sprintf("\n<< failed to decode input: %v >>\n", err)
+	}
+
+	return fmt.Sprintf("\n=== Decoded values ===\n%s\n=== Seed corpus "+
+		"entry ===\n%s\n", fuzzinput.Render(vals),
+		fuzzinput.SeedCode(vals))
+}
+
+// isBuildFailureLine reports whether the line is the summary "go test" prints
+// when the package under test could not be built or set up, e.g.
+// "FAIL	example.com/parser [build failed]".
+func isBuildFailureLine(line string) bool {
+	return strings.HasPrefix(line, "FAIL") &&
+		(strings.HasSuffix(line, "[build failed]") ||
+			strings.HasSuffix(line, "[setup failed]"))
+}
+
+// classifyFailure determines the class of a failure from the fuzzing output
+// surrounding it.
+func classifyFailure(output string) crashClass {
+	switch {
+	case containsLine(output, isBuildFailureLine):
+		return crashClassBuild
+
+	case strings.Contains(output, raceReportHeader):
+		return crashClassRace
+
+	// The OOM killer terminates the fuzzing process with SIGKILL, which
+	// the fuzzer reports as an unexpected signal, while the Go runtime
+	// aborts with a fatal error when an allocation fails.
+	case strings.Contains(output, "terminated by unexpected signal"),
+		strings.Contains(output, "signal: killed"),
+		strings.Contains(output, "out of memory"):
+
+		return crashClassOOM
+
+	// The fuzzing worker panics with "deadlocked!" when a single input
+	// runs for too long. A worker dying without a panic or fatal error is
+	// most likely the fuzzer giving up on a hung process.
+	case strings.Contains(output, "panic: deadlocked!"),
+		strings.Contains(output, "hung or terminated unexpectedly") &&
+			!strings.Contains(output, "panic:") &&
+			!strings.Contains(output, "fatal error:"):
+
+		return crashClassTimeout
+
+	case strings.Contains(output, "failure while testing seed corpus "+
+		"entry"):
+
+		return crashClassSeed
+
+	case strings.Contains(output, "panic:"),
+		strings.Contains(output, "fatal error:"):
+
+		return crashClassPanic
+	}
+
+	// Anything else is a test failure reported through t.Error, t.Fatal
+	// and friends.
+	return crashClassAssertion
+}
+
+// containsLine reports whether any line of output satisfies match.
+func containsLine(output string, match func(string) bool) bool {
+	for _, line := range strings.Split(output, "\n") {
+		if match(strings.TrimSpace(line)) {
+			return true
+		}
+	}
+	return false
 }
@@ -119,7 +119,13 @@ func TestReadInputData(t *testing.T) {
 			corpusPath: "testdata",
 			expectedData: "\n\n=== Failing testcase (FuzzFoo/" +
 				"771e938e4458e983) ===\ngo test fuzz v1\n" +
-				"string(\"0\")\n",
+				"string(\"0\")\n\n=== Decoded values ===\n" +
+				"#0 string (len 1): \"0\"\n\n=== Seed " +
+				"corpus entry ===\nf.Add(\"0\")\n\n=== " +
+				"Regression test ===\nCommit the failing " +
+				"testcase as testdata/fuzz/FuzzFoo/" +
+				"771e938e4458e983 to make \"go test\" " +
+				"replay it.\n",
 		},
 	}
 
@@ -138,3 +144,111 @@ func TestReadInputData(t *testing.T) {
 		})
 	}
 }
+
+// TestClassifyFailure verifies that classifyFailure tells apart the different
+// kinds of failures reported by the fuzzer.
+func TestClassifyFailure(t *testing.T) {
+	tests := []struct {
+		name          string
+		output        string
+		expectedClass crashClass
+	}{
+		{
+			name: "panic in fuzz target",
+			output: "    --- FAIL: FuzzParseComplex (0.00s)\n" +
+				"        testing.go:1591: panic: runtime " +
+				"error: index out of range [3] with " +
+				"length 3\n",
+			expectedClass: crashClassPanic,
+		},
+		{
+			name: "assertion failure",
+			output: "    stringutils_test.go:17: Reverse " +
+				"produced invalid UTF-8 string\n",
+			expectedClass: crashClassAssertion,
+		},
+		{
+			name: "hung input",
+			output: "panic: deadlocked!\n\ngoroutine 21 " +
+				"[running]:\n--- FAIL: FuzzEvalExpr " +
+				"(61.02s)\n    fuzzing process hung or " +
+				"terminated unexpectedly: exit status 2\n",
+			expectedClass: crashClassTimeout,
+		},
+		{
+			name: "worker killed by the OOM killer",
+			output: "--- FAIL: FuzzEvalExpr (12.20s)\n    " +
+				"fuzzing process terminated by unexpected " +
+				"signal; no crash will be recorded: " +
+				"signal: killed\n",
+			expectedClass: crashClassOOM,
+		},
+		{
+			name: "runtime out of memory",
+			output: "fatal error: runtime: out of memory\n" +
+				"--- FAIL: FuzzEvalExpr (3.10s)\n    " +
+				"fuzzing process hung or terminated " +
+				"unexpectedly: exit status 2\n",
+			expectedClass: crashClassOOM,
+		},
+		{
+			name: "build failure",
+			output: "FAIL\tgithub.com/example/parser " +
+				"[build failed]\n",
+			expectedClass: crashClassBuild,
+		},
+		{
+			name: "seed corpus failure",
+			output: "    failure while testing seed corpus " +
+				"entry: FuzzFoo/seed#0\n    fuzz_test.go:" +
+				"12: unexpected result\n",
+			expectedClass: crashClassSeed,
+		},
+		{
+			name: "data race",
+			output: "==================\nWARNING: DATA RACE\n" +
+				"Write at 0x00c000014108 by goroutine 8:\n",
+			expectedClass: crashClassRace,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expectedClass,
+				classifyFailure(tt.output))
+		})
+	}
+}
+
+// TestRaceSignatureData verifies that a data race is identified by the
+// innermost frames of its two conflicting accesses, regardless of the order
+// in which they were reported.
+func TestRaceSignatureData(t *testing.T) {
+	writeStack := "Write at 0x00c000014108 by goroutine 8:\n" +
+		"  example.com/parser.(*Parser).next()\n" +
+		"      /src/parser/parser.go:42 +0x44\n" +
+		"  example.com/parser.Parse()\n" +
+		"      /src/parser/parser.go:12 +0x30\n"
+	readStack := "Previous read at 0x00c000014108 by goroutine 7:\n" +
+		"  example.com/parser.(*Parser).peek()\n" +
+		"      /src/parser/parser.go:50 +0x3c\n"
+	goroutineStack := "Goroutine 8 (running) created at:\n" +
+		"  example.com/parser.Parse()\n" +
+		"      /src/parser/parser.go:10 +0x20\n"
+	report := func(first, second string) string {
+		return "==================\nWARNING: DATA RACE\n" + first +
+			"\n" + second + "\n" + goroutineStack +
+			"==================\n"
+	}
+
+	expected := "DATA RACE\n" +
+		"example.com/parser.(*Parser).next\n" +
+		"example.com/parser.Parse\n--\n" +
+		"example.com/parser.(*Parser).peek\n"
+
+	assert.Equal(t, expected, raceSignatureData(report(writeStack,
+		readStack)))
+	assert.Equal(t, expected, raceSignatureData(report(readStack,
+		writeStack)))
+	assert.Empty(t, raceSignatureData("--- FAIL: FuzzFoo (0.10s)\n"))
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"container/heap"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// TaskPriority orders the tasks of a TaskQueue: tasks with a higher priority
+// are dequeued first.
+type TaskPriority int
+
+const (
+	// PriorityNormal is the priority of targets with nothing calling for
+	// attention.
+	PriorityNormal TaskPriority = iota
+
+	// PriorityCrash is the priority of targets with an open crash, so that
+	// the crash is confirmed or found fixed early in the cycle.
+	PriorityCrash
+
+	// PriorityChanged is the priority of targets in packages affected by
+	// the commits since the previous cycle, where new bugs are most
+	// likely.
+	PriorityChanged
+
+	// PriorityNew is the priority of targets never fuzzed before, whose
+	// corpus is the least explored.
+	PriorityNew
+)
+
+// taskPriorityNames are the names of the task priorities, as shown in the
+// status API and dashboard.
+var taskPriorityNames = map[TaskPriority]string{
+	PriorityNormal:  "normal",
+	PriorityCrash:   "crash",
+	PriorityChanged: "changed",
+	PriorityNew:     "new",
+}
+
+// String returns the name of the priority.
+func (p TaskPriority) String() string {
+	if name, ok := taskPriorityNames[p]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("TaskPriority(%d)", int(p))
+}
+
+// MarshalText encodes the priority as its name.
+func (p TaskPriority) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText decodes a priority from its name.
+func (p *TaskPriority) UnmarshalText(text []byte) error {
+	for priority, name := range taskPriorityNames {
+		if name == string(text) {
+			*p = priority
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown task priority %q", text)
+}
+
+// Task represents a single fuzz‐target job, containing the package path and
+// the specific target name to execute.
+type Task struct {
+	Package string `json:"package"`
+	Target  string `json:"target"`
+
+	// Race reports whether the target is built with the race detector.
+	Race bool `json:"race"`
+
+	// Priority orders the task in the queue.
+	Priority TaskPriority `json:"priority"`
+
+	// Timeout, if set, is how long the target is fuzzed instead of the
+	// per-target timeout of the cycle.
+	Timeout time.Duration `json:"timeout,omitempty"`
+}
+
+// queueEntry is a task in the queue, along with its position in the order
+// of dequeueing.
+type queueEntry struct {
+	task Task
+
+	// round is the round, among the tasks of its priority, in which the
+	// task is dequeued. Every package has at most one task per round, so
+	// that packages take turns.
+	round int
+
+	// seq orders the tasks of a round by the time they were enqueued.
+	seq int
+
+	// index is the position of the entry in the heap.
+	index int
+}
+
+// taskKey identifies a task in the queue.
+type taskKey struct {
+	pkg    string
+	target string
+}
+
+// roundKey identifies the tasks of a package with a given priority.
+type roundKey struct {
+	pkg      string
+	priority TaskPriority
+}
+
+// entryHeap is a heap of queue entries, ordered by priority, then round, then
+// enqueue order.
+type entryHeap []*queueEntry
+
+// less reports whether a is dequeued before b.
+func (h entryHeap) less(a, b *queueEntry) bool {
+	if a.task.Priority != b.task.Priority {
+		return a.task.Priority > b.task.Priority
+	}
+	if a.round != b.round {
+		return a.round < b.round
+	}
+
+	return a.seq < b.seq
+}
+
+func (h entryHeap) Len() int           { return len(h) }
+func (h entryHeap) Less(i, j int) bool { return h.less(h[i], h[j]) }
+
+func (h entryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *entryHeap) Push(x any) {
+	e := x.(*queueEntry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *entryHeap) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+
+	return e
+}
+
+// TaskQueue is a thread-safe priority queue for scheduling Task items. Tasks
+// are dequeued by descending priority. Among tasks of the same priority,
+// packages take turns, one task each, in the order their tasks were enqueued,
+// so that a package with many targets does not hold back the others.
+//
+// A task holds a single place in the queue: enqueueing a task already queued
+// raises its priority and timeout if the new ones are higher, instead of
+// queueing it twice.
+// A dequeued task can be enqueued again, and then waits for its package's
+// next turn.
+type TaskQueue struct {
+	mu sync.Mutex
+
+	entries entryHeap
+
+	// queued maps the queued tasks to their entry.
+	queued map[taskKey]*queueEntry
+
+	// rounds holds, for the tasks of each package and priority, the
+	// round of the last one enqueued.
+	rounds map[roundKey]int
+
+	// current holds, for each priority, the round of the last task
+	// dequeued.
+	current map[TaskPriority]int
+
+	// seq is the number of tasks enqueued so far.
+	seq int
+}
+
+// NewTaskQueue returns an empty, initialized TaskQueue.
+func NewTaskQueue() *TaskQueue {
+	return &TaskQueue{
+		queued:  make(map[taskKey]*queueEntry),
+		rounds:  make(map[roundKey]int),
+		current: make(map[TaskPriority]int),
+	}
+}
+
+// assignRound places the entry in the next round of its package and priority
+// that has not been dequeued yet.
+func (q *TaskQueue) assignRound(e *queueEntry) {
+	key := roundKey{e.task.Package, e.task.Priority}
+	e.round = q.current[e.task.Priority]
+	if last, ok := q.rounds[key]; ok && last+1 > e.round {
+		e.round = last + 1
+	}
+	q.rounds[key] = e.round
+}
+
+// Enqueue adds a Task to the queue, behind the tasks of higher priority and
+// the earlier tasks of its package.
+func (q *TaskQueue) Enqueue(t Task) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	key := taskKey{t.Package, t.Target}
+	if e, ok := q.queued[key]; ok {
+		e.task.Race = e.task.Race || t.Race
+		e.task.Timeout = max(e.task.Timeout, t.Timeout)
+		if t.Priority > e.task.Priority {
+			e.task.Priority = t.Priority
+			q.assignRound(e)
+			heap.Fix(&q.entries, e.index)
+		}
+		return
+	}
+
+	e := &queueEntry{task: t, seq: q.seq}
+	q.seq++
+	q.assignRound(e)
+	heap.Push(&q.entries, e)
+	q.queued[key] = e
+}
+
+// Dequeue removes and returns the next Task from the queue. If the queue is
+// empty, it returns false for the second return value.
+func (q *TaskQueue) Dequeue() (Task, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.entries) == 0 {
+		return Task{}, false
+	}
+
+	e := heap.Pop(&q.entries).(*queueEntry)
+	delete(q.queued, taskKey{e.task.Package, e.task.Target})
+	q.current[e.task.Priority] = e.round
+
+	return e.task, true
+}
+
+// Len returns the number of queued tasks.
+func (q *TaskQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return len(q.entries)
+}
+
+// Tasks returns a copy of the queued tasks, in queue order.
+func (q *TaskQueue) Tasks() []Task {
+	// Copy the entries, as queued tasks change when enqueued again.
+	q.mu.Lock()
+	entries := make(entryHeap, len(q.entries))
+	for i, e := range q.entries {
+		copied := *e
+		entries[i] = &copied
+	}
+	q.mu.Unlock()
+
+	slices.SortFunc(entries, func(a, b *queueEntry) int {
+		switch {
+		case entries.less(a, b):
+			return -1
+		case entries.less(b, a):
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	tasks := make([]Task, len(entries))
+	for i, e := range entries {
+		tasks[i] = e.task
+	}
+
+	return tasks
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// drainQueue dequeues all tasks of the queue, returning them as
+// "<package>/<target>".
+func drainQueue(q *TaskQueue) []string {
+	var names []string
+	for {
+		task, ok := q.Dequeue()
+		if !ok {
+			return names
+		}
+		names = append(names, task.Package+"/"+task.Target)
+	}
+}
+
+// TestTaskQueueOrder verifies that tasks are dequeued by priority, with
+// packages taking turns among tasks of the same priority, and that Tasks lists
+// them in that order.
+func TestTaskQueueOrder(t *testing.T) {
+	q := NewTaskQueue()
+	for _, task := range []Task{
+		{Package: "a", Target: "Fuzz1"},
+		{Package: "a", Target: "Fuzz2"},
+		{Package: "a", Target: "Fuzz3"},
+		{Package: "b", Target: "Fuzz1"},
+		{Package: "c", Target: "Fuzz1", Priority: PriorityCrash},
+		{Package: "b", Target: "Fuzz2"},
+		{Package: "c", Target: "Fuzz2", Priority: PriorityNew},
+		{Package: "a", Target: "Fuzz4", Priority: PriorityNew},
+	} {
+		q.Enqueue(task)
+	}
+
+	want := []string{
+		"c/Fuzz2", "a/Fuzz4", "c/Fuzz1", "a/Fuzz1", "b/Fuzz1",
+		"a/Fuzz2", "b/Fuzz2", "a/Fuzz3",
+	}
+	var listed []string
+	for _, task := range q.Tasks() {
+		listed = append(listed, task.Package+"/"+task.Target)
+	}
+	assert.Equal(t, want, listed)
+	assert.Equal(t, len(want), q.Len())
+
+	assert.Equal(t, want, drainQueue(q))
+	assert.Zero(t, q.Len())
+	_, ok := q.Dequeue()
+	assert.False(t, ok)
+}
+
+// TestTaskQueueEnqueueAgain verifies that a queued task is not queued twice,
+// that enqueueing it with a higher priority moves it up, and that a dequeued
+// task enqueued again waits for its package's next turn.
+func TestTaskQueueEnqueueAgain(t *testing.T) {
+	q := NewTaskQueue()
+	q.Enqueue(Task{Package: "a", Target: "Fuzz1"})
+	q.Enqueue(Task{Package: "a", Target: "Fuzz2"})
+	q.Enqueue(Task{Package: "b", Target: "Fuzz1"})
+	q.Enqueue(Task{Package: "b", Target: "Fuzz2"})
+
+	// A duplicate keeps its place, but raises the race flag and timeout.
+	q.Enqueue(Task{Package: "a", Target: "Fuzz1", Race: true,
+		Timeout: time.Minute})
+	q.Enqueue(Task{Package: "a", Target: "Fuzz1", Timeout: time.Second})
+	require.Equal(t, 4, q.Len())
+	assert.True(t, q.Tasks()[0].Race)
+	assert.Equal(t, time.Minute, q.Tasks()[0].Timeout)
+
+	// A higher priority moves the task up.
+	q.Enqueue(Task{Package: "b", Target: "Fuzz2",
+		Priority: PriorityCrash})
+	assert.Equal(t, Task{Package: "b", Target: "Fuzz2",
+		Priority: PriorityCrash}, q.Tasks()[0])
+
+	// A lower priority leaves it in place.
+	q.Enqueue(Task{Package: "b", Target: "Fuzz2"})
+	assert.Equal(t, PriorityCrash, q.Tasks()[0].Priority)
+
+	task, ok := q.Dequeue()
+	require.True(t, ok)
+	assert.Equal(t, "Fuzz2", task.Target)
+	task, ok = q.Dequeue()
+	require.True(t, ok)
+	assert.Equal(t, Task{Package: "a", Target: "Fuzz1", Race: true,
+		Timeout: time.Minute}, task)
+
+	// Package a just had its turn, so its re-enqueued task comes after
+	// b's, but before a's later ones.
+	q.Enqueue(task)
+	q.Enqueue(Task{Package: "a", Target: "Fuzz3"})
+	assert.Equal(t, []string{"b/Fuzz1", "a/Fuzz2", "a/Fuzz1", "a/Fuzz3"},
+		drainQueue(q))
+}
+
+// TestTaskQueueConcurrent verifies that concurrent producers and consumers
+// dequeue every task exactly once. Run with -race to detect unsynchronized
+// accesses.
+func TestTaskQueueConcurrent(t *testing.T) {
+	const (
+		producers = 8
+		consumers = 8
+		perWorker = 200
+	)
+
+	q := NewTaskQueue()
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		seen     = make(map[string]int)
+		produced sync.WaitGroup
+	)
+	produced.Add(producers)
+	for p := 0; p < producers; p++ {
+		go func() {
+			defer produced.Done()
+			for i := 0; i < perWorker; i++ {
+				q.Enqueue(Task{
+					Package:  fmt.Sprintf("pkg%d", p),
+					Target:   fmt.Sprintf("Fuzz%d", i),
+					Priority: TaskPriority(i % 3),
+				})
+				_ = q.Tasks()
+				_ = q.Len()
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		produced.Wait()
+		close(done)
+	}()
+
+	wg.Add(consumers)
+	for c := 0; c < consumers; c++ {
+		go func() {
+			defer wg.Done()
+			for {
+				task, ok := q.Dequeue()
+				if ok {
+					mu.Lock()
+					seen[task.Package+"/"+task.Target]++
+					mu.Unlock()
+					continue
+				}
+
+				// The queue may be empty only until all tasks
+				// are produced.
+				select {
+				case <-done:
+					if q.Len() == 0 {
+						return
+					}
+				default:
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Len(t, seen, producers*perWorker)
+	for name, count := range seen {
+		assert.Equal(t, 1, count, name)
+	}
+}
+
+// TestTaskPriorityJSON verifies that priorities are encoded by name.
+func TestTaskPriorityJSON(t *testing.T) {
+	task := Task{Package: "a", Target: "Fuzz1", Priority: PriorityNew}
+	data, err := json.Marshal(task)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"package":"a","target":"Fuzz1","race":false,`+
+		`"priority":"new"}`, string(data))
+
+	var decoded Task
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, task, decoded)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"priority":"urgent"}`),
+		&decoded))
+}
+
+// TestNewFuzzTaskQueue verifies that the targets of a cycle are queued with
+// new targets first, then targets in changed packages, which get their own
+// timeout, then targets with an open crash, in sorted order.
+func TestNewFuzzTaskQueue(t *testing.T) {
+	resultsDir := t.TempDir()
+	state, err := loadFuzzState(resultsDir)
+	require.NoError(t, err)
+	state.markGood("parser", "FuzzParse", "abc")
+	state.markGood("lexer", "FuzzLex", "abc")
+	state.markGood("lexer", "FuzzToken", "abc")
+	state.markGood("codec", "FuzzDecode", "abc")
+	for _, rec := range []*crashRecord{
+		{Package: "lexer", Target: "FuzzToken", Signature: "s1"},
+		{Package: "parser", Target: "FuzzEval", Signature: "s2",
+			Fixed: true},
+		{Package: "codec", Target: "FuzzDecode", Signature: "s3"},
+	} {
+		require.NoError(t, saveCrashRecord(resultsDir, rec))
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	q := newFuzzTaskQueue(logger, &Config{
+		FuzzResultsPath: resultsDir,
+		RaceTargets:     []string{"parser:FuzzParse"},
+	}, state, map[string][]string{
+		"parser": {"FuzzParse", "FuzzEval", "FuzzNew"},
+		"lexer":  {"FuzzToken", "FuzzLex"},
+		"codec":  {"FuzzDecode"},
+	}, map[string]bool{"lexer": true}, time.Hour, false)
+
+	assert.Equal(t, []Task{
+		{Package: "parser", Target: "FuzzNew", Priority: PriorityNew},
+		{Package: "lexer", Target: "FuzzLex",
+			Priority: PriorityChanged, Timeout: time.Hour},
+		{Package: "lexer", Target: "FuzzToken",
+			Priority: PriorityChanged, Timeout: time.Hour},
+		{Package: "codec", Target: "FuzzDecode",
+			Priority: PriorityCrash},
+		{Package: "parser", Target: "FuzzEval"},
+		{Package: "parser", Target: "FuzzParse", Race: true},
+	}, q.Tasks())
+}
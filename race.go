@@ -0,0 +1,108 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// raceReportHeader is the line the race detector starts every data
+	// race report with.
+	raceReportHeader = "WARNING: DATA RACE"
+
+	// raceReportDelimiter is the line surrounding every data race report.
+	raceReportDelimiter = "=================="
+
+	// maxRaceFrames is the number of innermost stack frames of each
+	// conflicting access used to deduplicate data races. Deeper frames
+	// only tell how the racy code was reached.
+	maxRaceFrames = 3
+)
+
+var (
+	// raceAccessRegex matches the line introducing the stack of one of the
+	// two conflicting memory accesses in a data race report.
+	//
+	// It matches lines like:
+	//   "Write at 0x00c000014108 by goroutine 8:"
+	//   "Previous read at 0x00c000014108 by main goroutine:"
+	raceAccessRegex = regexp.MustCompile(
+		`^(?:Previous )?(?i:read|write)(?: \(atomic\))? at ` +
+			`0x[0-9a-f]+ by .*:$`,
+	)
+
+	// raceFrameRegex matches the function line of a stack frame in a data
+	// race report, capturing the function name.
+	//
+	// It matches lines like:
+	//   "  example.com/parser.(*Parser).next()"
+	//
+	// Captured groups:
+	//   - "func": the function name (e.g.,
+	//     "example.com/parser.(*Parser).next")
+	raceFrameRegex = regexp.MustCompile(`^  (?P<func>[^\s].*)\(.*\)$`)
+)
+
+// raceSignatureData extracts the deduplication data of the first data race
+// report in the fuzzing output: the innermost frames of the two conflicting
+// accesses. The accesses are ordered canonically, so the same race yields the
+// same data regardless of which access happened first. It returns an empty
+// string if the output contains no data race report.
+func raceSignatureData(output string) string {
+	lines := strings.Split(output, "\n")
+
+	start := -1
+	for i, line := range lines {
+		if strings.TrimSpace(line) == raceReportHeader {
+			start = i + 1
+			break
+		}
+	}
+	if start == -1 {
+		return ""
+	}
+
+	var (
+		stacks  []string
+		current []string
+		inStack bool
+	)
+	flush := func() {
+		if inStack {
+			stacks = append(stacks, strings.Join(current, "\n"))
+		}
+		current, inStack = nil, false
+	}
+
+	for _, line := range lines[start:] {
+		if strings.TrimSpace(line) == raceReportDelimiter {
+			break
+		}
+
+		switch {
+		case raceAccessRegex.MatchString(line):
+			flush()
+			inStack = true
+
+		// A blank line ends the current stack; the goroutine creation
+		// stacks that follow don't identify the race.
+		case strings.TrimSpace(line) == "":
+			flush()
+
+		case inStack && len(current) < maxRaceFrames:
+			matches := raceFrameRegex.FindStringSubmatch(line)
+			if matches != nil {
+				current = append(current, matches[1])
+			}
+		}
+	}
+	flush()
+
+	if len(stacks) == 0 {
+		return ""
+	}
+	sort.Strings(stacks)
+
+	return "DATA RACE\n" + strings.Join(stacks, "\n--\n") + "\n"
+}
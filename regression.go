@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+// regressionAuthor is the author of the commits adding failing inputs to the
+// regression branches.
+const regressionAuthor = "go-continuous-fuzz"
+
+// regressionInputPath returns the slash separated path, relative to the root
+// of the repository, at which committing the failing input of a crash makes
+// "go test" replay it as a seed corpus entry of its target.
+func regressionInputPath(pkg, target, inputID string) string {
+	return path.Join(pkg, "testdata", "fuzz", target, inputID)
+}
+
+// regressionBranchName returns the name of the branch holding the failing
+// input of a crash.
+func regressionBranchName(rec *crashRecord) string {
+	return fmt.Sprintf("fuzz/regression-%s-%s", rec.Target, rec.Signature)
+}
+
+// createRegressionBranches creates a branch in the clone at
+// cfg.RegressionRepoPath for every unfixed crash with a failing input that has
+// none yet. Each branch adds the failing input to the testdata of its target
+// on top of commit, and is recorded in the crash record. The clone is created
+// if missing. Failures are logged, as the crashes are reported regardless.
+func createRegressionBranches(ctx context.Context, logger *slog.Logger,
+	cfg *Config, commit string) {
+
+	records, err := listCrashRecords(cfg.FuzzResultsPath)
+	if err != nil {
+		logger.Error("Failed to list crash records", "error", err)
+		return
+	}
+
+	var repo *git.Repository
+	for _, rec := range records {
+		if rec.Fixed || rec.RegressionBranch != "" ||
+			len(rec.Input) == 0 {
+
+			continue
+		}
+
+		recLogger := logger.With("package", rec.Package, "target",
+			rec.Target, "signature", rec.Signature)
+		if repo == nil {
+			repo, err = openRegressionRepo(ctx, cfg, commit)
+			if err != nil {
+				recLogger.Error("Failed to open regression "+
+					"repository", "path",
+					cfg.RegressionRepoPath, "error", err)
+				return
+			}
+		}
+
+		branch, err := createRegressionBranch(repo, rec, commit,
+			time.Now())
+		if err != nil {
+			recLogger.Error("Failed to create regression branch",
+				"error", err)
+			continue
+		}
+
+		rec.RegressionBranch = branch
+		err = saveCrashRecord(cfg.FuzzResultsPath, rec)
+		if err != nil {
+			recLogger.Error("Failed to save crash record", "error",
+				err)
+			continue
+		}
+		recLogger.Info("Created regression branch", "branch", branch,
+			"path", cfg.RegressionRepoPath)
+	}
+}
+
+// openRegressionRepo opens the clone at cfg.RegressionRepoPath, cloning the
+// project if it does not exist yet, and fetches its remote if it lacks commit.
+func openRegressionRepo(ctx context.Context, cfg *Config,
+	commit string) (*git.Repository, error) {
+
+	repo, err := git.PlainOpen(cfg.RegressionRepoPath)
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		return cloneRepository(ctx, cfg, cfg.RegressionRepoPath, 0)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = repo.CommitObject(plumbing.NewHash(commit))
+	if !errors.Is(err, plumbing.ErrObjectNotFound) {
+		return repo, err
+	}
+	err = repo.FetchContext(ctx, &git.FetchOptions{})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return nil, fmt.Errorf("fetching %s: %w", commit, err)
+	}
+
+	return repo, nil
+}
+
+// createRegressionBranch creates the branch holding the failing input of a
+// crash, with a commit adding the input on top of base. The commit is written
+// directly to the repository's object store, leaving its worktree and HEAD
+// untouched. An existing branch is kept as is. It returns the branch name.
+func createRegressionBranch(repo *git.Repository, rec *crashRecord,
+	base string, now time.Time) (string, error) {
+
+	branch := regressionBranchName(rec)
+	refName := plumbing.NewBranchReferenceName(branch)
+	_, err := repo.Reference(refName, false)
+	if err == nil {
+		return branch, nil
+	}
+	if !errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return "", err
+	}
+
+	parent, err := repo.CommitObject(plumbing.NewHash(base))
+	if err != nil {
+		return "", fmt.Errorf("resolving commit %s: %w", base, err)
+	}
+	tree, err := parent.Tree()
+	if err != nil {
+		return "", err
+	}
+
+	s := repo.Storer
+	blob := s.NewEncodedObject()
+	blob.SetType(plumbing.BlobObject)
+	w, err := blob.Writer()
+	if err != nil {
+		return "", err
+	}
+	if _, err := w.Write(rec.Input); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	blobHash, err := s.SetEncodedObject(blob)
+	if err != nil {
+		return "", err
+	}
+
+	inputPath := regressionInputPath(rec.Package, rec.Target, rec.InputID)
+	treeHash, err := addTreeFile(s, tree, strings.Split(inputPath, "/"),
+		blobHash)
+	if err != nil {
+		return "", fmt.Errorf("adding %s: %w", inputPath, err)
+	}
+
+	signature := object.Signature{
+		Name:  regressionAuthor,
+		Email: regressionAuthor + "@localhost",
+		When:  now,
+	}
+	commit := &object.Commit{
+		Author:    signature,
+		Committer: signature,
+		Message: fmt.Sprintf("%s: add failing input of %s\n\n"+
+			"Found by continuous fuzzing, crash %s (%s). "+
+			"Committing the input makes\n\"go test\" replay it "+
+			"as a seed corpus entry of %s.\n", rec.Package,
+			rec.Target, rec.Signature, rec.Class, rec.Target),
+		TreeHash:     treeHash,
+		ParentHashes: []plumbing.Hash{parent.Hash},
+	}
+	obj := s.NewEncodedObject()
+	if err := commit.Encode(obj); err != nil {
+		return "", err
+	}
+	commitHash, err := s.SetEncodedObject(obj)
+	if err != nil {
+		return "", err
+	}
+
+	err = s.SetReference(plumbing.NewHashReference(refName, commitHash))
+	if err != nil {
+		return "", err
+	}
+
+	return branch, nil
+}
+
+// addTreeFile stores a copy of tree, which may be nil for an empty tree, with
+// the blob added as a regular file at the path given by its elements,
+// creating the missing directories. It returns the hash of the new tree.
+func addTreeFile(s storer.EncodedObjectStorer, tree *object.Tree,
+	elems []string, blob plumbing.Hash) (plumbing.Hash, error) {
+
+	var entries []object.TreeEntry
+	if tree != nil {
+		entries = slices.Clone(tree.Entries)
+	}
+
+	entry := object.TreeEntry{
+		Name: elems[0],
+		Mode: filemode.Regular,
+		Hash: blob,
+	}
+	i := slices.IndexFunc(entries, func(e object.TreeEntry) bool {
+		return e.Name == elems[0]
+	})
+	if len(elems) > 1 {
+		var subtree *object.Tree
+		if i >= 0 && entries[i].Mode == filemode.Dir {
+			var err error
+			subtree, err = object.GetTree(s, entries[i].Hash)
+			if err != nil {
+				return plumbing.ZeroHash, err
+			}
+		}
+
+		hash, err := addTreeFile(s, subtree, elems[1:], blob)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		entry.Mode = filemode.Dir
+		entry.Hash = hash
+	}
+	if i >= 0 {
+		entries[i] = entry
+	} else {
+		entries = append(entries, entry)
+	}
+
+	// Git orders tree entries by name, comparing directories as if their
+	// name ended with a slash.
+	sortName := func(e object.TreeEntry) string {
+		if e.Mode == filemode.Dir {
+			return e.Name + "/"
+		}
+		return e.Name
+	}
+	slices.SortFunc(entries, func(a, b object.TreeEntry) int {
+		return strings.Compare(sortName(a), sortName(b))
+	})
+
+	obj := s.NewEncodedObject()
+	if err := (&object.Tree{Entries: entries}).Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return s.SetEncodedObject(obj)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// initRegressionRepo creates a repository holding a package and returns it
+// along with the hash of its only commit.
+func initRegressionRepo(t *testing.T, dir string) (*git.Repository,
+	string) {
+
+	t.Helper()
+
+	repo, err := git.PlainInit(dir, false)
+	require.NoError(t, err)
+
+	files := map[string]string{
+		"parser/parse.go":  "package parser\n",
+		"parser/parse.txt": "notes\n",
+		"README.md":        "# project\n",
+	}
+	worktree, err := repo.Worktree()
+	require.NoError(t, err)
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		_, err := worktree.Add(name)
+		require.NoError(t, err)
+	}
+	hash, err := worktree.Commit("initial", &git.CommitOptions{
+		Author: &object.Signature{Name: "dev", When: time.Now()},
+	})
+	require.NoError(t, err)
+
+	return repo, hash.String()
+}
+
+// TestCreateRegressionBranch verifies that the regression branch of a crash
+// adds its failing input on top of the base commit, without touching the
+// worktree.
+func TestCreateRegressionBranch(t *testing.T) {
+	dir := t.TempDir()
+	repo, base := initRegressionRepo(t, dir)
+
+	rec := &crashRecord{
+		Package:   "parser",
+		Target:    "FuzzParse",
+		Signature: "342a5c470d17be27",
+		Class:     crashClassPanic,
+		InputID:   "771e938e4458e983",
+		Input:     []byte("go test fuzz v1\nstring(\"0\")\n"),
+	}
+	branch, err := createRegressionBranch(repo, rec, base, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, "fuzz/regression-FuzzParse-342a5c470d17be27", branch)
+
+	ref, err := repo.Reference(plumbing.NewBranchReferenceName(branch),
+		false)
+	require.NoError(t, err)
+	commit, err := repo.CommitObject(ref.Hash())
+	require.NoError(t, err)
+	assert.Equal(t, []plumbing.Hash{plumbing.NewHash(base)},
+		commit.ParentHashes)
+	assert.Contains(t, commit.Message, "342a5c470d17be27")
+
+	file, err := commit.File(
+		"parser/testdata/fuzz/FuzzParse/771e938e4458e983")
+	require.NoError(t, err)
+	content, err := file.Contents()
+	require.NoError(t, err)
+	assert.Equal(t, string(rec.Input), content)
+
+	// The files of the base commit are kept.
+	for _, name := range []string{"parser/parse.go", "parser/parse.txt",
+		"README.md"} {
+
+		_, err := commit.File(name)
+		assert.NoError(t, err, name)
+	}
+
+	// The worktree and HEAD are left alone.
+	head, err := repo.Head()
+	require.NoError(t, err)
+	assert.Equal(t, base, head.Hash().String())
+	assert.NoDirExists(t, filepath.Join(dir, "parser", "testdata"))
+
+	// An existing branch is kept.
+	again, err := createRegressionBranch(repo, rec, base, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, branch, again)
+	ref2, err := repo.Reference(plumbing.NewBranchReferenceName(branch),
+		false)
+	require.NoError(t, err)
+	assert.Equal(t, ref.Hash(), ref2.Hash())
+}
+
+// TestCreateRegressionBranches verifies that a branch is created for every
+// unfixed crash with a failing input, and recorded in its crash record.
+func TestCreateRegressionBranches(t *testing.T) {
+	repoDir := t.TempDir()
+	_, base := initRegressionRepo(t, repoDir)
+
+	cfg := &Config{
+		FuzzResultsPath:    t.TempDir(),
+		RegressionRepoPath: repoDir,
+	}
+	input := []byte("go test fuzz v1\nint(1)\n")
+	records := []*crashRecord{
+		{Package: "parser", Target: "FuzzA", Signature: "a",
+			InputID: "1", Input: input},
+		{Package: "parser", Target: "FuzzB", Signature: "b",
+			InputID: "2", Input: input, Fixed: true},
+		{Package: "parser", Target: "FuzzC", Signature: "c"},
+	}
+	for _, rec := range records {
+		require.NoError(t, saveCrashRecord(cfg.FuzzResultsPath, rec))
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	createRegressionBranches(context.Background(), logger, cfg, base)
+
+	branches := make(map[string]string)
+	for _, rec := range records {
+		loaded, err := loadCrashRecord(filepath.Join(
+			cfg.FuzzResultsPath, crashRecordFileName(rec.Package,
+				rec.Target, rec.Signature)))
+		require.NoError(t, err)
+		branches[rec.Target] = loaded.RegressionBranch
+	}
+	assert.Equal(t, map[string]string{
+		"FuzzA": "fuzz/regression-FuzzA-a",
+		"FuzzB": "",
+		"FuzzC": "",
+	}, branches)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// targetResultSuffix is the file name suffix of target result records.
+const targetResultSuffix = ".result.json"
+
+// targetStatus is the outcome of the latest run of a fuzz target.
+type targetStatus string
+
+const (
+	// targetStatusOK is a run that completed without crashing.
+	targetStatusOK targetStatus = "ok"
+
+	// targetStatusCrashed is a run that found a crash.
+	targetStatusCrashed targetStatus = "crashed"
+
+	// targetStatusBroken is a target whose package failed to build, so it
+	// could not be fuzzed at all.
+	targetStatusBroken targetStatus = "broken"
+
+	// targetStatusFailed is a run that failed for any other reason.
+	targetStatusFailed targetStatus = "failed"
+)
+
+// targetBrokenError is returned for a fuzz target that could not be run
+// because its package failed to build.
+type targetBrokenError struct {
+	// output is the output of the failed build.
+	output string
+}
+
+// Error implements the error interface.
+func (e *targetBrokenError) Error() string {
+	return "fuzz target is broken: package failed to build"
+}
+
+// fuzzRunError is returned for a fuzzing process that failed without
+// reporting a crash.
+type fuzzRunError struct {
+	// err is the error the fuzzing process exited with.
+	err error
+
+	// stderr is the standard error output of the fuzzing process.
+	stderr string
+}
+
+// Error implements the error interface.
+func (e *fuzzRunError) Error() string {
+	if e.stderr == "" {
+		return fmt.Sprintf("fuzz execution failed: %v", e.err)
+	}
+
+	return fmt.Sprintf("fuzz execution failed: %v (stderr: %q)", e.err,
+		e.stderr)
+}
+
+// Unwrap returns the error the fuzzing process exited with.
+func (e *fuzzRunError) Unwrap() error {
+	return e.err
+}
+
+// targetResult records the outcome of the latest run of a fuzz target. It is
+// stored as JSON in the fuzz results directory.
+type targetResult struct {
+	// Package is the package of the fuzz target.
+	Package string `json:"package"`
+
+	// Target is the fuzz target.
+	Target string `json:"target"`
+
+	// Commit is the commit that was fuzzed.
+	Commit string `json:"commit"`
+
+	// Time is when the run completed.
+	Time time.Time `json:"time"`
+
+	// Status is the outcome of the run.
+	Status targetStatus `json:"status"`
+
+	// CrashSignature identifies the crash found by the run, if any.
+	CrashSignature string `json:"crash_signature,omitempty"`
+
+	// Error describes why the run failed, if it did.
+	Error string `json:"error,omitempty"`
+
+	// Stderr is the standard error output of the fuzzing process, or the
+	// build output of a broken target.
+	Stderr string `json:"stderr,omitempty"`
+}
+
+// newTargetResult builds the result record of a run of the task at commit from
+// the crash and error returned by executeFuzzTarget.
+func newTargetResult(task Task, commit string, crash *fuzzCrash,
+	err error) *targetResult {
+
+	res := &targetResult{
+		Package: task.Package,
+		Target:  task.Target,
+		Commit:  commit,
+		Time:    time.Now(),
+		Status:  targetStatusOK,
+	}
+
+	var (
+		brokenErr *targetBrokenError
+		runErr    *fuzzRunError
+	)
+	switch {
+	case errors.As(err, &brokenErr):
+		res.Status = targetStatusBroken
+		res.Error = brokenErr.Error()
+		res.Stderr = brokenErr.output
+
+	case errors.As(err, &runErr):
+		res.Status = targetStatusFailed
+		res.Error = runErr.err.Error()
+		res.Stderr = runErr.stderr
+
+	case err != nil:
+		res.Status = targetStatusFailed
+		res.Error = err.Error()
+
+	case crash != nil:
+		res.Status = targetStatusCrashed
+		if crash.Record != nil {
+			res.CrashSignature = crash.Record.Signature
+		}
+	}
+
+	return res
+}
+
+// targetResultFileName returns the file name of the result record of a fuzz
+// target.
+func targetResultFileName(pkg, target string) string {
+	return fmt.Sprintf("%s_%s%s", pkg, target, targetResultSuffix)
+}
+
+// saveTargetResult writes the result record into the results directory,
+// replacing the record of the target's previous run.
+func saveTargetResult(resultsDir string, res *targetResult) error {
+	data, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding target result: %w", err)
+	}
+
+	if err := EnsureDirExists(resultsDir); err != nil {
+		return err
+	}
+	path := filepath.Join(resultsDir, targetResultFileName(res.Package,
+		res.Target))
+
+	// Write to a temporary file first so that a crash of the daemon never
+	// leaves a truncated record behind.
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("writing target result: %w", err)
+	}
+
+	return os.Rename(tmpPath, path)
+}
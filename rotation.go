@@ -0,0 +1,83 @@
+package main
+
+import (
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// rotateFuzzTargets returns the fuzz targets of pkgTargets to fuzz in this
+// cycle, along with their number. If fuzzing every target for at least
+// cfg.MinTargetFuzzTime, with the targets of the changed packages fuzzed
+// cfg.ChangedTargetBoost times as long, takes more worker time than the cycle
+// has, only the targets that fit are fuzzed. They are taken in the order of
+// their "<package>/<target>" keys, starting at the rotation cursor persisted in
+// the state, which is then moved past them, so that consecutive cycles rotate
+// through all targets.
+func rotateFuzzTargets(logger *slog.Logger, cfg *Config, state *fuzzState,
+	pkgTargets map[string][]string,
+	changed map[string]bool) (map[string][]string, int) {
+
+	type target struct {
+		key, pkg, name string
+		weight         float64
+	}
+	var targets []target
+	totalWeight := 0.0
+	for pkg, names := range pkgTargets {
+		for _, name := range names {
+			weight := 1.0
+			if changed[pkg] {
+				weight = cfg.ChangedTargetBoost
+			}
+			targets = append(targets, target{
+				key:    pkg + "/" + name,
+				pkg:    pkg,
+				name:   name,
+				weight: weight,
+			})
+			totalWeight += weight
+		}
+	}
+
+	capacity := cfg.SyncFrequency.Seconds() * float64(cfg.NumWorkers)
+	minSeconds := cfg.MinTargetFuzzTime.Seconds()
+	if len(targets) == 0 || totalWeight*minSeconds <= capacity {
+		return pkgTargets, len(targets)
+	}
+
+	slices.SortFunc(targets, func(a, b target) int {
+		return strings.Compare(a.key, b.key)
+	})
+
+	// Start at the cursor, or at the target following it if it is gone.
+	cursor := state.rotationCursor()
+	start, _ := slices.BinarySearchFunc(targets, cursor,
+		func(t target, key string) int {
+			return strings.Compare(t.key, key)
+		})
+
+	// Take targets, at least one, until the next one does not fit.
+	selected := make(map[string][]string)
+	count := 0
+	usedWeight := 0.0
+	for count < len(targets) {
+		t := targets[(start+count)%len(targets)]
+		if count > 0 && (usedWeight+t.weight)*minSeconds > capacity {
+			break
+		}
+		selected[t.pkg] = append(selected[t.pkg], t.name)
+		usedWeight += t.weight
+		count++
+	}
+
+	next := targets[(start+count)%len(targets)].key
+	state.setRotationCursor(next)
+
+	logger.Info("Not all fuzz targets fit in the cycle; rotating",
+		"targets", len(targets), "fuzzed", count, "packages",
+		slices.Sorted(maps.Keys(selected)), "next_cursor", next)
+
+	return selected, count
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRotateFuzzTargets verifies that targets not fitting in a cycle are
+// fuzzed in rotation, resuming at the persisted cursor, and that boosted
+// targets take more of the cycle.
+func TestRotateFuzzTargets(t *testing.T) {
+	resultsDir := t.TempDir()
+	state, err := loadFuzzState(resultsDir)
+	require.NoError(t, err)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg := &Config{
+		SyncFrequency:      100 * time.Second,
+		NumWorkers:         1,
+		MinTargetFuzzTime:  30 * time.Second,
+		ChangedTargetBoost: 2,
+	}
+	pkgTargets := map[string][]string{
+		"parser": {"FuzzParse", "FuzzEval"},
+		"lexer":  {"FuzzLex"},
+		"codec":  {"FuzzDecode", "FuzzEncode"},
+	}
+
+	// Three targets fit in a cycle, taken in key order.
+	selected, count := rotateFuzzTargets(logger, cfg, state, pkgTargets,
+		nil)
+	assert.Equal(t, 3, count)
+	assert.Equal(t, map[string][]string{
+		"codec": {"FuzzDecode", "FuzzEncode"},
+		"lexer": {"FuzzLex"},
+	}, selected)
+	assert.Equal(t, "parser/FuzzEval", state.rotationCursor())
+
+	// The next cycle resumes at the cursor, wrapping around, even after
+	// a restart.
+	require.NoError(t, state.save())
+	state, err = loadFuzzState(resultsDir)
+	require.NoError(t, err)
+	selected, count = rotateFuzzTargets(logger, cfg, state, pkgTargets,
+		nil)
+	assert.Equal(t, 3, count)
+	assert.Equal(t, map[string][]string{
+		"codec":  {"FuzzDecode"},
+		"parser": {"FuzzEval", "FuzzParse"},
+	}, selected)
+	assert.Equal(t, "codec/FuzzEncode", state.rotationCursor())
+
+	// A boosted target takes the time of two.
+	selected, count = rotateFuzzTargets(logger, cfg, state, pkgTargets,
+		map[string]bool{"codec": true})
+	assert.Equal(t, 2, count)
+	assert.Equal(t, map[string][]string{
+		"codec": {"FuzzEncode"},
+		"lexer": {"FuzzLex"},
+	}, selected)
+	assert.Equal(t, "parser/FuzzEval", state.rotationCursor())
+
+	// A cursor pointing to a removed target resumes at the next one.
+	state.setRotationCursor("lexer/FuzzRemoved")
+	selected, _ = rotateFuzzTargets(logger, cfg, state, pkgTargets, nil)
+	assert.Equal(t, map[string][]string{
+		"codec":  {"FuzzDecode"},
+		"parser": {"FuzzEval", "FuzzParse"},
+	}, selected)
+
+	// At least one target is fuzzed, even if it does not fit.
+	state.setRotationCursor("")
+	selected, count = rotateFuzzTargets(logger, &Config{
+		SyncFrequency:      10 * time.Second,
+		NumWorkers:         1,
+		MinTargetFuzzTime:  30 * time.Second,
+		ChangedTargetBoost: 2,
+	}, state, pkgTargets, nil)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, map[string][]string{"codec": {"FuzzDecode"}},
+		selected)
+
+	// Targets that all fit are all fuzzed, leaving the cursor alone.
+	cfg.SyncFrequency = time.Hour
+	selected, count = rotateFuzzTargets(logger, cfg, state, pkgTargets,
+		map[string]bool{"codec": true})
+	assert.Equal(t, 5, count)
+	assert.Equal(t, pkgTargets, selected)
+	assert.Equal(t, "codec/FuzzEncode", state.rotationCursor())
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// sandboxInitArg is the hidden first argument with which the daemon
+	// re-executes itself inside the sandbox's namespaces, to set up the
+	// mounts before executing the fuzzing process.
+	sandboxInitArg = "__sandbox_init"
+
+	// sandboxSpecEnv is the environment variable passing the sandboxSpec to
+	// the sandbox init process.
+	sandboxSpecEnv = "GO_CONTINUOUS_FUZZ_SANDBOX_SPEC"
+)
+
+// sandboxLimits are the resource limits of a sandboxed fuzz target. A zero
+// value leaves the resource unlimited.
+type sandboxLimits struct {
+	// MemoryMB is the memory limit, in megabytes, of all processes of the
+	// fuzz target combined.
+	MemoryMB int
+
+	// CPUs is the number of CPUs the fuzz target may use.
+	CPUs float64
+}
+
+// sandboxSpec describes the sandbox set up by the init process before it
+// executes the fuzzing process.
+type sandboxSpec struct {
+	// ReadOnly are the directories mounted read-only.
+	ReadOnly []string `json:"read_only"`
+
+	// Writable are the directories, inside the read-only ones, that stay
+	// writable.
+	Writable []string `json:"writable"`
+
+	// Path is the program executed once the sandbox is set up.
+	Path string `json:"path"`
+
+	// Args are the arguments of the program, including its name.
+	Args []string `json:"args"`
+}
+
+// sandboxLimitUnset marks a limit left unset by a per-target limit, which
+// falls back to the default limit, unlike an explicit 0.
+const sandboxLimitUnset = -1
+
+// parseSandboxTargetLimit parses a per-target limit of the form
+// "<package>:<target>:<memory_mb>:<cpus>". An empty memory or CPU field is
+// returned as sandboxLimitUnset, and falls back to the default limit.
+func parseSandboxTargetLimit(s string) (string, sandboxLimits, error) {
+	limits := sandboxLimits{
+		MemoryMB: sandboxLimitUnset,
+		CPUs:     sandboxLimitUnset,
+	}
+
+	fields := strings.Split(s, ":")
+	if len(fields) != 4 || fields[0] == "" || fields[1] == "" {
+		return "", limits, fmt.Errorf("invalid sandbox target limit "+
+			"%q, expected <package>:<target>:<memory_mb>:<cpus>", s)
+	}
+
+	if fields[2] != "" {
+		memoryMB, err := strconv.Atoi(fields[2])
+		if err != nil || memoryMB < 0 {
+			return "", limits, fmt.Errorf("invalid memory limit "+
+				"in %q", s)
+		}
+		limits.MemoryMB = memoryMB
+	}
+
+	if fields[3] != "" {
+		cpus, err := strconv.ParseFloat(fields[3], 64)
+		if err != nil || cpus < 0 {
+			return "", limits, fmt.Errorf("invalid CPU limit in "+
+				"%q", s)
+		}
+		limits.CPUs = cpus
+	}
+
+	return fields[0] + ":" + fields[1], limits, nil
+}
+
+// targetSandboxLimits returns the resource limits of the fuzz target: its own
+// limits if configured, including an explicit 0 lifting a default limit, and
+// the default limits otherwise.
+func targetSandboxLimits(cfg *Config, pkg, target string) sandboxLimits {
+	limits := sandboxLimits{
+		MemoryMB: cfg.SandboxMemoryMB,
+		CPUs:     cfg.SandboxCPUs,
+	}
+
+	for _, s := range cfg.SandboxTargetLimits {
+		key, targetLimits, err := parseSandboxTargetLimit(s)
+		if err != nil || key != pkg+":"+target {
+			continue
+		}
+		if targetLimits.MemoryMB != sandboxLimitUnset {
+			limits.MemoryMB = targetLimits.MemoryMB
+		}
+		if targetLimits.CPUs != sandboxLimitUnset {
+			limits.CPUs = targetLimits.CPUs
+		}
+	}
+
+	return limits
+}
@@ -0,0 +1,374 @@
+//go:build linux
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// cpuMaxPeriod is the cgroup CPU bandwidth period, in microseconds, used to
+// express CPU limits.
+const cpuMaxPeriod = 100000
+
+// sandbox confines a fuzzing process to new user, mount and network
+// namespaces and, if resource limits are configured, to a cgroup of its own.
+type sandbox struct {
+	// tmpDir is the private temporary directory of the fuzzing process,
+	// the only writable one besides those of its sandboxSpec.
+	tmpDir string
+
+	// cgroupDir is the cgroup v2 directory enforcing the resource limits,
+	// or empty if the fuzz target has no limits.
+	cgroupDir string
+
+	// cgroup is the open cgroup directory the fuzzing process is started
+	// in.
+	cgroup *os.File
+}
+
+// newSandbox prepares the sandbox of a fuzz target, creating its private
+// temporary directory and a cgroup below cfg.SandboxCgroup that enforces the
+// target's resource limits.
+func newSandbox(cfg *Config, pkg, target string) (*sandbox, error) {
+	name := strings.ReplaceAll(pkg, "/", "_") + "_" + target + "-"
+	tmpDir, err := os.MkdirTemp("", "go-continuous-fuzz-sandbox-"+name)
+	if err != nil {
+		return nil, fmt.Errorf("creating temporary directory: %w", err)
+	}
+	sb := &sandbox{tmpDir: tmpDir}
+
+	limits := targetSandboxLimits(cfg, pkg, target)
+	if limits.MemoryMB == 0 && limits.CPUs == 0 {
+		return sb, nil
+	}
+
+	if err := os.MkdirAll(cfg.SandboxCgroup, 0755); err != nil {
+		sb.close(nil)
+		return nil, fmt.Errorf("creating parent cgroup: %w", err)
+	}
+
+	// Delegate the memory and CPU controllers to the targets' cgroups.
+	err = os.WriteFile(filepath.Join(cfg.SandboxCgroup,
+		"cgroup.subtree_control"), []byte("+memory +cpu"), 0644)
+	if err != nil {
+		sb.close(nil)
+		return nil, fmt.Errorf("enabling cgroup controllers: %w", err)
+	}
+
+	sb.cgroupDir, err = os.MkdirTemp(cfg.SandboxCgroup, name)
+	if err != nil {
+		sb.close(nil)
+		return nil, fmt.Errorf("creating cgroup: %w", err)
+	}
+
+	settings := make(map[string]string)
+	if limits.MemoryMB > 0 {
+		settings["memory.max"] = strconv.Itoa(limits.MemoryMB *
+			bytesPerMB)
+	}
+	if limits.CPUs > 0 {
+		quota := int(limits.CPUs * cpuMaxPeriod)
+		settings["cpu.max"] = fmt.Sprintf("%d %d", quota, cpuMaxPeriod)
+	}
+	for file, value := range settings {
+		err := os.WriteFile(filepath.Join(sb.cgroupDir, file),
+			[]byte(value), 0644)
+		if err != nil {
+			sb.close(nil)
+			return nil, fmt.Errorf("setting %s: %w", file, err)
+		}
+	}
+
+	// Without swap, exceeding the memory limit kills the fuzzing process
+	// instead of slowing it down. Not every kernel accounts swap.
+	_ = os.WriteFile(filepath.Join(sb.cgroupDir, "memory.swap.max"),
+		[]byte("0"), 0644)
+
+	sb.cgroup, err = os.Open(sb.cgroupDir)
+	if err != nil {
+		sb.close(nil)
+		return nil, fmt.Errorf("opening cgroup: %w", err)
+	}
+
+	return sb, nil
+}
+
+// wrap rewrites cmd so that it runs in the sandbox. The daemon re-executes
+// itself as the sandbox init process, which mounts the directories of spec and
+// then executes the original command. The command's TMPDIR is the sandbox's
+// private temporary directory, which stays writable.
+func (sb *sandbox) wrap(cmd *exec.Cmd, spec sandboxSpec) error {
+	self, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("locating executable: %w", err)
+	}
+
+	spec.Path = cmd.Path
+	spec.Args = cmd.Args
+	spec.Writable = append(slices.Clone(spec.Writable), sb.tmpDir)
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+
+	cmd.Path = self
+	cmd.Args = []string{self, sandboxInitArg}
+	cmd.Env = append(env, "TMPDIR="+sb.tmpDir,
+		sandboxSpecEnv+"="+string(data))
+
+	// Map the current user to root in the new user namespace, so that
+	// the init process may set up the mounts.
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags: syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS |
+			syscall.CLONE_NEWNET,
+		UidMappings: []syscall.SysProcIDMap{{
+			ContainerID: 0, HostID: os.Getuid(), Size: 1,
+		}},
+		GidMappings: []syscall.SysProcIDMap{{
+			ContainerID: 0, HostID: os.Getgid(), Size: 1,
+		}},
+		Pdeathsig: syscall.SIGKILL,
+	}
+	if sb.cgroup != nil {
+		cmd.SysProcAttr.UseCgroupFD = true
+		cmd.SysProcAttr.CgroupFD = int(sb.cgroup.Fd())
+	}
+
+	return nil
+}
+
+// limitExceeded reports whether a process in the sandbox was killed for
+// exceeding the memory limit.
+func (sb *sandbox) limitExceeded() bool {
+	if sb.cgroupDir == "" {
+		return false
+	}
+
+	f, err := os.Open(filepath.Join(sb.cgroupDir, "memory.events"))
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, _ := strings.Cut(scanner.Text(), " ")
+		if key == "oom_kill" && value != "0" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// close kills any process left in the sandbox's cgroup and removes it, along
+// with the sandbox's temporary directory.
+func (sb *sandbox) close(logger *slog.Logger) {
+	if err := os.RemoveAll(sb.tmpDir); err != nil && logger != nil {
+		logger.Warn("Failed to remove sandbox temporary directory",
+			"dir", sb.tmpDir, "error", err)
+	}
+
+	if sb.cgroupDir == "" {
+		return
+	}
+	if sb.cgroup != nil {
+		sb.cgroup.Close()
+	}
+
+	_ = os.WriteFile(filepath.Join(sb.cgroupDir, "cgroup.kill"),
+		[]byte("1"), 0644)
+	if err := os.Remove(sb.cgroupDir); err != nil && logger != nil {
+		logger.Warn("Failed to remove sandbox cgroup", "cgroup",
+			sb.cgroupDir, "error", err)
+	}
+}
+
+// runSandboxInit is the entry point of the sandbox init process. It sets up the
+// mounts described by the spec in its environment and executes the fuzzing
+// process in its place. It never returns.
+func runSandboxInit() {
+	var spec sandboxSpec
+	err := json.Unmarshal([]byte(os.Getenv(sandboxSpecEnv)), &spec)
+	if err == nil {
+		err = setupSandboxMounts(spec)
+	}
+	if err == nil {
+		os.Unsetenv(sandboxSpecEnv)
+		err = syscall.Exec(spec.Path, spec.Args, os.Environ())
+	}
+
+	fmt.Fprintf(os.Stderr, "sandbox setup failed: %v\n", err)
+	os.Exit(1)
+}
+
+// setupSandboxMounts makes the read-only directories of the spec, and every
+// mount below them, read-only, except for the writable directories inside
+// them.
+func setupSandboxMounts(spec sandboxSpec) error {
+	// Keep the mounts below from propagating back to the host.
+	err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE,
+		"")
+	if err != nil {
+		return fmt.Errorf("making mounts private: %w", err)
+	}
+
+	// Writable directories become mounts of their own first, so that
+	// they are carried over, writable, by the recursive bind mounts of the
+	// read-only directories.
+	for _, dir := range spec.Writable {
+		err := syscall.Mount(dir, dir, "",
+			syscall.MS_BIND|syscall.MS_REC, "")
+		if err != nil {
+			return fmt.Errorf("bind mounting %s: %w", dir, err)
+		}
+	}
+
+	for _, dir := range spec.ReadOnly {
+		err := syscall.Mount(dir, dir, "",
+			syscall.MS_BIND|syscall.MS_REC, "")
+		if err != nil {
+			return fmt.Errorf("bind mounting %s: %w", dir, err)
+		}
+
+		// A read-only remount only applies to a single mount, so the
+		// mounts below the directory are remounted one by one.
+		mounts, err := readOnlyMounts(dir, spec.Writable)
+		if err != nil {
+			return err
+		}
+		for _, mount := range mounts {
+			if err := remountReadOnly(mount); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// pseudoFilesystems are the kernel filesystems whose files are protected by
+// their permissions rather than by a read-only mount. Their mounts are left
+// alone, as some of them cannot be remounted in a user namespace.
+var pseudoFilesystems = map[string]bool{
+	"autofs": true, "binfmt_misc": true, "bpf": true, "cgroup": true,
+	"cgroup2": true, "configfs": true, "debugfs": true, "devpts": true,
+	"devtmpfs": true, "efivarfs": true, "fusectl": true, "hugetlbfs": true,
+	"mqueue": true, "nsfs": true, "proc": true, "pstore": true,
+	"securityfs": true, "sysfs": true, "tracefs": true,
+}
+
+// readOnlyMounts returns the mount points to remount read-only to make dir
+// read-only: dir itself and the mounts below it, except for the writable
+// directories, the mounts below them and the mounts of pseudoFilesystems.
+func readOnlyMounts(dir string, writable []string) ([]string, error) {
+	data, err := os.ReadFile("/proc/self/mountinfo")
+	if err != nil {
+		return nil, fmt.Errorf("listing mounts: %w", err)
+	}
+
+	mounts := []string{dir}
+	for _, line := range strings.Split(string(data), "\n") {
+		// The fields are documented in proc(5); the filesystem type
+		// follows the "-" separator.
+		fields := strings.Fields(line)
+		sep := slices.Index(fields, "-")
+		if len(fields) < 5 || sep < 0 || sep+1 >= len(fields) {
+			continue
+		}
+		mount := unescapeMountPath(fields[4])
+		inWritable := slices.ContainsFunc(writable,
+			func(w string) bool { return pathWithin(mount, w) })
+		if pseudoFilesystems[fields[sep+1]] || inWritable ||
+			!pathWithin(mount, dir) ||
+			slices.Contains(mounts, mount) {
+
+			continue
+		}
+		mounts = append(mounts, mount)
+	}
+
+	return mounts, nil
+}
+
+// pathWithin reports whether path is dir or lies below it.
+func pathWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+
+	return err == nil && rel != ".." &&
+		!strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// unescapeMountPath decodes the octal escapes, such as \040 for a space, of a
+// mount point listed in /proc/self/mountinfo.
+func unescapeMountPath(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			n, err := strconv.ParseUint(s[i+1:i+4], 8, 8)
+			if err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+
+	return b.String()
+}
+
+// remountReadOnly remounts the bind mount at dir read-only.
+func remountReadOnly(dir string) error {
+	// A remount must keep the flags locked by the mount the directory
+	// lives on.
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return fmt.Errorf("inspecting %s: %w", dir, err)
+	}
+	err := syscall.Mount("", dir, "", syscall.MS_BIND|syscall.MS_REMOUNT|
+		syscall.MS_RDONLY|lockedMountFlags(int64(st.Flags)), "")
+	if err != nil {
+		return fmt.Errorf("remounting %s read-only: %w", dir, err)
+	}
+
+	return nil
+}
+
+// statfsMountFlags maps the statfs flags of a mount to the corresponding mount
+// flags that an unprivileged remount must preserve.
+var statfsMountFlags = map[int64]uintptr{
+	0x0002: syscall.MS_NOSUID,
+	0x0004: syscall.MS_NODEV,
+	0x0008: syscall.MS_NOEXEC,
+	0x0400: syscall.MS_NOATIME,
+	0x0800: syscall.MS_NODIRATIME,
+	0x1000: syscall.MS_RELATIME,
+}
+
+// lockedMountFlags returns the mount flags corresponding to the statfs flags.
+func lockedMountFlags(statfsFlags int64) uintptr {
+	var flags uintptr
+	for statfsFlag, mountFlag := range statfsMountFlags {
+		if statfsFlags&statfsFlag != 0 {
+			flags |= mountFlag
+		}
+	}
+
+	return flags
+}
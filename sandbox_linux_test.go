@@ -0,0 +1,92 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMain lets the test binary act as the sandbox init process, like the
+// daemon does when it re-executes itself.
+func TestMain(m *testing.M) {
+	if len(os.Args) > 1 && os.Args[1] == sandboxInitArg {
+		runSandboxInit()
+	}
+
+	os.Exit(m.Run())
+}
+
+// TestSandboxMounts verifies that a sandboxed process cannot write to the
+// read-only file system, except to its writable directories and private
+// temporary directory, and has no network access.
+func TestSandboxMounts(t *testing.T) {
+	if err := exec.Command("unshare", "-Urmn", "true").Run(); err != nil {
+		t.Skipf("user namespaces are unavailable: %v", err)
+	}
+
+	projectDir := t.TempDir()
+	writableDir := filepath.Join(projectDir, "pkg", "testdata")
+	require.NoError(t, os.MkdirAll(writableDir, 0755))
+
+	sb, err := newSandbox(&Config{}, "pkg", "FuzzFoo")
+	require.NoError(t, err)
+	defer sb.close(nil)
+
+	otherDir := t.TempDir()
+	script := "touch " + filepath.Join(projectDir, "pkg", "escaped") +
+		"; touch " + filepath.Join(otherDir, "escaped") +
+		"; touch " + filepath.Join(writableDir, "input") +
+		"; touch $TMPDIR/tmp && echo tmp ok" +
+		"; echo null > /dev/null && echo null ok" +
+		"; cat /proc/net/dev"
+	cmd := exec.Command("/bin/sh", "-c", script)
+	require.NoError(t, sb.wrap(cmd, sandboxSpec{
+		ReadOnly: []string{"/"},
+		Writable: []string{writableDir},
+	}))
+
+	output, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(output))
+
+	assert.Contains(t, string(output), "Read-only file system")
+	assert.NoFileExists(t, filepath.Join(projectDir, "pkg", "escaped"))
+	assert.NoFileExists(t, filepath.Join(otherDir, "escaped"))
+	assert.FileExists(t, filepath.Join(writableDir, "input"))
+	assert.Contains(t, string(output), "tmp ok")
+	assert.Contains(t, string(output), "null ok")
+
+	// None of the host's network interfaces but loopback exist in the
+	// new network namespace.
+	hostDev, err := os.ReadFile("/proc/net/dev")
+	require.NoError(t, err)
+	for _, line := range strings.Split(string(hostDev), "\n") {
+		name, _, ok := strings.Cut(line, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "lo" {
+			continue
+		}
+		assert.NotContains(t, string(output), " "+name+":")
+	}
+}
+
+// TestMountPaths verifies that escaped mount points are decoded and that only
+// paths equal to or below a directory are found within it.
+func TestMountPaths(t *testing.T) {
+	assert.Equal(t, "/mnt/my disk\\x", unescapeMountPath(
+		`/mnt/my\040disk\134x`))
+	assert.Equal(t, `/mnt/a\0`, unescapeMountPath(`/mnt/a\0`))
+
+	assert.True(t, pathWithin("/", "/"))
+	assert.True(t, pathWithin("/tmp/a", "/"))
+	assert.True(t, pathWithin("/tmp/a/b", "/tmp/a"))
+	assert.False(t, pathWithin("/tmp/ab", "/tmp/a"))
+	assert.False(t, pathWithin("/tmp", "/tmp/a"))
+	assert.True(t, pathWithin("/tmp/..a", "/tmp"))
+}
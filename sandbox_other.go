@@ -0,0 +1,44 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+)
+
+// errSandboxUnsupported is returned when sandboxing is requested on a platform
+// other than Linux.
+var errSandboxUnsupported = errors.New("sandboxing is only supported on " +
+	"Linux")
+
+// sandbox is a placeholder for the Linux sandbox.
+type sandbox struct{}
+
+// newSandbox fails, as sandboxing is only supported on Linux.
+func newSandbox(cfg *Config, pkg, target string) (*sandbox, error) {
+	return nil, errSandboxUnsupported
+}
+
+// wrap fails, as sandboxing is only supported on Linux.
+func (sb *sandbox) wrap(cmd *exec.Cmd, spec sandboxSpec) error {
+	return errSandboxUnsupported
+}
+
+// limitExceeded always returns false.
+func (sb *sandbox) limitExceeded() bool {
+	return false
+}
+
+// close does nothing.
+func (sb *sandbox) close(logger *slog.Logger) {}
+
+// runSandboxInit exits with an error, as sandboxing is only supported on
+// Linux.
+func runSandboxInit() {
+	fmt.Fprintln(os.Stderr, errSandboxUnsupported)
+	os.Exit(1)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTargetSandboxLimits verifies that per-target limits override the default
+// limits, field by field, and that an explicit 0 lifts a default limit.
+func TestTargetSandboxLimits(t *testing.T) {
+	cfg := &Config{
+		SandboxMemoryMB: 2048,
+		SandboxCPUs:     1,
+		SandboxTargetLimits: []string{
+			"parser:FuzzParseComplex:512:",
+			"parser:FuzzEvalExpr::0.5",
+			"parser:FuzzUnlimited:0:0",
+		},
+	}
+
+	tests := []struct {
+		name     string
+		target   string
+		expected sandboxLimits
+	}{
+		{
+			name:     "memory override",
+			target:   "FuzzParseComplex",
+			expected: sandboxLimits{MemoryMB: 512, CPUs: 1},
+		},
+		{
+			name:     "cpu override",
+			target:   "FuzzEvalExpr",
+			expected: sandboxLimits{MemoryMB: 2048, CPUs: 0.5},
+		},
+		{
+			name:     "unlimited",
+			target:   "FuzzUnlimited",
+			expected: sandboxLimits{},
+		},
+		{
+			name:     "defaults",
+			target:   "FuzzOther",
+			expected: sandboxLimits{MemoryMB: 2048, CPUs: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, targetSandboxLimits(cfg,
+				"parser", tt.target))
+		})
+	}
+}
+
+// TestParseSandboxTargetLimitErrors verifies that malformed per-target limits
+// are rejected.
+func TestParseSandboxTargetLimitErrors(t *testing.T) {
+	for _, limit := range []string{
+		"parser:FuzzEvalExpr",
+		"parser::512:1",
+		"parser:FuzzEvalExpr:lots:1",
+		"parser:FuzzEvalExpr:512:-1",
+	} {
+		_, _, err := parseSandboxTargetLimit(limit)
+		require.Error(t, err, limit)
+	}
+}
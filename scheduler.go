@@ -2,120 +2,270 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
-	"os"
+	"maps"
+	"slices"
 	"time"
 
-	"github.com/go-git/go-git/v5"
-	"github.com/go-git/go-git/v5/plumbing"
+	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/sync/errgroup"
 )
 
 // startFuzzCycles runs an infinite loop of fuzzing cycles. Each cycle consists
 // of:
 //  1. Cloning or pulling the Git repository specified in cfg.ProjectSrcPath.
-//  2. Listing fuzz targets in the cloned repository.
+//  2. Building the test binaries and listing fuzz targets in the cloned
+//     repository, reporting the targets of packages that fail to build as
+//     broken, finding the packages affected by the commits since the
+//     previous cycle, and minimizing the corpus every cfg.CorpusMinimizeEvery
+//     cycles.
 //  3. Launching scheduler goroutines to execute all fuzz targets for a portion
 //     of cfg.SyncFrequency.
-//  4. Cleaning up the workspace (deleting cfg.ProjectDir, temporary artifacts,
-//     etc.).
+//  4. Measuring the coverage of the corpus, if enabled, and cleaning up the
+//     workspace (deleting cfg.ProjectDir, temporary artifacts, etc.).
+//  5. Storing the per-target statistics of the cycle, bisecting new
+//     crashes and creating their regression branches, if enabled,
+//     persisting the fuzz state and pruning the persistent Go caches.
 //
-// The loop repeats until the parent context is canceled. Errors in cloning or
-// target discovery are returned immediately
+// The loop repeats until the parent context is canceled, which returns nil.
+// Errors in loading the state, syncing the repository or corpus, target
+// discovery and fuzzing are returned immediately, once they are notified and
+// the workspace is cleaned up. The progress of the cycles is
+// recorded in m and status, and each cycle is traced as a span with a child
+// span per phase.
 func startFuzzCycles(ctx context.Context, logger *slog.Logger, cfg *Config,
-	cycleDuration time.Duration) {
+	m *fuzzMetrics, status *fuzzStatus,
+	cycleDuration time.Duration) error {
+
+	// Create the notifier announcing crashes and failures to the
+	// configured sinks.
+	n := newNotifier(logger, cfg)
+	defer n.close()
+
+	// Load the state persisted by previous cycles.
+	state, err := loadFuzzState(cfg.FuzzResultsPath)
+	if err != nil {
+		logger.Error("Failed to load fuzz state", "error", err)
+		notifyFatalFailure(n, m, eventCycleFailure,
+			"Failed to load fuzz state", err)
+		cleanupWorkspace(logger, cfg)
+		return fmt.Errorf("loading fuzz state: %w", err)
+	}
 
 	for {
+		cycle := state.startCycle()
+		cycleStart := time.Now()
+		cycleCtx, cycleSpan := startSpan(ctx, "cycle",
+			attribute.Int("cycle", cycle))
+
 		// 1. Clone or pull the repository.
-		logger.Info("Syncing project repository", "repo_url",
+		status.enterPhase(phaseSyncing, cfg.HealthStallTimeout)
+		logger.Info("Syncing project repository", "cycle", cycle,
+			"repo_url",
 			SanitizeURL(cfg.ProjectSrcPath), "local_path",
 			cfg.ProjectDir)
 
-		_, err := git.PlainCloneContext(
-			ctx, cfg.ProjectDir, false, &git.CloneOptions{
-				URL: cfg.ProjectSrcPath,
-				// Temporary until the previous PR got merged
-				ReferenceName: plumbing.NewBranchReferenceName(
-					"fuzz-example"),
-				SingleBranch: true,
-				Depth:        1,
-				// // Temporary until the previous PR got merged
-			},
-		)
+		// Keep the commit of the previous cycle, to find the packages
+		// changed since.
+		prevCommit := state.lastCommit()
+
+		cloneStart := time.Now()
+		repo, err := cloneRepository(cycleCtx, cfg, cfg.ProjectDir, 1)
+		if err == nil {
+			m.observeClone(time.Since(cloneStart))
+
+			var commit string
+			commit, err = headCommit(repo)
+			state.setLastCommit(commit)
+			cycleSpan.SetAttributes(attribute.String("commit",
+				commit))
+		}
+		status.setRepoSynced(err == nil)
 		if err != nil {
 			logger.Error("Failed to sync repository; aborting "+
 				"scheduler", "error", err)
+			notifyFatalFailure(n, m, eventCycleFailure,
+				"Failed to sync repository", err)
 
 			// Perform workspace cleanup before exiting due to the
 			// cloning error.
 			cleanupWorkspace(logger, cfg)
-			os.Exit(1)
+			endSpan(cycleSpan, err)
+			return fmt.Errorf("syncing repository: %w", err)
 		}
 
 		// Download corpus from S3 bucket
 		s3Client, err := createS3Client(ctx)
 		if err != nil {
 			logger.Error("Failed to create S3 client", "error", err)
+			notifyFatalFailure(n, m, eventCycleFailure,
+				"Failed to create S3 client", err)
 
 			// Perform workspace cleanup before exiting due to the
 			// corpus download error.
 			cleanupWorkspace(logger, cfg)
-			os.Exit(1)
+			endSpan(cycleSpan, err)
+			return fmt.Errorf("creating S3 client: %w", err)
 		}
 
+		status.enterPhase(phaseDownloading, cfg.HealthStallTimeout)
 		corpusZipPath := cfg.CorpusDir + ".zip"
-		empty, err := downloadObject(ctx, s3Client, cfg.S3BucketName,
-			CorpusKey, corpusZipPath, logger)
+		empty, err := downloadObject(cycleCtx, s3Client,
+			cfg.S3BucketName, CorpusKey, corpusZipPath, logger, m)
+		status.setStorageErr(err)
 		if err != nil {
 			logger.Error("Download failed", "error", err)
+			notifyFatalFailure(n, m, eventCycleFailure,
+				"Failed to download corpus", err)
 
 			// Perform workspace cleanup before exiting due to the
 			// corpus download error.
 			cleanupWorkspace(logger, cfg)
-			os.Exit(1)
+			endSpan(cycleSpan, err)
+			return fmt.Errorf("downloading corpus: %w", err)
 		}
 
 		if !empty {
-			if err := unzip(corpusZipPath, cfg.CorpusDir,
-				logger); err != nil {
+			_, span := startSpan(cycleCtx, "unzip corpus")
+			err := unzip(corpusZipPath, cfg.CorpusDir, logger)
+			if err == nil {
+				_, err = removeInvalidInputs(logger,
+					cfg.CorpusDir)
+			}
+			endSpan(span, err)
+			if err != nil {
 				logger.Error("Unzip failed", "error", err)
+				notifyFatalFailure(n, m, eventCycleFailure,
+					"Failed to unzip corpus", err)
 
 				// Perform workspace cleanup before exiting due
 				// to the corpus download error.
 				cleanupWorkspace(logger, cfg)
-				os.Exit(1)
+				endSpan(cycleSpan, err)
+				return fmt.Errorf("unzipping corpus: %w", err)
 			}
 		}
 
-		// 2. Discover fuzz targets.
-		pkgTargets, totalTargets, err := listPkgsFuzzTargets(ctx,
-			logger, cfg)
+		// 2. Build the test binaries and discover fuzz targets. The
+		// binaries are shared by all workers for the rest of the
+		// cycle.
+		status.enterPhase(phaseBuilding, cfg.HealthStallTimeout)
+		bins := newTestBinaries(cfg)
+		buildCtx, span := startSpan(cycleCtx, "discover fuzz targets")
+		pkgTargets, totalTargets, broken, err := listPkgsFuzzTargets(
+			buildCtx, logger, cfg, bins)
+		span.SetAttributes(attribute.Int("targets", totalTargets),
+			attribute.Int("broken_packages", len(broken)))
+		endSpan(span, err)
 		if err != nil {
 			logger.Error("Failed to list fuzz targets; aborting "+
 				"scheduler", "error", err)
+			notifyFatalFailure(n, m, eventTargetBuildFailure,
+				"Failed to build fuzz targets", err)
 
 			// Perform workspace cleanup before exiting due to the
 			// list fuzz targets error.
 			cleanupWorkspace(logger, cfg)
-			os.Exit(1)
+			endSpan(cycleSpan, err)
+			return fmt.Errorf("listing fuzz targets: %w", err)
 		}
 
-		if totalTargets == 0 {
+		if totalTargets == 0 && len(broken) == 0 {
 			logger.Warn("No fuzz targets found; aborting " +
 				"scheduler; please add some fuzz targets")
 			cleanupWorkspace(logger, cfg)
-			os.Exit(0)
+			cycleSpan.End()
+			return nil
+		}
+
+		// Report the targets of the packages that failed to build as
+		// broken, and keep fuzzing the others.
+		reportBrokenPackages(logger, cfg, n, broken,
+			state.lastCommit())
+
+		// If no package built, there is nothing to fuzz until the
+		// next cycle brings a fix.
+		if totalTargets == 0 {
+			logger.Warn("No package with fuzz targets built; " +
+				"waiting for the next cycle")
+			status.enterPhase(phaseWaiting,
+				cycleDuration+cfg.HealthStallTimeout)
+			cleanupWorkspace(logger, cfg)
+			if err := state.save(); err != nil {
+				logger.Error("Failed to save fuzz state",
+					"error", err)
+			}
+
+			select {
+			case <-time.After(cycleDuration):
+				cycleSpan.End()
+				continue
+
+			case <-ctx.Done():
+				endSpan(cycleSpan, ctx.Err())
+				return nil
+			}
+		}
+
+		// Find the packages affected by the commits since the previous
+		// cycle, whose targets are fuzzed first and longer.
+		changed := changedFuzzPackages(cycleCtx, logger, cfg, repo,
+			prevCommit, state.lastCommit(), pkgTargets)
+
+		// Check whether previously found crashes have been fixed in
+		// the freshly synced code, within the time the phase may take.
+		status.enterPhase(phaseVerifying, cfg.HealthStallTimeout)
+		verifyCtx, cancelVerify := context.WithTimeout(cycleCtx,
+			cfg.HealthStallTimeout)
+		verifyCtx, span = startSpan(verifyCtx, "verify crash fixes")
+		verifyCrashFixes(verifyCtx, logger, cfg, pkgTargets, n)
+		span.End()
+		cancelVerify()
+
+		// Every cfg.CorpusMinimizeEvery-th cycle starts by minimizing
+		// the corpus, so that the minimized corpus is fuzzed and
+		// uploaded at the end of the cycle.
+		if cfg.CorpusMinimizeEvery > 0 &&
+			cycle%cfg.CorpusMinimizeEvery == 0 {
+
+			status.enterPhase(phaseMinimizing,
+				cfg.HealthStallTimeout)
+			minimizeCtx, span := startSpan(cycleCtx,
+				"minimize corpus")
+			_, err := minimizeStoredCorpus(minimizeCtx, logger, cfg,
+				s3Client, m, pkgTargets)
+			endSpan(span, err)
+			if err != nil {
+				logger.Error("Failed to minimize corpus",
+					"error", err)
+			}
 		}
 
 		// 3. Create a cycle sub-context for this fuzz iteration.
-		schedulerCtx, cancelCycle := context.WithCancel(ctx)
+		schedulerCtx, cancelCycle := context.WithCancel(cycleCtx)
 
 		// Channel to check if the cycle is cancelled, before cleanup.
 		doneChan := make(chan struct{})
 
-		// Launch the fuzz worker scheduler as a goroutine.
-		go scheduleFuzzing(schedulerCtx, logger, cfg,
-			pkgTargets, totalTargets, doneChan)
+		// Collect the statistics of the fuzz targets over the cycle.
+		stats := newCycleStats(cycle, state.lastCommit())
+		m.cycleStarted(stats)
+		status.cycleStarted(cycle, state.lastCommit(), stats)
+
+		// Launch the fuzz worker scheduler as a goroutine. Fuzzing
+		// takes up to the cycle duration. Its error is set before
+		// doneChan is closed.
+		status.enterPhase(phaseFuzzing,
+			cycleDuration+cfg.HealthStallTimeout)
+		var fuzzErr error
+		go func() {
+			defer close(doneChan)
+			fuzzErr = scheduleFuzzing(schedulerCtx, logger, cfg,
+				newExecutor(logger, cfg, bins), pkgTargets,
+				totalTargets, changed, n, m, status, state,
+				stats)
+		}()
 
 		// 4. Wait for either:
 		//    A) All workers finish early
@@ -123,17 +273,30 @@ func startFuzzCycles(ctx context.Context, logger *slog.Logger, cfg *Config,
 		//    C) Parent context cancellation
 		select {
 		case <-doneChan:
+			// A failed fuzzing process stops the daemon, leaving
+			// the corpus as it was.
+			if fuzzErr != nil {
+				cancelCycle()
+				cleanupWorkspace(logger, cfg)
+				endSpan(cycleSpan, fuzzErr)
+				return fuzzErr
+			}
+
 			// If all the workers stopped prematurely
 			logger.Info("All workers completed early; cleaning " +
 				"up cycle")
 
 			// Upload the updated corpus back to cloud storage
-			zipUploadCorpus(schedulerCtx, s3Client,
-				cfg.S3BucketName, CorpusKey, cfg.CorpusDir,
-				logger)
+			status.enterPhase(phaseUploading,
+				cfg.HealthStallTimeout)
+			status.setStorageErr(zipUploadCorpus(schedulerCtx,
+				s3Client, cfg.S3BucketName, CorpusKey,
+				cfg.CorpusDir, logger, m))
 
 			// Cancel the current cycle.
 			cancelCycle()
+			measureCycleCoverage(cycleCtx, logger, cfg, status,
+				pkgTargets, cycle, state.lastCommit())
 			cleanupWorkspace(logger, cfg)
 
 		case <-time.After(cycleDuration):
@@ -141,9 +304,11 @@ func startFuzzCycles(ctx context.Context, logger *slog.Logger, cfg *Config,
 				"cleanup.")
 
 			// Upload the updated corpus back to cloud storage
-			zipUploadCorpus(schedulerCtx, s3Client,
-				cfg.S3BucketName, CorpusKey, cfg.CorpusDir,
-				logger)
+			status.enterPhase(phaseUploading,
+				cfg.HealthStallTimeout)
+			status.setStorageErr(zipUploadCorpus(schedulerCtx,
+				s3Client, cfg.S3BucketName, CorpusKey,
+				cfg.CorpusDir, logger, m))
 
 			// Cancel the current cycle.
 			cancelCycle()
@@ -151,6 +316,13 @@ func startFuzzCycles(ctx context.Context, logger *slog.Logger, cfg *Config,
 			// wait before the fuzzing worker is closed before
 			// cleanup.
 			<-doneChan
+			if fuzzErr != nil {
+				cleanupWorkspace(logger, cfg)
+				endSpan(cycleSpan, fuzzErr)
+				return fuzzErr
+			}
+			measureCycleCoverage(cycleCtx, logger, cfg, status,
+				pkgTargets, cycle, state.lastCommit())
 			cleanupWorkspace(logger, cfg)
 
 		case <-ctx.Done():
@@ -167,74 +339,239 @@ func startFuzzCycles(ctx context.Context, logger *slog.Logger, cfg *Config,
 			// cleanup.
 			<-doneChan
 			cleanupWorkspace(logger, cfg)
+			saveStats(logger, cfg, stats)
+
+			// Persist the state, keeping any pending bisections
+			// for the next run.
+			if err := state.save(); err != nil {
+				logger.Error("Failed to save fuzz state",
+					"error", err)
+			}
+			endSpan(cycleSpan, ctx.Err())
+
+			return nil
+		}
+
+		status.enterPhase(phaseFinishing, cfg.HealthStallTimeout)
 
-			return
+		// 5. Store the cycle's statistics, bisect the crashes found in
+		// this cycle, commit their failing inputs to regression
+		// branches and persist the state for the next one.
+		saveStats(logger, cfg, stats)
+		bisectPendingCrashes(cycleCtx, logger, cfg, state, n)
+		if cfg.RegressionRepoPath != "" {
+			createRegressionBranches(cycleCtx, logger, cfg,
+				state.lastCommit())
 		}
+		if err := state.save(); err != nil {
+			logger.Error("Failed to save fuzz state", "error", err)
+		}
+
+		// Keep the persistent Go caches within their size limits.
+		pruneGoCaches(cycleCtx, logger, cfg)
+
+		m.cycleCompleted(time.Since(cycleStart))
+		cycleSpan.End()
 	}
 }
 
 // scheduleFuzzing enqueues all discovered fuzz targets into a task queue and
-// spins up cfg.NumWorkers workers. Each worker runs until either:
+// spins up cfg.NumWorkers workers. The targets in the changed packages are
+// fuzzed cfg.ChangedTargetBoost times as long as the others. If the targets do
+// not all fit in the cycle for cfg.MinTargetFuzzTime, only the next ones in
+// rotation are enqueued. Each worker runs until either:
 //   - All tasks are completed.
 //   - A worker returns an error (errgroup will cancel the others).
 //   - The cycle context (ctx) is canceled.
 //
-// Returns an error if any worker fails.
+// Returns an error, once notified, if the fuzz time is invalid or any worker
+// fails.
 func scheduleFuzzing(ctx context.Context, logger *slog.Logger, cfg *Config,
-	pkgTargets map[string][]string, totalTargets int,
-	doneChan chan struct{}) {
+	executor Executor, pkgTargets map[string][]string, totalTargets int,
+	changed map[string]bool, n *notifier, m *fuzzMetrics,
+	status *fuzzStatus, state *fuzzState, stats *cycleStats) error {
 
-	defer close(doneChan)
+	ctx, span := startSpan(ctx, "fuzz targets",
+		attribute.Int("targets", totalTargets),
+		attribute.Int("workers", cfg.NumWorkers))
 
 	logger.Info("Starting fuzzing scheduler", "startTime", time.Now().
 		Format(time.RFC1123))
 
-	// Calculate the fuzzing time for each fuzz target.
-	fuzzSeconds := CalculateFuzzSeconds(cfg.SyncFrequency,
-		cfg.NumWorkers, totalTargets)
+	// Select the targets that fit in the cycle, rotating through them
+	// over the cycles if they do not all fit.
+	pkgTargets, cycleTargets := rotateFuzzTargets(logger, cfg, state,
+		pkgTargets, changed)
+	span.SetAttributes(attribute.Int("fuzzed_targets", cycleTargets))
+
+	// Calculate the fuzzing time for each fuzz target, boosting the
+	// targets in changed packages.
+	changedTargets := 0
+	for pkgPath := range changed {
+		changedTargets += len(pkgTargets[pkgPath])
+	}
+	fuzzSeconds := calculateBoostedFuzzSeconds(cfg.SyncFrequency,
+		cfg.NumWorkers, cycleTargets, changedTargets,
+		cfg.ChangedTargetBoost)
 	if fuzzSeconds <= 0 {
 		logger.Error("invalid fuzz duration", "duration", fuzzSeconds)
+		err := fmt.Errorf("per-target fuzz duration is %v seconds",
+			fuzzSeconds)
+		notifyFatalFailure(n, m, eventCycleFailure,
+			"Invalid fuzz duration", err)
+		endSpan(span, err)
 
-		// Perform workspace cleanup before exiting due to the fuzzing
-		// error.
-		cleanupWorkspace(logger, cfg)
-		os.Exit(1)
+		return err
 	}
-	perTargetTimeout := time.Duration(fuzzSeconds) * time.Second
+	perTargetTimeout := time.Duration(fuzzSeconds * float64(time.Second))
+	changedTimeout := time.Duration(fuzzSeconds * cfg.ChangedTargetBoost *
+		float64(time.Second))
 
 	logger.Info("Per-target fuzz timeout calculated", "duration",
-		perTargetTimeout)
+		perTargetTimeout, "changed_targets", changedTargets,
+		"changed_duration", changedTimeout)
+
+	// Every cfg.RaceEvery-th cycle runs all targets with the race
+	// detector.
+	raceCycle := cfg.RaceEvery > 0 && state.cycle()%cfg.RaceEvery == 0
+	if raceCycle {
+		logger.Info("Running race detector cycle", "cycle",
+			state.cycle())
+	}
 
 	// Build a thread-safe task queue.
-	taskQueue := NewTaskQueue()
-	for pkgPath := range pkgTargets {
-		for _, target := range pkgTargets[pkgPath] {
-			taskQueue.Enqueue(Task{
-				Package: pkgPath,
-				Target:  target,
-			})
-		}
-	}
+	taskQueue := newFuzzTaskQueue(logger, cfg, state, pkgTargets, changed,
+		changedTimeout, raceCycle)
+	status.setQueue(taskQueue)
 
 	// Use an errgroup to cancel all workers if any single worker errors.
+	status.setWorkersRunning(true)
+	defer status.setWorkersRunning(false)
 	g, goCtx := errgroup.WithContext(ctx)
 	for i := 1; i <= cfg.NumWorkers; i++ {
 		workerID := i // capture loop variable
 		g.Go(func() error {
 			return runWorker(workerID, goCtx, taskQueue,
-				perTargetTimeout, logger, cfg)
+				perTargetTimeout, logger, cfg, executor, n, m,
+				status, state, stats)
 		})
 	}
 
 	// Wait for all workers to finish or for the first error/cancellation.
 	if err := g.Wait(); err != nil {
 		logger.Error("Fuzzing process failed", "error", err)
+		notifyFatalFailure(n, m, eventCycleFailure,
+			"Fuzzing process failed", err)
+		endSpan(span, err)
 
-		// Perform workspace cleanup before exiting due to the fuzzing
-		// error.
-		cleanupWorkspace(logger, cfg)
-		os.Exit(1)
+		return fmt.Errorf("fuzzing: %w", err)
 	}
 
 	logger.Info("All fuzz targets processed successfully in this cycle")
+	span.End()
+
+	return nil
+}
+
+// newFuzzTaskQueue returns the queue of the cycle's fuzz targets. Targets
+// never fuzzed before come first, then targets in the changed packages, which
+// are fuzzed for changedTimeout, then targets with an open crash, then the
+// others. Packages and targets are enqueued in sorted order, so that the queue
+// order does not depend on map iteration.
+func newFuzzTaskQueue(logger *slog.Logger, cfg *Config, state *fuzzState,
+	pkgTargets map[string][]string, changed map[string]bool,
+	changedTimeout time.Duration, raceCycle bool) *TaskQueue {
+
+	// Targets with a crash record were fuzzed before, even if they never
+	// completed a run without crashing.
+	crashed := make(map[string]bool)
+	openCrash := make(map[string]bool)
+	records, err := listCrashRecords(cfg.FuzzResultsPath)
+	if err != nil {
+		logger.Error("Failed to list crash records", "error", err)
+	}
+	for _, rec := range records {
+		key := rec.Package + "/" + rec.Target
+		crashed[key] = true
+		openCrash[key] = openCrash[key] || !rec.Fixed
+	}
+
+	taskQueue := NewTaskQueue()
+	for _, pkgPath := range slices.Sorted(maps.Keys(pkgTargets)) {
+		for _, target := range slices.Sorted(
+			slices.Values(pkgTargets[pkgPath])) {
+
+			key := pkgPath + "/" + target
+			priority := PriorityNormal
+			switch {
+			case !crashed[key] &&
+				state.lastGoodCommit(pkgPath, target) == "":
+
+				priority = PriorityNew
+
+			case changed[pkgPath]:
+				priority = PriorityChanged
+
+			case openCrash[key]:
+				priority = PriorityCrash
+			}
+
+			var timeout time.Duration
+			if changed[pkgPath] {
+				timeout = changedTimeout
+			}
+
+			taskQueue.Enqueue(Task{
+				Package: pkgPath,
+				Target:  target,
+				Race: raceCycle || slices.Contains(
+					cfg.RaceTargets, pkgPath+":"+target),
+				Priority: priority,
+				Timeout:  timeout,
+			})
+		}
+	}
+
+	return taskQueue
+}
+
+// measureCycleCoverage measures and reports the coverage of the cycle's
+// corpus, if enabled. It must run before the workspace is cleaned up.
+func measureCycleCoverage(ctx context.Context, logger *slog.Logger,
+	cfg *Config, status *fuzzStatus, pkgTargets map[string][]string,
+	cycle int, commit string) {
+
+	if !cfg.Coverage {
+		return
+	}
+
+	status.enterPhase(phaseCoverage, cfg.HealthStallTimeout)
+	ctx, span := startSpan(ctx, "measure coverage")
+	defer span.End()
+
+	reportCoverage(ctx, logger, cfg, pkgTargets, cycle, commit)
+}
+
+// saveStats logs the statistics of a cycle's fuzz targets and appends them to
+// the stats history.
+func saveStats(logger *slog.Logger, cfg *Config, stats *cycleStats) {
+	stats.logSummary(logger)
+	if err := saveCycleStats(cfg.FuzzResultsPath, stats); err != nil {
+		logger.Error("Failed to save fuzz statistics", "error", err)
+	}
+}
+
+// notifyFatalFailure counts the failed cycle, announces a failure that is
+// about to terminate the process, and waits until all pending notifications
+// have been delivered.
+func notifyFatalFailure(n *notifier, m *fuzzMetrics, evType eventType,
+	msg string, err error) {
+
+	m.cycleFailed()
+	n.notify(&event{
+		Type:    evType,
+		Message: msg,
+		Error:   err.Error(),
+	})
+	n.close()
 }
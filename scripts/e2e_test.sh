@@ -134,8 +134,8 @@ readonly REQUIRED_PATTERNS=(
   'workerID=1'
   'workerID=2'
   'workerID=3'
-  'msg="Known crash detected. Please fix the failing testcase." target=FuzzParseComplex package=parser log_file=parser_FuzzParseComplex_342a5c470d17be27_failure.log'
-  'msg="Known crash detected. Please fix the failing testcase." target=FuzzUnSafeReverseString package=stringutils log_file=stringutils_FuzzUnSafeReverseString_0345b61f9a8eecc9_failure.log'
+  'msg="Known crash detected. Please fix the failing testcase." target=FuzzParseComplex package=parser log_file=parser_FuzzParseComplex_342a5c470d17be27_[a-z]*\.log'
+  'msg="Known crash detected. Please fix the failing testcase." target=FuzzUnSafeReverseString package=stringutils log_file=stringutils_FuzzUnSafeReverseString_0345b61f9a8eecc9_[a-z]*\.log'
   'Successfully zipped and uploaded corpus'
 )
 
@@ -189,21 +189,29 @@ done
 
 # Verify crash reports
 echo "📄 Checking crash reports..."
+# Crash logs are named <pkg>_<target>_<signature>_<class>.log.
 required_crashes=(
-  "$FUZZ_RESULTS_PATH/parser_FuzzParseComplex_342a5c470d17be27_failure.log"
-  "$FUZZ_RESULTS_PATH/stringutils_FuzzUnSafeReverseString_0345b61f9a8eecc9_failure.log"
+  "parser_FuzzParseComplex_342a5c470d17be27"
+  "stringutils_FuzzUnSafeReverseString_0345b61f9a8eecc9"
 )
 
-for crash_file in "${required_crashes[@]}"; do
-  if [[ ! -f "$crash_file" ]]; then
-    echo "❌ ERROR: Missing crash report: $crash_file"
+for crash_prefix in "${required_crashes[@]}"; do
+  crash_files=("$FUZZ_RESULTS_PATH/${crash_prefix}"_*.log)
+  if [[ ${#crash_files[@]} -eq 0 ]]; then
+    echo "❌ ERROR: Missing crash report: $crash_prefix"
     exit 1
   fi
 
+  crash_file="${crash_files[0]}"
   if ! grep -q "go test fuzz v1" "$crash_file"; then
     echo "❌ ERROR: Invalid crash report format in $crash_file"
     exit 1
   fi
+
+  if ! grep -q "^Class: " "$crash_file"; then
+    echo "❌ ERROR: Missing crash class in $crash_file"
+    exit 1
+  fi
 done
 
 # Cleanup resources
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// stateFileName is the name of the file in the fuzz results directory that
+// persists the fuzzing state across cycles and restarts.
+const stateFileName = "state.json"
+
+// bisectJob describes a crash waiting to be bisected.
+type bisectJob struct {
+	// Package is the package of the crashing fuzz target.
+	Package string `json:"package"`
+
+	// Target is the crashing fuzz target.
+	Target string `json:"target"`
+
+	// Signature identifies the crash record.
+	Signature string `json:"signature"`
+
+	// GoodCommit is the last commit at which the target was fuzzed without
+	// crashing.
+	GoodCommit string `json:"good_commit"`
+
+	// BadCommit is the commit at which the crash was found.
+	BadCommit string `json:"bad_commit"`
+}
+
+// fuzzState is the fuzzing state persisted across cycles. It is safe for
+// concurrent use.
+type fuzzState struct {
+	mu sync.Mutex
+
+	// path is the file the state is persisted to.
+	path string
+
+	// Cycles is the number of cycles started so far.
+	Cycles int `json:"cycles"`
+
+	// LastCommit is the commit fuzzed in the most recent cycle.
+	LastCommit string `json:"last_commit"`
+
+	// LastGoodCommits maps "<package>/<target>" to the most recent commit
+	// at which the target completed a fuzz run without crashing.
+	LastGoodCommits map[string]string `json:"last_good_commits"`
+
+	// PendingBisects are the crashes waiting to be bisected.
+	PendingBisects []bisectJob `json:"pending_bisects"`
+
+	// RotationCursor is the "<package>/<target>" key of the fuzz target
+	// the next cycle starts at, when the targets do not all fit in a
+	// cycle and are fuzzed in rotation.
+	RotationCursor string `json:"rotation_cursor,omitempty"`
+}
+
+// loadFuzzState reads the fuzzing state from the results directory. A missing
+// state file yields an empty state.
+func loadFuzzState(resultsDir string) (*fuzzState, error) {
+	state := &fuzzState{
+		path:            filepath.Join(resultsDir, stateFileName),
+		LastGoodCommits: make(map[string]string),
+	}
+
+	data, err := os.ReadFile(state.path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("decoding fuzz state %q: %w", state.path,
+			err)
+	}
+	if state.LastGoodCommits == nil {
+		state.LastGoodCommits = make(map[string]string)
+	}
+
+	return state, nil
+}
+
+// save persists the fuzzing state.
+func (s *fuzzState) save() error {
+	s.mu.Lock()
+	data, err := json.MarshalIndent(s, "", "  ")
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("encoding fuzz state: %w", err)
+	}
+
+	if err := EnsureDirExists(filepath.Dir(s.path)); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so that a crash of the daemon never
+	// leaves a truncated state behind.
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("writing fuzz state: %w", err)
+	}
+
+	return os.Rename(tmpPath, s.path)
+}
+
+// startCycle counts a new cycle and returns its number, starting at 1.
+func (s *fuzzState) startCycle() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Cycles++
+	return s.Cycles
+}
+
+// cycle returns the number of the current cycle.
+func (s *fuzzState) cycle() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.Cycles
+}
+
+// lastCommit returns the commit fuzzed in the most recent cycle.
+func (s *fuzzState) lastCommit() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.LastCommit
+}
+
+// setLastCommit records the commit fuzzed in the current cycle.
+func (s *fuzzState) setLastCommit(commit string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.LastCommit = commit
+}
+
+// lastGoodCommit returns the most recent commit at which the target was
+// fuzzed without crashing, or an empty string if there is none.
+func (s *fuzzState) lastGoodCommit(pkg, target string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.LastGoodCommits[pkg+"/"+target]
+}
+
+// markGood records that the target was fuzzed without crashing at commit.
+func (s *fuzzState) markGood(pkg, target, commit string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.LastGoodCommits[pkg+"/"+target] = commit
+}
+
+// addBisect queues a crash for bisection, unless it is already queued.
+func (s *fuzzState) addBisect(job bisectJob) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, pending := range s.PendingBisects {
+		if pending.Signature == job.Signature {
+			return
+		}
+	}
+	s.PendingBisects = append(s.PendingBisects, job)
+}
+
+// takeBisects removes and returns all queued bisections.
+func (s *fuzzState) takeBisects() []bisectJob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs := s.PendingBisects
+	s.PendingBisects = nil
+
+	return jobs
+}
+
+// rotationCursor returns the key of the fuzz target the rotation of the next
+// cycle starts at.
+func (s *fuzzState) rotationCursor() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.RotationCursor
+}
+
+// setRotationCursor records the key of the fuzz target the rotation of the
+// next cycle starts at.
+func (s *fuzzState) setRotationCursor(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.RotationCursor = key
+}
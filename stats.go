@@ -0,0 +1,327 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// statsFileName is the name of the file in the fuzz results directory that
+// stores the per-target statistics of the most recent cycles.
+const statsFileName = "stats.json"
+
+// maxStatsCycles is the number of cycles whose statistics are kept.
+const maxStatsCycles = 100
+
+var (
+	// fuzzProgressRegex matches the progress lines printed while fuzzing.
+	// The corpus counts are missing if coverage is not instrumented.
+	//
+	// It matches lines like:
+	//   "fuzz: elapsed: 3s, execs: 12345 (4115/sec), new interesting: 3
+	//   (total: 45)"
+	fuzzProgressRegex = regexp.MustCompile(
+		`^fuzz: elapsed: (?P<elapsed>[0-9hms]+), ` +
+			`execs: (?P<execs>[0-9]+) \((?P<rate>[0-9]+)/sec\)` +
+			`(?:, new interesting: (?P<new>[0-9]+) ` +
+			`\(total: (?P<total>[0-9]+)\))?`,
+	)
+
+	// fuzzBaselineRegex matches the progress lines printed while the
+	// baseline coverage of the corpus, or only the seed corpus if coverage
+	// is not instrumented, is gathered.
+	//
+	// It matches lines like:
+	//   "fuzz: elapsed: 0s, gathering baseline coverage: 3/45 completed"
+	fuzzBaselineRegex = regexp.MustCompile(
+		`^fuzz: elapsed: (?P<elapsed>[0-9hms]+), ` +
+			`(?:gathering baseline coverage|testing seed corpus)` +
+			`: ` +
+			`(?P<done>[0-9]+)/(?P<total>[0-9]+) completed`,
+	)
+)
+
+// fuzzProgress is a progress report of a running fuzz target.
+type fuzzProgress struct {
+	// Elapsed is how long the target has been fuzzed.
+	Elapsed time.Duration `json:"elapsed_ns"`
+
+	// Execs is the number of inputs executed so far.
+	Execs int64 `json:"execs"`
+
+	// ExecsPerSec is the execution rate since the previous report.
+	ExecsPerSec int64 `json:"execs_per_sec"`
+
+	// NewInteresting is the number of inputs added to the corpus by this
+	// run.
+	NewInteresting int `json:"new_interesting"`
+
+	// TotalCorpus is the size of the corpus, including the seed corpus.
+	TotalCorpus int `json:"total_corpus"`
+
+	// BaselineCompleted and BaselineTotal are the number of corpus
+	// entries whose coverage was gathered before fuzzing started, out of
+	// all entries.
+	BaselineCompleted int `json:"baseline_completed"`
+	BaselineTotal     int `json:"baseline_total"`
+}
+
+// parseFuzzProgress parses a progress line of the fuzzing output into prev,
+// the previous progress of the run. It reports whether the line was a progress
+// line.
+func parseFuzzProgress(line string, prev *fuzzProgress) bool {
+	if m := fuzzProgressRegex.FindStringSubmatch(line); m != nil {
+		elapsed, err := time.ParseDuration(m[1])
+		if err != nil {
+			return false
+		}
+		prev.Elapsed = elapsed
+		prev.Execs, _ = strconv.ParseInt(m[2], 10, 64)
+		prev.ExecsPerSec, _ = strconv.ParseInt(m[3], 10, 64)
+		if m[5] != "" {
+			prev.NewInteresting, _ = strconv.Atoi(m[4])
+			prev.TotalCorpus, _ = strconv.Atoi(m[5])
+		}
+
+		return true
+	}
+
+	if m := fuzzBaselineRegex.FindStringSubmatch(line); m != nil {
+		elapsed, err := time.ParseDuration(m[1])
+		if err != nil {
+			return false
+		}
+		prev.Elapsed = elapsed
+		prev.BaselineCompleted, _ = strconv.Atoi(m[2])
+		prev.BaselineTotal, _ = strconv.Atoi(m[3])
+
+		// Until fuzzing starts, the corpus is the baseline.
+		prev.TotalCorpus = prev.BaselineTotal
+
+		return true
+	}
+
+	return false
+}
+
+// targetStats are the statistics of a fuzz target over a cycle.
+type targetStats struct {
+	// Package is the package of the fuzz target.
+	Package string `json:"package"`
+
+	// Target is the fuzz target.
+	Target string `json:"target"`
+
+	// Runs is the number of completed runs.
+	Runs int `json:"runs"`
+
+	// Crashes is the number of runs that found a crash.
+	Crashes int `json:"crashes"`
+
+	// Failures is the number of runs that failed or whose target was
+	// broken.
+	Failures int `json:"failures"`
+
+	// FuzzTime is the total time the target was fuzzed.
+	FuzzTime time.Duration `json:"fuzz_time_ns"`
+
+	// Execs is the total number of inputs executed.
+	Execs int64 `json:"execs"`
+
+	// NewInteresting is the total number of inputs added to the corpus.
+	NewInteresting int `json:"new_interesting"`
+
+	// CorpusSize is the size of the corpus at the end of the latest run.
+	CorpusSize int `json:"corpus_size"`
+
+	// BaselineCoverage is the number of corpus entries whose baseline
+	// coverage was gathered in the latest run.
+	BaselineCoverage int `json:"baseline_coverage"`
+
+	// Current is the progress of the running run, if any.
+	Current *fuzzProgress `json:"current,omitempty"`
+}
+
+// ExecsPerSec returns the average execution rate of the target.
+func (s *targetStats) ExecsPerSec() float64 {
+	if s.FuzzTime <= 0 {
+		return 0
+	}
+
+	return float64(s.Execs) / s.FuzzTime.Seconds()
+}
+
+// cycleStats are the per-target statistics of a cycle. It is safe for
+// concurrent use.
+type cycleStats struct {
+	mu sync.Mutex
+
+	// Cycle is the number of the cycle.
+	Cycle int `json:"cycle"`
+
+	// Commit is the commit fuzzed in the cycle.
+	Commit string `json:"commit"`
+
+	// Start is when the cycle started.
+	Start time.Time `json:"start"`
+
+	// Targets maps "<package>/<target>" to the target's statistics.
+	Targets map[string]*targetStats `json:"targets"`
+}
+
+// newCycleStats returns empty statistics for the cycle fuzzing commit.
+func newCycleStats(cycle int, commit string) *cycleStats {
+	return &cycleStats{
+		Cycle:   cycle,
+		Commit:  commit,
+		Start:   time.Now(),
+		Targets: make(map[string]*targetStats),
+	}
+}
+
+// target returns the statistics of the task's target, creating them if
+// needed. The caller must hold the lock.
+func (s *cycleStats) target(task Task) *targetStats {
+	key := task.Package + "/" + task.Target
+	ts, ok := s.Targets[key]
+	if !ok {
+		ts = &targetStats{Package: task.Package, Target: task.Target}
+		s.Targets[key] = ts
+	}
+
+	return ts
+}
+
+// update records the latest progress of the task's running run.
+func (s *cycleStats) update(task Task, progress fuzzProgress) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.target(task).Current = &progress
+}
+
+// finishRun adds the task's completed run, ending with status, to the
+// statistics of its target.
+func (s *cycleStats) finishRun(task Task, status targetStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ts := s.target(task)
+	ts.Runs++
+	switch status {
+	case targetStatusCrashed:
+		ts.Crashes++
+
+	case targetStatusBroken, targetStatusFailed:
+		ts.Failures++
+	}
+
+	if p := ts.Current; p != nil {
+		ts.FuzzTime += p.Elapsed
+		ts.Execs += p.Execs
+		ts.NewInteresting += p.NewInteresting
+		ts.CorpusSize = p.TotalCorpus
+		ts.BaselineCoverage = p.BaselineCompleted
+	}
+	ts.Current = nil
+}
+
+// snapshot returns a copy of the statistics of all targets, sorted by package
+// and target.
+func (s *cycleStats) snapshot() []targetStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	targets := make([]targetStats, 0, len(s.Targets))
+	for _, ts := range s.Targets {
+		cp := *ts
+		if ts.Current != nil {
+			current := *ts.Current
+			cp.Current = &current
+		}
+		targets = append(targets, cp)
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		if targets[i].Package != targets[j].Package {
+			return targets[i].Package < targets[j].Package
+		}
+		return targets[i].Target < targets[j].Target
+	})
+
+	return targets
+}
+
+// logSummary logs the statistics of every target of the cycle.
+func (s *cycleStats) logSummary(logger *slog.Logger) {
+	for _, ts := range s.snapshot() {
+		logger.Info("Fuzz target statistics", "cycle", s.Cycle,
+			"package", ts.Package, "target", ts.Target, "runs",
+			ts.Runs, "crashes", ts.Crashes, "failures", ts.Failures,
+			"fuzz_time", ts.FuzzTime, "execs", ts.Execs,
+			"execs_per_sec", int64(ts.ExecsPerSec()),
+			"new_interesting", ts.NewInteresting, "corpus_size",
+			ts.CorpusSize)
+	}
+}
+
+// loadStatsHistory reads the statistics of the most recent cycles from the
+// results directory, oldest first. A missing stats file yields no cycles.
+func loadStatsHistory(resultsDir string) ([]*cycleStats, error) {
+	path := filepath.Join(resultsDir, statsFileName)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var history []*cycleStats
+	if err := json.Unmarshal(data, &history); err != nil {
+		return nil, fmt.Errorf("decoding stats %q: %w", path, err)
+	}
+
+	return history, nil
+}
+
+// saveCycleStats appends the statistics of a cycle to the history stored in
+// the results directory, keeping the last maxStatsCycles cycles.
+func saveCycleStats(resultsDir string, stats *cycleStats) error {
+	history, err := loadStatsHistory(resultsDir)
+	if err != nil {
+		return err
+	}
+
+	history = append(history, stats)
+	if len(history) > maxStatsCycles {
+		history = history[len(history)-maxStatsCycles:]
+	}
+
+	stats.mu.Lock()
+	data, err := json.MarshalIndent(history, "", "  ")
+	stats.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("encoding stats: %w", err)
+	}
+
+	if err := EnsureDirExists(resultsDir); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so that a crash of the daemon never
+	// leaves a truncated history behind.
+	path := filepath.Join(resultsDir, statsFileName)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("writing stats: %w", err)
+	}
+
+	return os.Rename(tmpPath, path)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseFuzzProgress verifies that the progress lines of the fuzzing output
+// are parsed, carrying over the fields a line doesn't report.
+func TestParseFuzzProgress(t *testing.T) {
+	tests := []struct {
+		name     string
+		lines    []string
+		expected fuzzProgress
+		ok       bool
+	}{
+		{
+			name: "baseline coverage",
+			lines: []string{
+				"fuzz: elapsed: 0s, gathering baseline " +
+					"coverage: 2/45 completed",
+			},
+			expected: fuzzProgress{
+				BaselineCompleted: 2,
+				BaselineTotal:     45,
+				TotalCorpus:       45,
+			},
+			ok: true,
+		},
+		{
+			name: "fuzzing",
+			lines: []string{
+				"fuzz: elapsed: 0s, gathering baseline " +
+					"coverage: 45/45 completed, now " +
+					"fuzzing with 8 workers",
+				"fuzz: elapsed: 1m3s, execs: 12345 " +
+					"(4115/sec), new interesting: 3 " +
+					"(total: 48)",
+			},
+			expected: fuzzProgress{
+				Elapsed:           63 * time.Second,
+				Execs:             12345,
+				ExecsPerSec:       4115,
+				NewInteresting:    3,
+				TotalCorpus:       48,
+				BaselineCompleted: 45,
+				BaselineTotal:     45,
+			},
+			ok: true,
+		},
+		{
+			name: "fuzzing without coverage",
+			lines: []string{
+				"fuzz: elapsed: 0s, testing seed corpus: " +
+					"2/2 completed, now fuzzing with 8 " +
+					"workers",
+				"fuzz: elapsed: 3s, execs: 900 (300/sec)",
+			},
+			expected: fuzzProgress{
+				Elapsed:           3 * time.Second,
+				Execs:             900,
+				ExecsPerSec:       300,
+				TotalCorpus:       2,
+				BaselineCompleted: 2,
+				BaselineTotal:     2,
+			},
+			ok: true,
+		},
+		{
+			name:  "other output",
+			lines: []string{"fuzz: elapsed: 1s, minimizing"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				progress fuzzProgress
+				ok       bool
+			)
+			for _, line := range tt.lines {
+				ok = parseFuzzProgress(line, &progress)
+			}
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.expected, progress)
+		})
+	}
+}
+
+// TestSaveCycleStats verifies that the statistics of every cycle are appended
+// to the stored history, keeping only the most recent cycles.
+func TestSaveCycleStats(t *testing.T) {
+	dir := t.TempDir()
+	task := Task{Package: "parser", Target: "FuzzParse"}
+
+	for cycle := 1; cycle <= maxStatsCycles+2; cycle++ {
+		stats := newCycleStats(cycle, "abc123")
+		stats.update(task, fuzzProgress{
+			Elapsed: time.Minute,
+			Execs:   int64(cycle) * 1000,
+		})
+		stats.finishRun(task, targetStatusOK)
+		require.NoError(t, saveCycleStats(dir, stats))
+	}
+
+	history, err := loadStatsHistory(dir)
+	require.NoError(t, err)
+	require.Len(t, history, maxStatsCycles)
+	assert.Equal(t, 3, history[0].Cycle)
+
+	latest := history[len(history)-1]
+	assert.Equal(t, maxStatsCycles+2, latest.Cycle)
+	ts := latest.Targets["parser/FuzzParse"]
+	require.NotNil(t, ts)
+	assert.Equal(t, int64(maxStatsCycles+2)*1000, ts.Execs)
+	assert.Equal(t, time.Minute, ts.FuzzTime)
+	assert.Nil(t, ts.Current)
+}
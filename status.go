@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultRecentCrashes is the number of most recently seen crashes listed by
+// default.
+const defaultRecentCrashes = 20
+
+// dashboardTemplates holds the templates of the web dashboard.
+//
+//go:embed templates/dashboard.html
+var dashboardTemplates embed.FS
+
+// dashboardTemplate renders the web dashboard.
+var dashboardTemplate = template.Must(template.ParseFS(dashboardTemplates,
+	"templates/dashboard.html"))
+
+// workerStatus describes a worker running a fuzz target.
+type workerStatus struct {
+	// ID identifies the worker.
+	ID int `json:"id"`
+
+	// Task is the fuzz target the worker runs.
+	Task Task `json:"task"`
+
+	// Started is when the worker started running the target.
+	Started time.Time `json:"started"`
+
+	// Timeout is how long the target is fuzzed.
+	Timeout time.Duration `json:"timeout"`
+}
+
+// statusSummary is an overview of what the fuzzer is doing.
+type statusSummary struct {
+	// Cycle is the number of the current cycle.
+	Cycle int `json:"cycle"`
+
+	// Commit is the commit fuzzed in the current cycle.
+	Commit string `json:"commit"`
+
+	// CycleStart is when the current cycle started.
+	CycleStart time.Time `json:"cycle_start"`
+
+	// Phase is the step of the cycle the scheduler loop is in.
+	Phase string `json:"phase"`
+
+	// QueuedTasks is the number of fuzz targets waiting for a worker.
+	QueuedTasks int `json:"queued_tasks"`
+
+	// Workers are the workers running a fuzz target.
+	Workers []workerStatus `json:"workers"`
+}
+
+// fuzzStatus tracks what the fuzzer is doing, and serves it as JSON and as a
+// read-only web dashboard. It is safe for concurrent use.
+type fuzzStatus struct {
+	mu sync.Mutex
+
+	// resultsDir is the fuzz results directory holding the crash
+	// records.
+	resultsDir string
+
+	// watchdogMargin is how long a fuzz target may overrun its timeout
+	// before it is considered hung.
+	watchdogMargin time.Duration
+
+	// cycle, commit and cycleStart describe the current cycle.
+	cycle      int
+	commit     string
+	cycleStart time.Time
+
+	// stats are the per-target statistics of the current cycle.
+	stats *cycleStats
+
+	// queue holds the fuzz targets of the current cycle waiting for a
+	// worker.
+	queue *TaskQueue
+
+	// workers maps the IDs of the workers running a fuzz target to their
+	// status.
+	workers map[int]workerStatus
+
+	// phase is the step of the cycle the scheduler loop is in, and
+	// phaseDeadline the time by which it is expected to complete.
+	phase         string
+	phaseDeadline time.Time
+
+	// repoSynced reports whether the latest sync of the project
+	// repository succeeded.
+	repoSynced bool
+
+	// storageErr is the error of the latest corpus transfer, if any.
+	storageErr error
+
+	// workersRunning reports whether the fuzzing workers of the current
+	// cycle are running.
+	workersRunning bool
+}
+
+// newFuzzStatus returns the status of a fuzzer configured by cfg.
+func newFuzzStatus(cfg *Config) *fuzzStatus {
+	return &fuzzStatus{
+		resultsDir:     cfg.FuzzResultsPath,
+		watchdogMargin: cfg.WatchdogMargin,
+		workers:        make(map[int]workerStatus),
+		phase:          phaseStarting,
+	}
+}
+
+// cycleStarted records the start of a cycle fuzzing commit, whose per-target
+// statistics are stats.
+func (s *fuzzStatus) cycleStarted(cycle int, commit string,
+	stats *cycleStats) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cycle = cycle
+	s.commit = commit
+	s.cycleStart = time.Now()
+	s.stats = stats
+	s.queue = nil
+}
+
+// setQueue records the task queue of the current cycle.
+func (s *fuzzStatus) setQueue(queue *TaskQueue) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.queue = queue
+}
+
+// workerStarted records that a worker started fuzzing the task for timeout.
+func (s *fuzzStatus) workerStarted(workerID int, task Task,
+	timeout time.Duration) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.workers[workerID] = workerStatus{
+		ID:      workerID,
+		Task:    task,
+		Started: time.Now(),
+		Timeout: timeout,
+	}
+}
+
+// workerFinished records that a worker finished running its task.
+func (s *fuzzStatus) workerFinished(workerID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.workers, workerID)
+}
+
+// summary returns an overview of what the fuzzer is doing.
+func (s *fuzzStatus) summary() statusSummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	summary := statusSummary{
+		Cycle:      s.cycle,
+		Commit:     s.commit,
+		CycleStart: s.cycleStart,
+		Phase:      s.phase,
+		Workers:    make([]workerStatus, 0, len(s.workers)),
+	}
+	if s.queue != nil {
+		summary.QueuedTasks = s.queue.Len()
+	}
+	for _, worker := range s.workers {
+		summary.Workers = append(summary.Workers, worker)
+	}
+	sort.Slice(summary.Workers, func(i, j int) bool {
+		return summary.Workers[i].ID < summary.Workers[j].ID
+	})
+
+	return summary
+}
+
+// queuedTasks returns the fuzz targets waiting for a worker, in queue order.
+func (s *fuzzStatus) queuedTasks() []Task {
+	s.mu.Lock()
+	queue := s.queue
+	s.mu.Unlock()
+
+	if queue == nil {
+		return []Task{}
+	}
+
+	return queue.Tasks()
+}
+
+// targetStats returns the per-target statistics of the current cycle.
+func (s *fuzzStatus) targetStats() []targetStats {
+	s.mu.Lock()
+	stats := s.stats
+	s.mu.Unlock()
+
+	if stats == nil {
+		return []targetStats{}
+	}
+
+	return stats.snapshot()
+}
+
+// recentCrashes returns up to limit crash records, most recently seen first.
+func (s *fuzzStatus) recentCrashes(limit int) ([]*crashRecord, error) {
+	records, err := listCrashRecords(s.resultsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].LastSeen.After(records[j].LastSeen)
+	})
+	if len(records) > limit {
+		records = records[:limit]
+	}
+	if records == nil {
+		records = []*crashRecord{}
+	}
+
+	return records, nil
+}
+
+// register adds the status and health endpoints and the dashboard to mux.
+func (s *fuzzStatus) register(mux *http.ServeMux) {
+	s.registerHealth(mux)
+
+	mux.HandleFunc("GET /api/status", func(w http.ResponseWriter,
+		_ *http.Request) {
+
+		writeJSON(w, s.summary())
+	})
+	mux.HandleFunc("GET /api/queue", func(w http.ResponseWriter,
+		_ *http.Request) {
+
+		writeJSON(w, s.queuedTasks())
+	})
+	mux.HandleFunc("GET /api/workers", func(w http.ResponseWriter,
+		_ *http.Request) {
+
+		writeJSON(w, s.summary().Workers)
+	})
+	mux.HandleFunc("GET /api/stats", func(w http.ResponseWriter,
+		_ *http.Request) {
+
+		writeJSON(w, s.targetStats())
+	})
+	mux.HandleFunc("GET /api/crashes", s.serveCrashes)
+	mux.HandleFunc("GET /{$}", s.serveDashboard)
+}
+
+// serveCrashes serves the most recently seen crashes. The number of crashes
+// is set by the "limit" query parameter.
+func (s *fuzzStatus) serveCrashes(w http.ResponseWriter, r *http.Request) {
+	limit := defaultRecentCrashes
+	if param := r.URL.Query().Get("limit"); param != "" {
+		var err error
+		limit, err = strconv.Atoi(param)
+		if err != nil || limit <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	crashes, err := s.recentCrashes(limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, crashes)
+}
+
+// dashboardData is the data rendered by the dashboard template.
+type dashboardData struct {
+	Summary statusSummary
+	Queue   []Task
+	Stats   []targetStats
+	Crashes []*crashRecord
+	Now     time.Time
+}
+
+// serveDashboard renders the web dashboard.
+func (s *fuzzStatus) serveDashboard(w http.ResponseWriter, _ *http.Request) {
+	crashes, err := s.recentCrashes(defaultRecentCrashes)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Render into a buffer first, so that a failure can still be
+	// reported with an error status.
+	var buf bytes.Buffer
+	err = dashboardTemplate.Execute(&buf, dashboardData{
+		Summary: s.summary(),
+		Queue:   s.queuedTasks(),
+		Stats:   s.targetStats(),
+		Crashes: crashes,
+		Now:     time.Now(),
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = buf.WriteTo(w)
+}
+
+// writeJSON writes v as a JSON response.
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
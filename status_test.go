@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newStatusServer starts a server for a status reporting a running cycle with
+// a busy worker, a queued task, target statistics and two crashes.
+func newStatusServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	resultsDir := t.TempDir()
+	now := time.Now()
+	for i, rec := range []*crashRecord{
+		{
+			Package:   "parser",
+			Target:    "FuzzParse",
+			Signature: "older",
+			Class:     crashClassPanic,
+		},
+		{
+			Package:   "lexer",
+			Target:    "FuzzLex",
+			Signature: "newer",
+			Class:     crashClassTimeout,
+		},
+	} {
+		rec.LastSeen = now.Add(time.Duration(i) * time.Minute)
+		require.NoError(t, saveCrashRecord(resultsDir, rec))
+	}
+
+	status := newFuzzStatus(&Config{FuzzResultsPath: resultsDir})
+	stats := newCycleStats(7, "abc123")
+	status.cycleStarted(7, "abc123", stats)
+
+	queue := NewTaskQueue()
+	queue.Enqueue(Task{Package: "parser", Target: "FuzzParse"})
+	queue.Enqueue(Task{Package: "parser", Target: "<FuzzEval>"})
+	status.setQueue(queue)
+
+	running, _ := queue.Dequeue()
+	status.workerStarted(2, running, time.Minute)
+	stats.update(running, fuzzProgress{Elapsed: time.Second, Execs: 10})
+
+	server := httptest.NewServer(newHTTPHandler(newFuzzMetrics(2),
+		status))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// getStatus fetches path from the server, decoding the JSON response into v.
+func getStatus(t *testing.T, server *httptest.Server, path string, v any) {
+	t.Helper()
+
+	resp, err := http.Get(server.URL + path)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(v))
+}
+
+// TestStatusAPI verifies that the JSON endpoints report the current cycle, the
+// task queue, the running workers, the target statistics and recent crashes.
+func TestStatusAPI(t *testing.T) {
+	server := newStatusServer(t)
+
+	var summary statusSummary
+	getStatus(t, server, "/api/status", &summary)
+	assert.Equal(t, 7, summary.Cycle)
+	assert.Equal(t, "abc123", summary.Commit)
+	assert.Equal(t, 1, summary.QueuedTasks)
+	require.Len(t, summary.Workers, 1)
+	assert.Equal(t, 2, summary.Workers[0].ID)
+	assert.Equal(t, "FuzzParse", summary.Workers[0].Task.Target)
+
+	var queue []Task
+	getStatus(t, server, "/api/queue", &queue)
+	assert.Equal(t, []Task{{Package: "parser", Target: "<FuzzEval>"}},
+		queue)
+
+	var workers []workerStatus
+	getStatus(t, server, "/api/workers", &workers)
+	assert.Len(t, workers, 1)
+
+	var stats []targetStats
+	getStatus(t, server, "/api/stats", &stats)
+	require.Len(t, stats, 1)
+	require.NotNil(t, stats[0].Current)
+	assert.Equal(t, int64(10), stats[0].Current.Execs)
+
+	var crashes []crashRecord
+	getStatus(t, server, "/api/crashes", &crashes)
+	require.Len(t, crashes, 2)
+	assert.Equal(t, "newer", crashes[0].Signature)
+	assert.Equal(t, "older", crashes[1].Signature)
+
+	getStatus(t, server, "/api/crashes?limit=1", &crashes)
+	require.Len(t, crashes, 1)
+	assert.Equal(t, "newer", crashes[0].Signature)
+
+	resp, err := http.Get(server.URL + "/api/crashes?limit=none")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+// TestStatusDashboard verifies that the dashboard renders the status, escaping
+// the values it shows.
+func TestStatusDashboard(t *testing.T) {
+	server := newStatusServer(t)
+
+	resp, err := http.Get(server.URL + "/")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/html; charset=utf-8",
+		resp.Header.Get("Content-Type"))
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	page := string(body)
+
+	assert.Contains(t, page, "Cycle <strong>7</strong>")
+	assert.Contains(t, page, "<code>abc123</code>")
+	assert.Contains(t, page, "&lt;FuzzEval&gt;")
+	assert.NotContains(t, page, "<FuzzEval>")
+	assert.Contains(t, page, "<code>newer</code>")
+	assert.Contains(t, page, "<code>older</code>")
+
+	// Only the dashboard is served at the root.
+	resp, err = http.Get(server.URL + "/missing")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
@@ -10,11 +10,13 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // createS3Client initializes and returns an S3 client using the AWS SDK v2.
@@ -39,8 +41,15 @@ func createS3Client(ctx context.Context) (*s3.Client, error) {
 // If the object does not exist (NoSuchKey), it logs the event and returns true
 // with a nil error, indicating that the process should continue with an empty
 // corpus. For all other errors, it returns false and the corresponding error.
+// The size and latency of a successful download are recorded in m.
 func downloadObject(ctx context.Context, s3Client *s3.Client, bucket, key,
-	destPath string, logger *slog.Logger) (bool, error) {
+	destPath string, logger *slog.Logger,
+	m *fuzzMetrics) (_ bool, err error) {
+
+	ctx, span := startSpan(ctx, "s3 download",
+		attribute.String("bucket", bucket),
+		attribute.String("key", key))
+	defer func() { endSpan(span, err) }()
 
 	// Ensure the corpus directory exists
 	if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
@@ -48,6 +57,7 @@ func downloadObject(ctx context.Context, s3Client *s3.Client, bucket, key,
 	}
 
 	// Attempt to download the corpus from S3
+	start := time.Now()
 	result, err := s3Client.GetObject(ctx, &s3.GetObjectInput{
 		Bucket: &bucket,
 		Key:    &key,
@@ -86,6 +96,8 @@ func downloadObject(ctx context.Context, s3Client *s3.Client, bucket, key,
 	if err != nil {
 		return false, fmt.Errorf("writing to local file: %w", err)
 	}
+	m.observeS3Transfer("download", n, time.Since(start))
+	span.SetAttributes(attribute.Int64("bytes", n))
 
 	logger.Info("Downloaded object",
 		"bytes", n,
@@ -102,11 +114,19 @@ func downloadObject(ctx context.Context, s3Client *s3.Client, bucket, key,
 // correct content length.
 //
 // If the upload fails, it returns a wrapped error describing the failure.
-// On success, it logs the upload details using the provided logger.
+// On success, it logs the upload details using the provided logger and records
+// the size and latency of the upload in m.
 func uploadObject(ctx context.Context, s3Client *s3.Client, bucket, key string,
-	buf *bytes.Buffer, logger *slog.Logger) error {
+	buf *bytes.Buffer, logger *slog.Logger, m *fuzzMetrics) (err error) {
+
+	ctx, span := startSpan(ctx, "s3 upload",
+		attribute.String("bucket", bucket),
+		attribute.String("key", key),
+		attribute.Int("bytes", buf.Len()))
+	defer func() { endSpan(span, err) }()
 
-	_, err := s3Client.PutObject(ctx, &s3.PutObjectInput{
+	start := time.Now()
+	_, err = s3Client.PutObject(ctx, &s3.PutObjectInput{
 		Bucket:        &bucket,
 		Key:           &key,
 		Body:          bytes.NewReader(buf.Bytes()),
@@ -116,6 +136,7 @@ func uploadObject(ctx context.Context, s3Client *s3.Client, bucket, key string,
 	if err != nil {
 		return fmt.Errorf("uploading s3://%s/%s: %w", bucket, key, err)
 	}
+	m.observeS3Transfer("upload", int64(buf.Len()), time.Since(start))
 	logger.Info("Uploaded object to S3",
 		"s3Bucket", bucket,
 		"key", key,
@@ -126,7 +147,7 @@ func uploadObject(ctx context.Context, s3Client *s3.Client, bucket, key string,
 // unzip extracts the contents of the zip archive specified by srcZip
 // into the destination directory destDir.
 //
-// It preserves file permissions and directory structure.
+// It preserves file permissions, modification times and directory structure.
 // If the zip archive is empty, it logs a message and returns without error.
 // Any error during extraction is wrapped and returned.
 func unzip(srcZip, destDir string, logger *slog.Logger) error {
@@ -192,6 +213,16 @@ func unzip(srcZip, destDir string, logger *slog.Logger) error {
 			return fmt.Errorf("copying to file %q: %w", fullPath,
 				err)
 		}
+
+		// Keep the modification time, so that it tells when the input
+		// was added to the corpus rather than when it was extracted.
+		if !f.Modified.IsZero() {
+			err := os.Chtimes(fullPath, f.Modified, f.Modified)
+			if err != nil {
+				return fmt.Errorf("setting time of %q: %w",
+					fullPath, err)
+			}
+		}
 	}
 
 	logger.Info("Successfully extracted zip archive.", "zipFile", srcZip,
@@ -276,9 +307,9 @@ func zipDir(srcDir string, logger *slog.Logger) (*bytes.Buffer, error) {
 // zipUploadCorpus compresses the contents of unzipDir into a ZIP archive
 // and uploads it to the specified S3 bucket and object key.
 //
-// It logs any errors encountered during zipping or uploading.
+// It logs and returns any errors encountered during zipping or uploading.
 func zipUploadCorpus(ctx context.Context, s3Client *s3.Client, bucketName,
-	objectKey, unzipDir string, logger *slog.Logger) {
+	objectKey, unzipDir string, logger *slog.Logger, m *fuzzMetrics) error {
 
 	logger.Info("Starting ZIP and upload process",
 		"source_dir", unzipDir,
@@ -286,20 +317,27 @@ func zipUploadCorpus(ctx context.Context, s3Client *s3.Client, bucketName,
 		"object_key", objectKey,
 	)
 
+	_, span := startSpan(ctx, "zip corpus")
 	buf, err := zipDir(unzipDir, logger)
+	if err == nil {
+		span.SetAttributes(attribute.Int("bytes", buf.Len()))
+	}
+	endSpan(span, err)
 	if err != nil {
 		logger.Error("Zipping failed", "error", err)
-		return
+		return err
 	}
 
 	if err := uploadObject(ctx, s3Client, bucketName, objectKey, buf,
-		logger); err != nil {
+		logger, m); err != nil {
 		logger.Error("Upload failed", "error", err)
-		return
+		return err
 	}
 
 	logger.Info("Successfully zipped and uploaded corpus",
 		"bucket", bucketName,
 		"object_key", objectKey,
 	)
+
+	return nil
 }
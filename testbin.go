@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// testBuildError is returned when a package's test binary fails to compile.
+type testBuildError struct {
+	// pkg is the package that failed to compile.
+	pkg string
+
+	// output is the compiler output.
+	output string
+
+	// err is the error of the "go test -c" command.
+	err error
+}
+
+// Error implements the error interface.
+func (e *testBuildError) Error() string {
+	return fmt.Sprintf("building test binary for %q failed: %v "+
+		"(output: %q)", e.pkg, e.err, e.output)
+}
+
+// Unwrap returns the error of the "go test -c" command.
+func (e *testBuildError) Unwrap() error {
+	return e.err
+}
+
+// testBinary is the compiled test binary of a package.
+type testBinary struct {
+	// mu serializes builds of the binary, so that concurrent workers
+	// wait for a single build instead of compiling it themselves.
+	mu sync.Mutex
+
+	// path is the location of the binary once it has been built. It is
+	// empty if the package has no test files.
+	path string
+
+	// err is the result of a build that completed, successfully or not.
+	err error
+
+	// built reports whether a build completed.
+	built bool
+}
+
+// testBinaries compiles the test binary of every fuzzed package at most once
+// per cycle and shares it across workers, so that a package with several fuzz
+// targets is not recompiled for each of them. It is safe for concurrent use.
+type testBinaries struct {
+	// cfg holds the project directory the packages are located in and
+	// the environment of the go command.
+	cfg *Config
+
+	// dir is the directory the binaries are written to.
+	dir string
+
+	mu       sync.Mutex
+	binaries map[string]*testBinary
+}
+
+// newTestBinaries returns an empty set of test binaries for the current
+// project checkout.
+func newTestBinaries(cfg *Config) *testBinaries {
+	return &testBinaries{
+		cfg:      cfg,
+		dir:      cfg.BinDir,
+		binaries: make(map[string]*testBinary),
+	}
+}
+
+// get returns the path of the package's test binary, building it first if
+// needed, or an empty path if the package has no test files. If race is set,
+// the binary is built with the race detector. A compilation failure is
+// returned as a *testBuildError and is remembered for the rest of the cycle; a
+// build interrupted by ctx is retried on the next call.
+func (tb *testBinaries) get(ctx context.Context, pkg string,
+	race bool) (string, error) {
+
+	name := strings.ReplaceAll(pkg, "/", "_")
+	if race {
+		name += "_race"
+	}
+	name += ".test"
+
+	tb.mu.Lock()
+	bin, ok := tb.binaries[name]
+	if !ok {
+		bin = &testBinary{}
+		tb.binaries[name] = bin
+	}
+	tb.mu.Unlock()
+
+	bin.mu.Lock()
+	defer bin.mu.Unlock()
+
+	if bin.built {
+		return bin.path, bin.err
+	}
+
+	path, err := tb.build(ctx, pkg, filepath.Join(tb.dir, name), race)
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+	bin.path, bin.err, bin.built = path, err, true
+
+	return path, err
+}
+
+// build compiles the package's test binary to path, with the same flags "go
+// test -fuzz" uses, so that the binary is instrumented for fuzzing. It returns
+// the path of the binary, or an empty path if the package has no test files,
+// in which case "go test -c" writes no binary.
+func (tb *testBinaries) build(ctx context.Context, pkg, path string,
+	race bool) (string, error) {
+
+	if err := EnsureDirExists(tb.dir); err != nil {
+		return "", err
+	}
+
+	args := []string{"test", "-c", "-fuzz=.", "-o", path}
+	if race {
+		args = append(args, "-race")
+	}
+	args = append(args, ".")
+
+	cmd := goCommand(ctx, tb.cfg, filepath.Join(tb.cfg.ProjectDir, pkg),
+		args...)
+
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	if err := cmd.Run(); err != nil {
+		return "", &testBuildError{
+			pkg:    pkg,
+			output: strings.TrimSpace(output.String()),
+			err:    err,
+		}
+	}
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return "", nil
+	}
+
+	return path, nil
+}
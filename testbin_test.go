@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestPackage writes a module into dir containing the package pkg with
+// the given test file contents.
+func writeTestPackage(t *testing.T, dir, pkg, testFile string) {
+	t.Helper()
+
+	pkgDir := filepath.Join(dir, pkg)
+	require.NoError(t, os.MkdirAll(pkgDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"),
+		[]byte("module example.com/fuzz\n\ngo 1.23\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(pkgDir, "fuzz_test.go"),
+		[]byte(testFile), 0644))
+}
+
+// TestTestBinaries verifies that a package's test binary is built once and
+// shared, that it lists the package's fuzz targets, and that compilation
+// failures are reported as build errors.
+func TestTestBinaries(t *testing.T) {
+	dir := t.TempDir()
+	writeTestPackage(t, dir, "parser", `package parser
+
+import "testing"
+
+func FuzzParse(f *testing.F) { f.Fuzz(func(t *testing.T, b []byte) {}) }
+
+func FuzzEval(f *testing.F) { f.Fuzz(func(t *testing.T, s string) {}) }
+`)
+	writeTestPackage(t, dir, "broken", `package broken
+
+func FuzzBroken(f *testing.F) {}
+`)
+
+	cfg := &Config{
+		ProjectDir: dir,
+		BinDir:     filepath.Join(t.TempDir(), TmpBinDir),
+	}
+	bins := newTestBinaries(cfg)
+	ctx := context.Background()
+
+	path, err := bins.get(ctx, "parser", false)
+	require.NoError(t, err)
+	require.FileExists(t, path)
+
+	// The second lookup reuses the binary instead of rebuilding it.
+	require.NoError(t, os.Remove(path))
+	again, err := bins.get(ctx, "parser", false)
+	require.NoError(t, err)
+	assert.Equal(t, path, again)
+	assert.NoFileExists(t, again)
+
+	// The cached binary was removed above, so list the fuzz targets
+	// through a fresh set.
+	bins = newTestBinaries(cfg)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	targets, err := listFuzzTargets(ctx, logger, cfg, bins, "parser")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"FuzzParse", "FuzzEval"}, targets)
+
+	_, err = bins.get(ctx, "broken", false)
+	var buildErr *testBuildError
+	require.ErrorAs(t, err, &buildErr)
+	assert.Contains(t, buildErr.output, "undefined: testing")
+}
+
+// TestListPkgsFuzzTargetsBroken verifies that a package failing to build is
+// returned with its build error while the other packages are still listed.
+func TestListPkgsFuzzTargetsBroken(t *testing.T) {
+	dir := t.TempDir()
+	writeTestPackage(t, dir, "parser", `package parser
+
+import "testing"
+
+func FuzzParse(f *testing.F) { f.Fuzz(func(t *testing.T, b []byte) {}) }
+`)
+	writeTestPackage(t, dir, "broken", `package broken
+
+func FuzzBroken(f *testing.F) {}
+`)
+
+	cfg := &Config{
+		ProjectDir:   dir,
+		BinDir:       filepath.Join(t.TempDir(), TmpBinDir),
+		FuzzPkgsPath: []string{"broken", "parser"},
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	pkgTargets, total, broken, err := listPkgsFuzzTargets(
+		context.Background(), logger, cfg, newTestBinaries(cfg))
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{"parser": {"FuzzParse"}},
+		pkgTargets)
+	assert.Equal(t, 1, total)
+	require.Contains(t, broken, "broken")
+	assert.Contains(t, broken["broken"].output, "undefined: testing")
+}
+
+// TestReportBrokenPackages verifies that the fuzz targets declared in the test
+// files of a package failing to build are recorded and announced as broken.
+func TestReportBrokenPackages(t *testing.T) {
+	dir := t.TempDir()
+	writeTestPackage(t, dir, "broken", `package broken
+
+import "testing"
+
+func FuzzDecode(f *testing.F) { undefinedHelper() }
+
+func FuzzEncode(f *testing.F) {}
+
+func FuzzNotTarget(t *testing.T) {}
+
+func helper(f *testing.F) {}
+`)
+	writeTestPackage(t, dir, "empty", "package empty\n")
+
+	cfg := &Config{
+		ProjectDir:      dir,
+		FuzzResultsPath: t.TempDir(),
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	sink := &recordingSink{}
+	n := newNotifierWithSinks(logger, sink)
+	reportBrokenPackages(logger, cfg, n, map[string]*testBuildError{
+		"broken": {pkg: "broken", output: "undefined: undefinedHelper"},
+		"empty":  {pkg: "empty", output: "no Go files"},
+	}, "abc123")
+	n.close()
+
+	assert.Equal(t, []eventType{eventTargetBuildFailure,
+		eventTargetBuildFailure, eventTargetBuildFailure}, sink.events)
+	for _, target := range []string{"FuzzDecode", "FuzzEncode"} {
+		data, err := os.ReadFile(filepath.Join(cfg.FuzzResultsPath,
+			targetResultFileName("broken", target)))
+		require.NoError(t, err)
+
+		var res targetResult
+		require.NoError(t, json.Unmarshal(data, &res))
+		assert.Equal(t, targetStatusBroken, res.Status, target)
+		assert.Equal(t, "abc123", res.Commit, target)
+		assert.Equal(t, "undefined: undefinedHelper", res.Stderr,
+			target)
+	}
+	assert.NoFileExists(t, filepath.Join(cfg.FuzzResultsPath,
+		targetResultFileName("broken", "FuzzNotTarget")))
+}
+
+// TestReplayFuzzInputRace verifies that a failing input caused by a data race
+// only reproduces when replayed with the race detector.
+func TestReplayFuzzInputRace(t *testing.T) {
+	dir := t.TempDir()
+	writeTestPackage(t, dir, "racy", `package racy
+
+import "testing"
+
+func FuzzRace(f *testing.F) {
+	f.Fuzz(func(t *testing.T, n int) {
+		if n != 7 {
+			return
+		}
+		x, done := 0, make(chan bool)
+		go func() {
+			x++
+			done <- true
+		}()
+		x++
+		<-done
+	})
+}
+`)
+
+	pkgPath := filepath.Join(dir, "racy")
+	input := []byte("go test fuzz v1\nint(7)\n")
+	cfg := &Config{ReplayTimeout: time.Minute}
+
+	reproduced, err := replayFuzzInput(context.Background(), cfg,
+		pkgPath, "FuzzRace", "racy-input", input, false)
+	require.NoError(t, err)
+	assert.False(t, reproduced)
+
+	reproduced, err = replayFuzzInput(context.Background(), cfg,
+		pkgPath, "FuzzRace", "racy-input", input, true)
+	require.NoError(t, err)
+	assert.True(t, reproduced)
+	assert.NoFileExists(t, filepath.Join(pkgPath, "testdata", "fuzz",
+		"FuzzRace", "racy-input"))
+}
+
+// TestReplayFuzzInputTimeout verifies that an input that still hangs is
+// reported as failing once the replay timeout elapses.
+func TestReplayFuzzInputTimeout(t *testing.T) {
+	dir := t.TempDir()
+	writeTestPackage(t, dir, "hang", `package hang
+
+import "testing"
+
+func FuzzHang(f *testing.F) {
+	f.Fuzz(func(t *testing.T, n int) {
+		if n == 7 {
+			select {}
+		}
+	})
+}
+`)
+
+	reproduced, err := replayFuzzInput(context.Background(),
+		&Config{ReplayTimeout: 3 * time.Second},
+		filepath.Join(dir, "hang"), "FuzzHang", "hang-input",
+		[]byte("go test fuzz v1\nint(7)\n"), false)
+	require.NoError(t, err)
+	assert.True(t, reproduced)
+}
+
+// TestVerifyCrashFixes verifies that crashes whose input no longer fails are
+// marked as fixed, except for crashes caused by the sandbox's memory limit,
+// which a replay outside the sandbox cannot reproduce.
+func TestVerifyCrashFixes(t *testing.T) {
+	dir := t.TempDir()
+	writeTestPackage(t, dir, "parser", `package parser
+
+import "testing"
+
+func FuzzParse(f *testing.F) {
+	f.Fuzz(func(t *testing.T, n int) {})
+}
+`)
+
+	cfg := &Config{
+		ProjectDir:      dir,
+		FuzzResultsPath: t.TempDir(),
+		ReplayTimeout:   time.Minute,
+	}
+	for _, rec := range []*crashRecord{
+		{Package: "parser", Target: "FuzzParse", Signature: "panic",
+			Class: crashClassPanic},
+		{Package: "parser", Target: "FuzzParse", Signature: "limit",
+			Class: crashClassLimit},
+	} {
+		rec.InputID = rec.Signature
+		rec.Input = []byte("go test fuzz v1\nint(7)\n")
+		require.NoError(t, saveCrashRecord(cfg.FuzzResultsPath, rec))
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	sink := &recordingSink{}
+	n := newNotifierWithSinks(logger, sink)
+	verifyCrashFixes(context.Background(), logger, cfg,
+		map[string][]string{"parser": {"FuzzParse"}}, n)
+	n.close()
+
+	assert.Equal(t, []eventType{eventCrashFixed}, sink.events)
+	for signature, fixed := range map[string]bool{
+		"panic": true,
+		"limit": false,
+	} {
+		rec, err := loadCrashRecord(filepath.Join(cfg.FuzzResultsPath,
+			crashRecordFileName("parser", "FuzzParse", signature)))
+		require.NoError(t, err)
+		assert.Equal(t, fixed, rec.Fixed, signature)
+	}
+}
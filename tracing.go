@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// tracerName is the name of the instrumentation scope of the spans.
+	tracerName = "github.com/go-continuous-fuzz/go-continuous-fuzz"
+
+	// serviceName is the service the spans are reported for.
+	serviceName = "go-continuous-fuzz"
+
+	// traceExporterOTLP sends the spans to an OTLP/HTTP collector.
+	traceExporterOTLP = "otlp"
+
+	// traceExporterFile writes the spans as JSON to a local file.
+	traceExporterFile = "file"
+
+	// tracingShutdownTimeout bounds the time spent flushing the pending
+	// spans on exit.
+	tracingShutdownTimeout = 10 * time.Second
+)
+
+// setupTracing installs the global tracer provider exporting spans as
+// configured by cfg. The returned function flushes the pending spans and
+// shuts the provider down. Tracing is disabled if no exporter is configured,
+// in which case spans are never recorded.
+func setupTracing(ctx context.Context,
+	cfg *Config) (func(context.Context) error, error) {
+
+	var (
+		exporter sdktrace.SpanExporter
+		file     *os.File
+		err      error
+	)
+	switch cfg.TraceExporter {
+	case "":
+		return func(context.Context) error { return nil }, nil
+
+	case traceExporterOTLP:
+		// The endpoint, headers and TLS settings default to the
+		// standard OTEL_EXPORTER_OTLP_* environment variables.
+		var opts []otlptracehttp.Option
+		if cfg.TraceEndpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(
+				cfg.TraceEndpoint))
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("creating OTLP exporter: %w",
+				err)
+		}
+
+	case traceExporterFile:
+		err = EnsureDirExists(filepath.Dir(cfg.TraceFile))
+		if err != nil {
+			return nil, err
+		}
+		file, err = os.OpenFile(cfg.TraceFile,
+			os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("opening trace file: %w", err)
+		}
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("creating file exporter: %w",
+				err)
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q",
+			cfg.TraceExporter)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(
+			semconv.ServiceName(serviceName),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if file != nil {
+			err = errors.Join(err, file.Close())
+		}
+		return err
+	}, nil
+}
+
+// startSpan starts a span named name as a child of the span in ctx, if any,
+// and returns it along with a context carrying it.
+func startSpan(ctx context.Context, name string,
+	attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+
+	return otel.Tracer(tracerName).Start(ctx, name,
+		trace.WithAttributes(attrs...))
+}
+
+// endSpan ends the span, marking it as failed with err if it is not nil.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// restoreTracerProvider restores the global tracer provider at the end of the
+// test.
+func restoreTracerProvider(t *testing.T) {
+	t.Helper()
+
+	prev := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+}
+
+// spanAttrs returns the attributes of a span by key.
+func spanAttrs(span sdktrace.ReadOnlySpan) map[string]attribute.Value {
+	attrs := make(map[string]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attrs[string(kv.Key)] = kv.Value
+	}
+
+	return attrs
+}
+
+// TestSetupTracingFile verifies that the file exporter writes the spans, with
+// their attributes and errors, as JSON to the trace file.
+func TestSetupTracingFile(t *testing.T) {
+	restoreTracerProvider(t)
+
+	path := filepath.Join(t.TempDir(), "traces", "spans.json")
+	shutdown, err := setupTracing(context.Background(), &Config{
+		TraceExporter: traceExporterFile,
+		TraceFile:     path,
+	})
+	require.NoError(t, err)
+
+	ctx, parent := startSpan(context.Background(), "cycle",
+		attribute.Int("cycle", 3))
+	_, child := startSpan(ctx, "s3 upload",
+		attribute.Int("bytes", 42))
+	endSpan(child, errors.New("access denied"))
+	endSpan(parent, nil)
+	require.NoError(t, shutdown(context.Background()))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	type exportedSpan struct {
+		Name        string
+		SpanContext struct{ TraceID string }
+		Parent      struct{ SpanID string }
+		Status      struct{ Code string }
+	}
+	var spans []exportedSpan
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	for dec.More() {
+		var span exportedSpan
+		require.NoError(t, dec.Decode(&span))
+		spans = append(spans, span)
+	}
+
+	// Children end first, so they are exported first.
+	require.Len(t, spans, 2)
+	assert.Equal(t, "s3 upload", spans[0].Name)
+	assert.Equal(t, "Error", spans[0].Status.Code)
+	assert.Equal(t, "cycle", spans[1].Name)
+	assert.Equal(t, spans[1].SpanContext.TraceID,
+		spans[0].SpanContext.TraceID)
+	assert.Contains(t, string(data), `"Key":"bytes"`)
+}
+
+// TestSetupTracingDisabled verifies that no tracer provider is installed if
+// no exporter is configured.
+func TestSetupTracingDisabled(t *testing.T) {
+	restoreTracerProvider(t)
+
+	prev := otel.GetTracerProvider()
+	shutdown, err := setupTracing(context.Background(), &Config{})
+	require.NoError(t, err)
+	require.NoError(t, shutdown(context.Background()))
+
+	assert.Equal(t, prev, otel.GetTracerProvider())
+}
+
+// TestRunWorkerSpans verifies that each fuzz target run by a worker is traced
+// as a span carrying the target, the fuzzed commit and the outcome.
+func TestRunWorkerSpans(t *testing.T) {
+	restoreTracerProvider(t)
+
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(recorder)))
+
+	cfg := newFakeExecutorConfig(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	executor := &fakeExecutor{runs: map[string]fakeRun{
+		"FuzzParse": {
+			outputFile:    "panic.txt",
+			failingInputs: panicInput,
+		},
+		"FuzzLexer": buildFailedRun,
+	}}
+
+	state, err := loadFuzzState(cfg.FuzzResultsPath)
+	require.NoError(t, err)
+	state.setLastCommit("abc123")
+
+	n := newNotifierWithSinks(logger)
+	defer n.close()
+
+	taskQueue := NewTaskQueue()
+	taskQueue.Enqueue(Task{Package: "parser", Target: "FuzzParse"})
+	taskQueue.Enqueue(Task{Package: "parser", Target: "FuzzLexer"})
+
+	err = runWorker(2, context.Background(), taskQueue, time.Minute,
+		logger, cfg, executor, n, newFuzzMetrics(1),
+		newFuzzStatus(cfg), state, newCycleStats(1, "abc123"))
+	require.NoError(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+
+	expected := []struct {
+		target string
+		status targetStatus
+		code   codes.Code
+	}{
+		{"FuzzParse", targetStatusCrashed, codes.Unset},
+		{"FuzzLexer", targetStatusBroken, codes.Error},
+	}
+	for i, want := range expected {
+		span := spans[i]
+		assert.Equal(t, "fuzz target", span.Name())
+		assert.Equal(t, want.code, span.Status().Code, want.target)
+
+		attrs := spanAttrs(span)
+		assert.Equal(t, int64(2), attrs["worker"].AsInt64())
+		assert.Equal(t, "parser", attrs["package"].AsString())
+		assert.Equal(t, want.target, attrs["target"].AsString())
+		assert.Equal(t, "abc123", attrs["commit"].AsString())
+		assert.Equal(t, string(want.status),
+			attrs["status"].AsString())
+	}
+}
@@ -63,6 +63,19 @@ func CalculateFuzzSeconds(syncFrequency time.Duration, numWorkers int,
 		float64(totalTargets)
 }
 
+// calculateBoostedFuzzSeconds calculates the per-target fuzz duration when
+// changedTargets of the targets are fuzzed boost times as long as the others,
+// within the same (SyncFrequency * NumWorkers). It returns the duration of the
+// unchanged targets.
+func calculateBoostedFuzzSeconds(syncFrequency time.Duration, numWorkers,
+	totalTargets, changedTargets int, boost float64) float64 {
+
+	shares := float64(totalTargets-changedTargets) +
+		boost*float64(changedTargets)
+
+	return syncFrequency.Seconds() * float64(numWorkers) / shares
+}
+
 // ComputeSHA256Short computes a SHA-256 hash of the concatenation of
 // the given package name, fuzz target, and error data(*.go:<line>), then
 // returns the first 16 characters of the hash.
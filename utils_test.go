@@ -68,6 +68,20 @@ func TestCalculateFuzzSeconds(t *testing.T) {
 	)
 }
 
+// TestCalculateBoostedFuzzSeconds verifies that boosting the changed targets
+// splits the same total fuzz time, and that no boost matches
+// CalculateFuzzSeconds.
+func TestCalculateBoostedFuzzSeconds(t *testing.T) {
+	seconds := calculateBoostedFuzzSeconds(time.Hour, 2, 10, 2, 3)
+	assert.InDelta(t, 7200.0/14, seconds, 1e-9)
+	assert.InDelta(t, 7200, 8*seconds+2*3*seconds, 1e-9)
+
+	assert.Equal(t, CalculateFuzzSeconds(time.Hour, 2, 10),
+		calculateBoostedFuzzSeconds(time.Hour, 2, 10, 2, 1))
+	assert.Equal(t, CalculateFuzzSeconds(time.Hour, 2, 10),
+		calculateBoostedFuzzSeconds(time.Hour, 2, 10, 0, 3))
+}
+
 // TestComputeSHA256Short verifies that ComputeSHA256Short correctly computes a
 // short SHA256 hash based on the package name, fuzz target, and error data.
 // This test ensures that deduplication logic based on this hash remains stable
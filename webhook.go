@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// webhookRequestTimeout bounds the duration of a single webhook
+	// delivery attempt.
+	webhookRequestTimeout = 30 * time.Second
+
+	// webhookInitialBackoff is the delay before the first retry of a
+	// failed delivery. It doubles with every further retry.
+	webhookInitialBackoff = time.Second
+
+	// webhookSignatureHeader carries the HMAC-SHA256 signature of the
+	// payload, in the form "sha256=<hex digest>".
+	webhookSignatureHeader = "X-Fuzz-Signature-256"
+
+	// webhookEventHeader carries the type of the delivered event.
+	webhookEventHeader = "X-Fuzz-Event"
+)
+
+// webhookSink delivers events as JSON documents POSTed to a generic webhook.
+// Failed deliveries are retried with exponential backoff, and the number of
+// deliveries per minute is limited so that a crash storm doesn't flood the
+// receiver.
+type webhookSink struct {
+	// url is the webhook endpoint.
+	url string
+
+	// headers are added to every request.
+	headers http.Header
+
+	// secret is the HMAC key used to sign payloads. Payloads are not
+	// signed if it is empty.
+	secret []byte
+
+	// maxRetries is the number of retries after a failed delivery.
+	maxRetries int
+
+	// backoff is the delay before the first retry.
+	backoff time.Duration
+
+	// limiter limits the rate of deliveries.
+	limiter *rateLimiter
+
+	// client performs the HTTP requests.
+	client *http.Client
+
+	// logger for informational and error messages.
+	logger *slog.Logger
+}
+
+// newWebhookSink returns a webhook sink configured from cfg. The headers in
+// cfg are expected to have been validated by loadConfig.
+func newWebhookSink(logger *slog.Logger, cfg *Config) *webhookSink {
+	headers := make(http.Header)
+	for _, header := range cfg.WebhookHeaders {
+		name, value, _ := strings.Cut(header, ":")
+		headers.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+
+	return &webhookSink{
+		url:        cfg.WebhookURL,
+		headers:    headers,
+		secret:     []byte(cfg.WebhookSecret),
+		maxRetries: cfg.WebhookMaxRetries,
+		backoff:    webhookInitialBackoff,
+		limiter:    newRateLimiter(cfg.WebhookRateLimit, time.Minute),
+		client:     &http.Client{Timeout: webhookRequestTimeout},
+		logger:     logger,
+	}
+}
+
+// name identifies the sink in logs.
+func (w *webhookSink) name() string {
+	return "webhook"
+}
+
+// notify delivers the event, unless the rate limit has been reached, in which
+// case the event is dropped and accounted for in the next delivered payload.
+func (w *webhookSink) notify(ctx context.Context, ev *event) error {
+	suppressed, ok := w.limiter.allow()
+	if !ok {
+		w.logger.Warn("Webhook rate limit reached; dropping event",
+			"type", ev.Type)
+		return nil
+	}
+
+	// Copy the event, since it is shared with the other sinks.
+	payload := *ev
+	payload.SuppressedEvents = suppressed
+
+	body, err := json.Marshal(&payload)
+	if err != nil {
+		return fmt.Errorf("encoding webhook payload: %w", err)
+	}
+
+	backoff := w.backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := w.deliver(ctx, ev.Type, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= w.maxRetries {
+			return fmt.Errorf("delivering webhook after %d "+
+				"attempt(s): %w", attempt+1, err)
+		}
+
+		w.logger.Warn("Webhook delivery failed; retrying", "type",
+			ev.Type, "attempt", attempt+1, "backoff", backoff,
+			"error", err)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+// deliver performs a single delivery attempt, reporting whether a failure is
+// worth retrying.
+func (w *webhookSink) deliver(ctx context.Context, evType eventType,
+	body []byte) (bool, error) {
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url,
+		bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+
+	for name, values := range w.headers {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go-continuous-fuzz")
+	req.Header.Set(webhookEventHeader, string(evType))
+	if len(w.secret) > 0 {
+		req.Header.Set(webhookSignatureHeader,
+			signWebhookPayload(w.secret, body))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		// Network errors are transient unless the context is done.
+		return ctx.Err() == nil, err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			w.logger.Error("Failed to close response body",
+				"error", err)
+		}
+	}()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	err = fmt.Errorf("unexpected status %s: %s", resp.Status,
+		strings.TrimSpace(string(msg)))
+
+	// Server errors and throttling are transient, while any other client
+	// error won't go away by retrying.
+	retry := resp.StatusCode >= 500 ||
+		resp.StatusCode == http.StatusTooManyRequests
+
+	return retry, err
+}
+
+// signWebhookPayload returns the value of the signature header for the given
+// payload, i.e. "sha256=" followed by the hex-encoded HMAC-SHA256 of the
+// payload keyed with secret.
+func signWebhookPayload(secret, payload []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// rateLimiter is a token bucket allowing up to limit events per interval,
+// refilled continuously. A limit of zero or less disables rate limiting.
+type rateLimiter struct {
+	mu         sync.Mutex
+	limit      int
+	interval   time.Duration
+	tokens     float64
+	last       time.Time
+	suppressed int
+	now        func() time.Time
+}
+
+// newRateLimiter returns a rate limiter allowing up to limit events per
+// interval, starting with a full bucket.
+func newRateLimiter(limit int, interval time.Duration) *rateLimiter {
+	return &rateLimiter{
+		limit:    limit,
+		interval: interval,
+		tokens:   float64(limit),
+		last:     time.Now(),
+		now:      time.Now,
+	}
+}
+
+// allow reports whether another event may pass. When it may, the number of
+// events rejected since the previous allowed event is returned as well.
+func (r *rateLimiter) allow() (int, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.limit <= 0 {
+		return 0, true
+	}
+
+	// Refill the bucket in proportion to the time elapsed.
+	now := r.now()
+	elapsed := now.Sub(r.last)
+	r.last = now
+	r.tokens += elapsed.Seconds() / r.interval.Seconds() *
+		float64(r.limit)
+	if r.tokens > float64(r.limit) {
+		r.tokens = float64(r.limit)
+	}
+
+	if r.tokens < 1 {
+		r.suppressed++
+		return 0, false
+	}
+
+	r.tokens--
+	suppressed := r.suppressed
+	r.suppressed = 0
+
+	return suppressed, true
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// webhookReceiver records the requests received by a test webhook endpoint.
+type webhookReceiver struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+
+	// failures is the number of initial requests answered with status.
+	failures int
+	status   int
+}
+
+// ServeHTTP records the request and answers it.
+func (wr *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	wr.requests = append(wr.requests, r)
+	wr.bodies = append(wr.bodies, body)
+
+	if len(wr.requests) <= wr.failures {
+		w.WriteHeader(wr.status)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// received returns the number of received requests.
+func (wr *webhookReceiver) received() int {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	return len(wr.requests)
+}
+
+// newTestWebhookSink returns a webhook sink delivering to the given server
+// without delays between retries.
+func newTestWebhookSink(t *testing.T, url string, cfg Config) *webhookSink {
+	t.Helper()
+
+	cfg.WebhookURL = url
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	sink := newWebhookSink(logger, &cfg)
+	sink.backoff = time.Millisecond
+
+	return sink
+}
+
+// TestWebhookSinkDelivery verifies that the webhook payload, custom headers
+// and HMAC signature are delivered as documented.
+func TestWebhookSinkDelivery(t *testing.T) {
+	receiver := &webhookReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	sink := newTestWebhookSink(t, server.URL, Config{
+		WebhookHeaders: []string{"Authorization: Bearer abc"},
+		WebhookSecret:  "s3cret",
+	})
+
+	ev := &event{
+		Type:    eventNewCrash,
+		Time:    time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC),
+		Package: "parser",
+		Target:  "FuzzParseComplex",
+		Message: "New crash detected in parser/FuzzParseComplex",
+		Crash: &crashRecord{
+			Package:   "parser",
+			Target:    "FuzzParseComplex",
+			Signature: "342a5c470d17be27",
+		},
+	}
+	require.NoError(t, sink.notify(context.Background(), ev))
+	require.Equal(t, 1, receiver.received())
+
+	req, body := receiver.requests[0], receiver.bodies[0]
+	assert.Equal(t, "Bearer abc", req.Header.Get("Authorization"))
+	assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+	assert.Equal(t, "new_crash", req.Header.Get(webhookEventHeader))
+	assert.Equal(t, signWebhookPayload([]byte("s3cret"), body),
+		req.Header.Get(webhookSignatureHeader))
+
+	var payload map[string]any
+	require.NoError(t, json.Unmarshal(body, &payload))
+	assert.Equal(t, "new_crash", payload["type"])
+	assert.Equal(t, "parser", payload["package"])
+	assert.Equal(t, "FuzzParseComplex", payload["target"])
+	assert.Equal(t, "2025-06-01T12:00:00Z", payload["time"])
+	crash, ok := payload["crash"].(map[string]any)
+	require.True(t, ok, "crash record missing from payload")
+	assert.Equal(t, "342a5c470d17be27", crash["signature"])
+}
+
+// TestWebhookSinkRetries verifies that transient failures are retried while
+// permanent failures are not.
+func TestWebhookSinkRetries(t *testing.T) {
+	tests := []struct {
+		name             string
+		failures         int
+		status           int
+		maxRetries       int
+		expectedRequests int
+		expectErr        bool
+	}{
+		{
+			name:             "server error is retried",
+			failures:         2,
+			status:           http.StatusServiceUnavailable,
+			maxRetries:       3,
+			expectedRequests: 3,
+		},
+		{
+			name:             "retries are exhausted",
+			failures:         10,
+			status:           http.StatusInternalServerError,
+			maxRetries:       2,
+			expectedRequests: 3,
+			expectErr:        true,
+		},
+		{
+			name:             "client error is not retried",
+			failures:         1,
+			status:           http.StatusBadRequest,
+			maxRetries:       3,
+			expectedRequests: 1,
+			expectErr:        true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver := &webhookReceiver{
+				failures: tt.failures,
+				status:   tt.status,
+			}
+			server := httptest.NewServer(receiver)
+			defer server.Close()
+
+			sink := newTestWebhookSink(t, server.URL, Config{
+				WebhookMaxRetries: tt.maxRetries,
+			})
+
+			err := sink.notify(context.Background(), &event{
+				Type: eventCycleFailure,
+			})
+			if tt.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expectedRequests,
+				receiver.received())
+		})
+	}
+}
+
+// TestWebhookSinkRateLimit verifies that deliveries beyond the rate limit are
+// dropped and reported in the next delivered payload.
+func TestWebhookSinkRateLimit(t *testing.T) {
+	receiver := &webhookReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	sink := newTestWebhookSink(t, server.URL, Config{
+		WebhookRateLimit: 2,
+	})
+	now := time.Now()
+	sink.limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, sink.notify(context.Background(), &event{
+			Type: eventNewCrash,
+		}))
+	}
+	assert.Equal(t, 2, receiver.received())
+
+	// Once the bucket has refilled, the next event reports the three
+	// events dropped in the meantime.
+	now = now.Add(time.Minute)
+	require.NoError(t, sink.notify(context.Background(), &event{
+		Type: eventNewCrash,
+	}))
+	require.Equal(t, 3, receiver.received())
+
+	var payload event
+	require.NoError(t, json.Unmarshal(receiver.bodies[2], &payload))
+	assert.Equal(t, 3, payload.SuppressedEvents)
+}
+
+// recordingSink is a notification sink remembering the delivered events.
+type recordingSink struct {
+	mu     sync.Mutex
+	events []eventType
+}
+
+// name identifies the sink in logs.
+func (s *recordingSink) name() string {
+	return "recording"
+}
+
+// notify records the event type.
+func (s *recordingSink) notify(_ context.Context, ev *event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events = append(s.events, ev.Type)
+	return nil
+}
+
+// TestNotifierDeliversInOrder verifies that the notifier delivers all queued
+// events to every sink, in order, before close returns.
+func TestNotifierDeliversInOrder(t *testing.T) {
+	first, second := &recordingSink{}, &recordingSink{}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	n := newNotifierWithSinks(logger, first, second)
+
+	n.notify(&event{Type: eventNewCrash})
+	n.notify(&event{Type: eventCrashFixed})
+	n.notify(&event{Type: eventCycleFailure})
+	n.close()
+
+	// Events sent after close are dropped.
+	n.notify(&event{Type: eventTargetBuildFailure})
+
+	expected := []eventType{
+		eventNewCrash, eventCrashFixed, eventCycleFailure,
+	}
+	assert.Equal(t, expected, first.events)
+	assert.Equal(t, expected, second.events)
+}
@@ -2,60 +2,33 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
-	"sync"
+	"maps"
+	"path/filepath"
+	"slices"
 	"time"
-)
-
-// Task represents a single fuzz‐target job, containing the package path and the
-// specific target name to execute.
-type Task struct {
-	Package string
-	Target  string
-}
-
-// TaskQueue is a simple thread‐safe FIFO queue for scheduling Task items.
-type TaskQueue struct {
-	mu    sync.Mutex
-	tasks []Task
-}
-
-// NewTaskQueue returns an empty, initialized TaskQueue.
-func NewTaskQueue() *TaskQueue {
-	return &TaskQueue{
-		tasks: make([]Task, 0),
-	}
-}
-
-// Enqueue adds a new Task to the back of the queue.
-func (q *TaskQueue) Enqueue(t Task) {
-	q.tasks = append(q.tasks, t)
-}
-
-// Dequeue removes and returns the next Task from the queue. If the queue is
-// empty, it returns false for the second return value.
-func (q *TaskQueue) Dequeue() (Task, bool) {
-	q.mu.Lock()
-	defer q.mu.Unlock()
 
-	if len(q.tasks) == 0 {
-		return Task{}, false
-	}
-
-	t := q.tasks[0]
-	q.tasks = q.tasks[1:]
-	return t, true
-}
+	"go.opentelemetry.io/otel/attribute"
+)
 
 // runWorker continuously pulls tasks from taskQueue and executes them via
-// fuzz.executeFuzzTarget. Each Task is run with its own timeout (taskTimeout).
+// fuzz.executeFuzzTarget, using the executor. Each Task is run with its own
+// timeout, taskTimeout unless the Task sets another one. Crashes found by a
+// Task are announced through the notifier and, if enabled, queued for
+// bisection. Tasks that complete without crashing mark the cycle's commit as
+// known-good for their target. The progress and outcome of every run are added
+// to the cycle's stats, the crashes and worker utilization are recorded in m,
+// and the running task in status.
 //
 // If the schedular context is canceled or any Task execution returns an error,
 // runWorker stops and returns that error. If the queue is empty, it logs that
 // it’s done and returns nil.
 func runWorker(workerID int, schedulerCtx context.Context, taskQueue *TaskQueue,
-	taskTimeout time.Duration, logger *slog.Logger, cfg *Config) error {
+	taskTimeout time.Duration, logger *slog.Logger, cfg *Config,
+	executor Executor, n *notifier, m *fuzzMetrics, status *fuzzStatus,
+	state *fuzzState, stats *cycleStats) error {
 
 	for {
 		task, ok := taskQueue.Dequeue()
@@ -65,29 +38,161 @@ func runWorker(workerID int, schedulerCtx context.Context, taskQueue *TaskQueue,
 			return nil
 		}
 
+		timeout := taskTimeout
+		if task.Timeout > 0 {
+			timeout = task.Timeout
+		}
+
 		logger.Info(
 			"Worker starting fuzz target", "workerID", workerID,
 			"package", task.Package, "target", task.Target,
-			"timeout", taskTimeout,
+			"timeout", timeout, "race", task.Race,
 		)
 
 		// Create a sub‐context with timeout for this individual fuzz
-		// target.
-		taskCtx, cancel := context.WithTimeout(schedulerCtx,
-			taskTimeout)
-		err := executeFuzzTarget(taskCtx, logger, task.Package,
-			task.Target, cfg, taskTimeout)
+		// target, traced as a span of the cycle.
+		spanCtx, span := startSpan(schedulerCtx, "fuzz target",
+			attribute.Int("worker", workerID),
+			attribute.String("package", task.Package),
+			attribute.String("target", task.Target),
+			attribute.Bool("race", task.Race))
+		taskCtx, cancel := context.WithTimeout(spanCtx, timeout)
+		m.workerStarted()
+		status.workerStarted(workerID, task, timeout)
+		taskStart := time.Now()
+		crash, err := executeFuzzTarget(taskCtx, logger, cfg, executor,
+			task, timeout, stats)
+		status.workerFinished(workerID)
+		m.workerFinished(time.Since(taskStart))
 		cancel()
 
+		// Record the outcome of the run, unless it was interrupted.
+		commit := state.lastCommit()
+		res := newTargetResult(task, commit, crash, err)
+		stats.finishRun(task, res.Status)
+		if schedulerCtx.Err() == nil {
+			saveResult(logger, cfg, res)
+		}
+		span.SetAttributes(attribute.String("commit", commit),
+			attribute.String("status", string(res.Status)))
+		endSpan(span, err)
+
+		// A broken target can't be fuzzed until its package is fixed,
+		// but the other targets can.
+		var brokenErr *targetBrokenError
+		if errors.As(err, &brokenErr) {
+			logger.Error("Fuzz target is broken", "workerID",
+				workerID, "package", task.Package, "target",
+				task.Target, "output", brokenErr.output)
+			notifyBrokenTarget(n, task, brokenErr.output)
+			continue
+		}
+
 		if err != nil {
 			return fmt.Errorf("worker %d: fuzz target %q/%q "+
 				"failed: %w", workerID, task.Package,
 				task.Target, err)
 		}
 
+		switch {
+		case crash == nil:
+			state.markGood(task.Package, task.Target, commit)
+
+		case crash.Record != nil:
+			m.crashFound(crash)
+			n.notify(newCrashEvent(crash))
+			queueBisect(cfg, state, crash, commit)
+		}
+
 		logger.Info(
 			"Worker completed fuzz target", "workerID", workerID,
 			"package", task.Package, "target", task.Target,
 		)
 	}
 }
+
+// saveResult stores the result record of a run, logging any failure to do so.
+func saveResult(logger *slog.Logger, cfg *Config, res *targetResult) {
+	if err := saveTargetResult(cfg.FuzzResultsPath, res); err != nil {
+		logger.Error("Failed to save target result", "package",
+			res.Package, "target", res.Target, "error", err)
+	}
+}
+
+// notifyBrokenTarget announces that the task's fuzz target is broken because
+// its package failed to build with the given output.
+func notifyBrokenTarget(n *notifier, task Task, output string) {
+	n.notify(&event{
+		Type:    eventTargetBuildFailure,
+		Package: task.Package,
+		Target:  task.Target,
+		Message: fmt.Sprintf("Fuzz target %s/%s is broken: its "+
+			"package failed to build", task.Package, task.Target),
+		Error: output,
+	})
+}
+
+// reportBrokenPackages reports the fuzz targets of the packages whose test
+// binary failed to compile during discovery as broken, through a result record
+// and a notification each. As the targets of a broken package cannot be
+// listed by its test binary, they are found in its test files. A package in
+// which none are found is announced as a whole.
+func reportBrokenPackages(logger *slog.Logger, cfg *Config, n *notifier,
+	broken map[string]*testBuildError, commit string) {
+
+	for _, pkg := range slices.Sorted(maps.Keys(broken)) {
+		output := broken[pkg].output
+		targets, err := sourceFuzzTargets(filepath.Join(cfg.ProjectDir,
+			pkg))
+		if err != nil {
+			logger.Error("Failed to find fuzz targets in test "+
+				"files", "package", pkg, "error", err)
+		}
+		if len(targets) == 0 {
+			n.notify(&event{
+				Type:    eventTargetBuildFailure,
+				Package: pkg,
+				Message: fmt.Sprintf("Package %s failed to "+
+					"build; its fuzz targets are broken",
+					pkg),
+				Error: output,
+			})
+			continue
+		}
+
+		for _, target := range targets {
+			task := Task{Package: pkg, Target: target}
+			logger.Error("Fuzz target is broken", "package", pkg,
+				"target", target)
+			saveResult(logger, cfg, newTargetResult(task, commit,
+				nil, &targetBrokenError{output: output}))
+			notifyBrokenTarget(n, task, output)
+		}
+	}
+}
+
+// queueBisect queues a new or regressed crash for bisection, if bisection is
+// enabled and the target has a known-good commit preceding the crash.
+func queueBisect(cfg *Config, state *fuzzState, crash *fuzzCrash,
+	commit string) {
+
+	rec := crash.Record
+	if !cfg.Bisect || !(crash.IsNew || crash.Regression) ||
+		len(rec.Input) == 0 {
+
+		return
+	}
+
+	good := state.lastGoodCommit(rec.Package, rec.Target)
+	if good == "" || good == commit {
+		return
+	}
+
+	state.addBisect(bisectJob{
+		Package:    rec.Package,
+		Target:     rec.Target,
+		Signature:  rec.Signature,
+		GoodCommit: good,
+		BadCommit:  commit,
+	})
+}